@@ -0,0 +1,242 @@
+package message
+
+import (
+	"bitcoin-go/btc/transaction"
+	"bitcoin-go/ecc"
+	"bitcoin-go/utility"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Generic signed messages (BIP322). Instead of a bare signature, the signer proves they could spend
+// an output locked by the address' scriptPubKey (the "message challenge"). Two virtual transactions
+// are built:
+//
+//	to_spend: spends nothing, commits to the message hash and has a single output locked by the challenge
+//	to_sign:  spends that output and has a single OP_RETURN output
+//
+// A signature is valid if to_sign's input passes the script interpreter. The "simple" format only
+// carries the witness of to_sign's input, the "full" format carries the whole to_sign transaction.
+
+type BIP322Format int
+
+const (
+	BIP322Simple BIP322Format = iota
+	BIP322Full
+)
+
+const opReturn = 0x6a
+
+// The tagged hash of the message that gets committed to in to_spend.
+func BIP322MessageHash(message []byte) []byte {
+	return utility.TaggedHash("BIP0322-signed-message", message)
+}
+
+// Builds the to_spend transaction for the challenge and message.
+func BIP322ToSpend(challenge transaction.Script, message []byte) transaction.Tx {
+
+	scriptSig := transaction.Script{}
+	scriptSig.AddOpCode(0x00)
+	scriptSig.AddData(BIP322MessageHash(message))
+
+	var nullHash [32]byte
+	txIn := transaction.NewTxIn(nullHash, 0xffffffff, &scriptSig, 0)
+	txOut := transaction.NewTxOut(0, challenge)
+
+	return transaction.NewTx(0, []transaction.TxIn{txIn}, []transaction.TxOut{txOut}, 0, false)
+}
+
+// Builds the unsigned to_sign transaction that spends the output of to_spend.
+func BIP322ToSign(toSpend *transaction.Tx) transaction.Tx {
+
+	var toSpendHash [32]byte
+	copy(toSpendHash[:], toSpend.Hash())
+
+	txIn := transaction.NewTxIn(toSpendHash, 0, &transaction.Script{}, 0)
+
+	output := transaction.Script{}
+	output.AddOpCode(opReturn)
+	txOut := transaction.NewTxOut(0, output)
+
+	return transaction.NewTx(0, []transaction.TxIn{txIn}, []transaction.TxOut{txOut}, 0, false)
+}
+
+// Signs the message for a P2PKH, P2WPKH or P2TR (key path only) challenge belonging to the key.
+// Only the full format can be used for legacy scripts since they don't have a witness. P2WSH
+// challenges only commit to the hash of their witness script, so they can't be signed from a key.
+func SignBIP322(key *ecc.PrivateKey, challenge transaction.Script, message string, format BIP322Format) (string, error) {
+
+	if version, program, _ := challenge.WitnessProgram(); version == 0 && len(program) == 32 {
+		return "", errors.New("signing P2WSH challenges is not supported, the witness script isn't known")
+	}
+
+	witness := isWitnessProgram(&challenge)
+	if !witness && format != BIP322Full {
+		return "", errors.New("legacy scripts can only be signed with the full format")
+	}
+
+	toSpend := BIP322ToSpend(challenge, []byte(message))
+	toSign := BIP322ToSign(&toSpend)
+
+	// Taproot signatures can leave the hash type out, which is what BIP322 signers do.
+	hashType := byte(transaction.SIGHASH_ALL)
+	if version, _, _ := challenge.WitnessProgram(); version == 1 {
+		hashType = transaction.SIGHASH_DEFAULT
+	}
+
+	// to_spend only exists here, so its output is passed in rather than fetched.
+	if err := toSign.SignInputWithPrevOuts(0, key, hashType, toSpend.TxOuts); err != nil {
+		return "", err
+	}
+
+	if witness && format == BIP322Simple {
+		return base64.StdEncoding.EncodeToString(encodeWitness(toSign.TxIns[0].Witness)), nil
+	}
+
+	buffer := bytes.NewBuffer(make([]byte, 0))
+	toSign.Serialize(buffer, -1, nil)
+
+	return base64.StdEncoding.EncodeToString(buffer.Bytes()), nil
+}
+
+// Verifies a base64 BIP322 signature (simple or full) of the message for the challenge.
+// Malformed signatures are reported as errors, signatures that don't check out return false.
+func VerifyBIP322(challenge transaction.Script, message string, signature string) (bool, error) {
+
+	buffer, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false, errors.New("signature is not valid base64")
+	}
+
+	// The interpreter lets spends of later witness versions through, which would make any signature valid.
+	if version, program, ok := challenge.WitnessProgram(); ok && version != 0 && !(version == 1 && len(program) == 32) {
+		return false, fmt.Errorf("verifying witness version %v signatures is not supported", version)
+	}
+
+	toSpend := BIP322ToSpend(challenge, []byte(message))
+	expected := BIP322ToSign(&toSpend)
+
+	// A simple signature is just a witness stack, try that first.
+	if witness, err := decodeWitness(buffer); err == nil {
+		if !isWitnessProgram(&challenge) {
+			return false, errors.New("simple signatures can only be used with witness programs")
+		}
+		return verifyWitness(&toSpend, &expected, witness)
+	}
+
+	toSign, err := transaction.DecodeTx(bytes.NewReader(buffer), false)
+	if err != nil {
+		return false, errors.New("signature is neither a witness nor a transaction")
+	}
+
+	reserialized := bytes.NewBuffer(make([]byte, 0))
+	toSign.Serialize(reserialized, -1, nil)
+	if !bytes.Equal(reserialized.Bytes(), buffer) {
+		return false, errors.New("signature is neither a witness nor a transaction")
+	}
+
+	if err := checkToSign(&toSign, &expected); err != nil {
+		return false, err
+	}
+
+	return toSign.VerifyInputWithPrevOuts(0, toSpend.TxOuts), nil
+}
+
+// Checks that a full format to_sign transaction has the shape BIP322 requires.
+func checkToSign(toSign *transaction.Tx, expected *transaction.Tx) error {
+
+	if len(toSign.TxIns) != 1 {
+		return errors.New("to_sign must have exactly one input")
+	}
+
+	txIn := toSign.TxIns[0]
+	if txIn.PreviousTxHash != expected.TxIns[0].PreviousTxHash || txIn.PreviousTxId != 0 {
+		return errors.New("to_sign doesn't spend the to_spend output for this message")
+	}
+
+	if len(toSign.TxOuts) != 1 || toSign.TxOuts[0].Satoshis != 0 || !bytes.Equal(toSign.TxOuts[0].ScriptPubKey.RawData, []byte{opReturn}) {
+		return errors.New("to_sign must have a single empty OP_RETURN output")
+	}
+
+	return nil
+}
+
+// Verifies a simple signature by putting the witness in to_sign and running the interpreter.
+func verifyWitness(toSpend *transaction.Tx, toSign *transaction.Tx, witness [][]byte) (bool, error) {
+	toSign.TxIns[0].Witness = witness
+	return toSign.VerifyInputWithPrevOuts(0, toSpend.TxOuts), nil
+}
+
+func encodeWitness(witness [][]byte) []byte {
+	buffer := bytes.NewBuffer(make([]byte, 0))
+	utility.WriteVarInt(buffer, uint64(len(witness)))
+	for _, item := range witness {
+		utility.WriteVarInt(buffer, uint64(len(item)))
+		buffer.Write(item)
+	}
+	return buffer.Bytes()
+}
+
+// Decodes a serialized witness stack (item count followed by length prefixed items),
+// failing unless the whole buffer is used.
+func decodeWitness(buffer []byte) ([][]byte, error) {
+	reader := bytes.NewReader(buffer)
+
+	count, err := readCompactSize(reader)
+	if err != nil || count > uint64(reader.Len()) {
+		return nil, errors.New("invalid witness item count")
+	}
+
+	witness := make([][]byte, count)
+	for i := range witness {
+		length, err := readCompactSize(reader)
+		if err != nil || length > uint64(reader.Len()) {
+			return nil, errors.New("invalid witness item length")
+		}
+
+		witness[i] = make([]byte, length)
+		io.ReadFull(reader, witness[i])
+	}
+
+	if reader.Len() != 0 {
+		return nil, errors.New("trailing data after the witness")
+	}
+
+	return witness, nil
+}
+
+// utility.ReadVarInt doesn't report short reads, which matters when probing untrusted data.
+func readCompactSize(reader *bytes.Reader) (uint64, error) {
+	first, err := reader.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	size := 0
+	switch first {
+	case 0xfd:
+		size = 2
+	case 0xfe:
+		size = 4
+	case 0xff:
+		size = 8
+	default:
+		return uint64(first), nil
+	}
+
+	buffer := make([]byte, 8)
+	if _, err := io.ReadFull(reader, buffer[:size]); err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint64(buffer), nil
+}
+
+func isWitnessProgram(script *transaction.Script) bool {
+	_, _, ok := script.WitnessProgram()
+	return ok
+}
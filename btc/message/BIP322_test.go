@@ -0,0 +1,351 @@
+package message
+
+import (
+	"bitcoin-go/btc/transaction"
+	"bitcoin-go/ecc"
+	"bitcoin-go/utility"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// scriptPubKey of bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l, the address used by the BIP322 vectors.
+const bip322VectorChallenge = "00142b05d564e6a7a33c087f16e0f730d1440123799d"
+
+func TestBIP322MessageHash(t *testing.T) {
+
+	testCases := map[string]string{
+		"":            "c90c269c4f8fcbe6880f72a721ddfbf1914268a794cbb21cfafee13770ae19f1",
+		"Hello World": "f0eb03b1a75ac6d9847f55c624a99169b5dccba2a31f5b23bea77ba270de0a7a",
+	}
+
+	for message, expected := range testCases {
+		if actual := hex.EncodeToString(BIP322MessageHash([]byte(message))); actual != expected {
+			t.Errorf("%q: expected %v, got %v", message, expected, actual)
+		}
+	}
+}
+
+func TestBIP322Transactions(t *testing.T) {
+
+	raw, _ := hex.DecodeString(bip322VectorChallenge)
+	challenge := transaction.Script{RawData: raw}
+
+	testCases := []struct {
+		message string
+		toSpend string
+		toSign  string
+	}{
+		{"", "c5680aa69bb8d860bf82d4e9cd3504b55dde018de765a91bb566283c545a99a7", "1e9654e951a5ba44c8604c4de6c67fd78a27e81dcadcfe1edf638ba3aaebaed6"},
+		{"Hello World", "b79d196740ad5217771c1098fc4a4b51e0535c32236c71f1ea4d61a2d603352b", "88737ae86f2077145f93cc4b153ae9a1cb8d56afa511988c149c5c8c9d93bddf"},
+	}
+
+	for _, testCase := range testCases {
+		toSpend := BIP322ToSpend(challenge, []byte(testCase.message))
+		if toSpend.Id() != testCase.toSpend {
+			t.Errorf("%q: expected to_spend %v, got %v", testCase.message, testCase.toSpend, toSpend.Id())
+		}
+
+		toSign := BIP322ToSign(&toSpend)
+		if toSign.Id() != testCase.toSign {
+			t.Errorf("%q: expected to_sign %v, got %v", testCase.message, testCase.toSign, toSign.Id())
+		}
+	}
+}
+
+func TestBIP322LegacyFull(t *testing.T) {
+
+	key := ecc.NewPrivateKey(utility.HexStringToBigInt(testSecret))
+	pub := ecc.G.ScalarMultiply(key.Secret)
+
+	for _, compressed := range []bool{true, false} {
+		challenge := p2pkhScript(pub.Hash160(compressed))
+
+		signature, err := SignBIP322(&key, challenge, "Hello World", BIP322Full)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := VerifyBIP322(challenge, "Hello World", signature); err != nil || !ok {
+			t.Errorf("Signature didn't verify (compressed: %v, %v)", compressed, err)
+		}
+
+		if ok, _ := VerifyBIP322(challenge, "Hello World!", signature); ok {
+			t.Errorf("Signature verified for a different message (compressed: %v)", compressed)
+		}
+
+		other := p2pkhScript(pub.Hash160(!compressed))
+		if ok, _ := VerifyBIP322(other, "Hello World", signature); ok {
+			t.Errorf("Signature verified for a different challenge (compressed: %v)", compressed)
+		}
+	}
+
+	challenge := p2pkhScript(pub.Hash160(true))
+	if _, err := SignBIP322(&key, challenge, "Hello World", BIP322Simple); err == nil {
+		t.Errorf("Simple signatures can't be made for legacy scripts")
+	}
+
+	otherKey := ecc.NewPrivateKey(utility.HexStringToBigInt("01"))
+	if _, err := SignBIP322(&otherKey, challenge, "Hello World", BIP322Full); err == nil {
+		t.Errorf("Signed for a challenge that belongs to another key")
+	}
+}
+
+func TestBIP322Malformed(t *testing.T) {
+
+	key := ecc.NewPrivateKey(utility.HexStringToBigInt(testSecret))
+	pub := ecc.G.ScalarMultiply(key.Secret)
+	challenge := p2pkhScript(pub.Hash160(true))
+
+	signature, _ := SignBIP322(&key, challenge, "Hello World", BIP322Full)
+	full, _ := base64.StdEncoding.DecodeString(signature)
+
+	for name, signature := range map[string]string{
+		"not base64":       "***",
+		"truncated":        base64.StdEncoding.EncodeToString(full[:len(full)-10]),
+		"trailing data":    base64.StdEncoding.EncodeToString(append(append([]byte{}, full...), 0x00)),
+		"simple for P2PKH": base64.StdEncoding.EncodeToString([]byte{0x01, 0x01, 0x01}),
+	} {
+		if ok, err := VerifyBIP322(challenge, "Hello World", signature); ok || err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
+
+// Scripts that can't be decoded or run fail verification rather than crashing the verifier.
+func TestBIP322MalformedScripts(t *testing.T) {
+
+	key := ecc.NewPrivateKey(utility.HexStringToBigInt(testSecret))
+	pub := ecc.G.ScalarMultiply(key.Secret)
+	p2pkh := p2pkhScript(pub.Hash160(true))
+
+	p2sh := transaction.Script{}
+	p2sh.AddOpCode(0xa9) // OP_HASH160
+	p2sh.AddData(pub.Hash160(true))
+	p2sh.AddOpCode(0x87) // OP_EQUAL
+
+	testCases := []struct {
+		name      string
+		challenge transaction.Script
+		scriptSig []byte
+	}{
+		{"push without a length", p2pkh, []byte{0x4c}},
+		{"push past the end", p2pkh, []byte{0x4e, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{"undefined op code", p2pkh, []byte{0xff}},
+		{"P2SH without a redeem script", p2sh, []byte{}},
+		{"P2SH ending in an op code", p2sh, []byte{0x51}},
+	}
+
+	for _, testCase := range testCases {
+		toSpend := BIP322ToSpend(testCase.challenge, []byte("Hello World"))
+		toSign := BIP322ToSign(&toSpend)
+		toSign.TxIns[0].ScriptSignature = &transaction.Script{RawData: testCase.scriptSig}
+
+		buffer := bytes.NewBuffer(make([]byte, 0))
+		toSign.Serialize(buffer, -1, nil)
+
+		if ok, _ := VerifyBIP322(testCase.challenge, "Hello World", base64.StdEncoding.EncodeToString(buffer.Bytes())); ok {
+			t.Errorf("%v: signature verified", testCase.name)
+		}
+	}
+}
+
+// Signing and verifying don't share any state, so they can run in parallel.
+func TestBIP322Concurrent(t *testing.T) {
+
+	raw, _ := hex.DecodeString(bip322VectorChallenge)
+	challenge := transaction.Script{RawData: raw}
+	key, _, _, err := ecc.ParseWIF("L3VFeEujGtevx9w18HD1fhRbCH67Az2dpCymeRE1SoPK6XQtaN2k")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		go func(message string) {
+			signature, err := SignBIP322(&key, challenge, message, BIP322Simple)
+			if err == nil {
+				var ok bool
+				if ok, err = VerifyBIP322(challenge, message, signature); err == nil && !ok {
+					err = fmt.Errorf("%q: signature didn't verify", message)
+				}
+			}
+			errs <- err
+		}(fmt.Sprintf("message %v", i))
+	}
+
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestBIP322SegWit(t *testing.T) {
+
+	raw, _ := hex.DecodeString(bip322VectorChallenge)
+	challenge := transaction.Script{RawData: raw}
+
+	// Signatures from the BIP322 test vectors.
+	vectors := map[string]string{
+		"":            "AkcwRAIgM2gBAQqvZX15ZiysmKmQpDrG83avLIT492QBzLnQIxYCIBaTpOaD20qRlEylyxFSeEA2ba9YOixpX8z46TSDtS40ASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI=",
+		"Hello World": "AkcwRAIgZRfIY3p7/DoVTty6YZbWS71bc5Vct9p9Fia83eRmw2QCICK/ENGfwLtptFluMGs2KsqoNSk89pO7F29zJLUx9a/sASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI=",
+	}
+
+	for message, signature := range vectors {
+		if ok, err := VerifyBIP322(challenge, message, signature); err != nil || !ok {
+			t.Errorf("%q: signature didn't verify (%v)", message, err)
+		}
+		if ok, _ := VerifyBIP322(challenge, message+"!", signature); ok {
+			t.Errorf("%q: signature verified for a different message", message)
+		}
+	}
+
+	key, _, _, err := ecc.ParseWIF("L3VFeEujGtevx9w18HD1fhRbCH67Az2dpCymeRE1SoPK6XQtaN2k")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []BIP322Format{BIP322Simple, BIP322Full} {
+		signature, err := SignBIP322(&key, challenge, "Hello World", format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := VerifyBIP322(challenge, "Hello World", signature); err != nil || !ok {
+			t.Errorf("Format %v: signature didn't verify (%v)", format, err)
+		}
+		if ok, _ := VerifyBIP322(challenge, "Hello World!", signature); ok {
+			t.Errorf("Format %v: signature verified for a different message", format)
+		}
+	}
+
+	otherKey := ecc.NewPrivateKey(utility.HexStringToBigInt("01"))
+	if _, err := SignBIP322(&otherKey, challenge, "Hello World", BIP322Simple); err == nil {
+		t.Errorf("Signed for a challenge that belongs to another key")
+	}
+}
+
+func TestBIP322P2WSH(t *testing.T) {
+
+	key := ecc.NewPrivateKey(utility.HexStringToBigInt(testSecret))
+	pub := ecc.G.ScalarMultiply(key.Secret)
+	otherKey := ecc.NewPrivateKey(utility.HexStringToBigInt("01"))
+	otherPub := ecc.G.ScalarMultiply(otherKey.Secret)
+
+	// <pubkey> OP_CHECKSIG and a 1-of-2 multisig with the same key second.
+	checkSig := transaction.Script{}
+	checkSig.AddData(pub.ToSEC(true))
+	checkSig.AddOpCode(0xac) // OP_CHECKSIG
+
+	multiSig := transaction.Script{}
+	multiSig.AddOpCode(0x51) // OP_1
+	multiSig.AddData(otherPub.ToSEC(true))
+	multiSig.AddData(pub.ToSEC(true))
+	multiSig.AddOpCode(0x52) // OP_2
+	multiSig.AddOpCode(0xae) // OP_CHECKMULTISIG
+
+	for name, witnessScript := range map[string]transaction.Script{"checksig": checkSig, "multisig": multiSig} {
+		challenge := p2wshScript(witnessScript)
+
+		// There's no signer for P2WSH, so the witness is made by hand.
+		sign := func(message string, signer *ecc.PrivateKey) [][]byte {
+			toSpend := BIP322ToSpend(challenge, []byte(message))
+			toSign := BIP322ToSign(&toSpend)
+			z := toSign.SigHashSegWit(0, &witnessScript, 0, transaction.SIGHASH_ALL)
+			signature := signer.Sign(new(big.Int).SetBytes(z))
+			sig := append(signature.ToDER(), transaction.SIGHASH_ALL)
+			if name == "multisig" {
+				return [][]byte{{}, sig, witnessScript.RawData}
+			}
+			return [][]byte{sig, witnessScript.RawData}
+		}
+
+		witness := sign("Hello World", &key)
+		simple := base64.StdEncoding.EncodeToString(encodeWitness(witness))
+		if ok, err := VerifyBIP322(challenge, "Hello World", simple); err != nil || !ok {
+			t.Errorf("%v: signature didn't verify (%v)", name, err)
+		}
+
+		toSpend := BIP322ToSpend(challenge, []byte("Hello World"))
+		toSign := BIP322ToSign(&toSpend)
+		toSign.TxIns[0].Witness = witness
+		buffer := bytes.NewBuffer(make([]byte, 0))
+		toSign.Serialize(buffer, -1, nil)
+		if ok, err := VerifyBIP322(challenge, "Hello World", base64.StdEncoding.EncodeToString(buffer.Bytes())); err != nil || !ok {
+			t.Errorf("%v: full signature didn't verify (%v)", name, err)
+		}
+
+		if ok, _ := VerifyBIP322(challenge, "Hello World!", simple); ok {
+			t.Errorf("%v: signature verified for a different message", name)
+		}
+
+		// The multisig script also takes the other key.
+		wrongKey := base64.StdEncoding.EncodeToString(encodeWitness(sign("Hello World", &otherKey)))
+		if ok, _ := VerifyBIP322(challenge, "Hello World", wrongKey); ok != (name == "multisig") {
+			t.Errorf("%v: signature by the other key verified: %v", name, ok)
+		}
+
+		// The witness script has to hash to the challenge's program.
+		other := p2wshScript(p2pkhScript(pub.Hash160(true)))
+		if ok, _ := VerifyBIP322(other, "Hello World", simple); ok {
+			t.Errorf("%v: signature verified for a different challenge", name)
+		}
+
+		if _, err := SignBIP322(&key, challenge, "Hello World", BIP322Simple); err == nil {
+			t.Errorf("%v: signed a P2WSH challenge without its witness script", name)
+		}
+	}
+}
+
+func TestBIP322Taproot(t *testing.T) {
+
+	address, err := transaction.ParseAddress("bc1ppv609nr0vr25u07u95waq5lucwfm6tde4nydujnu8npg4q75mr5sxq8lt3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge := address.ScriptPubKey()
+
+	// The P2TR signature from the BIP322 test vectors, it has an explicit SIGHASH_ALL.
+	signature := "AUHd69PrJQEv+oKTfZ8l+WROBHuy9HKrbFCJu7U1iK2iiEy1vMU5EfMtjc+VSHM7aU0SDbak5IUZRVno2P5mjSafAQ=="
+	if ok, err := VerifyBIP322(challenge, "Hello World", signature); err != nil || !ok {
+		t.Errorf("Signature didn't verify (%v)", err)
+	}
+	if ok, _ := VerifyBIP322(challenge, "Hello World!", signature); ok {
+		t.Errorf("Signature verified for a different message")
+	}
+
+	key, _, _, err := ecc.ParseWIF("L3VFeEujGtevx9w18HD1fhRbCH67Az2dpCymeRE1SoPK6XQtaN2k")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []BIP322Format{BIP322Simple, BIP322Full} {
+		signature, err := SignBIP322(&key, challenge, "Hello World", format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := VerifyBIP322(challenge, "Hello World", signature); err != nil || !ok {
+			t.Errorf("Format %v: signature didn't verify (%v)", format, err)
+		}
+	}
+}
+
+func p2wshScript(witnessScript transaction.Script) transaction.Script {
+	script := transaction.Script{}
+	script.AddOpCode(0x00) // OP_0
+	script.AddData(utility.Sha256(witnessScript.RawData))
+	return script
+}
+
+func p2pkhScript(h160 []byte) transaction.Script {
+	script := transaction.Script{}
+	script.AddOpCode(0x76) // OP_DUP
+	script.AddOpCode(0xa9) // OP_HASH160
+	script.AddData(h160)
+	script.AddOpCode(0x88) // OP_EQUALVERIFY
+	script.AddOpCode(0xac) // OP_CHECKSIG
+	return script
+}
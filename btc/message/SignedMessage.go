@@ -0,0 +1,141 @@
+package message
+
+import (
+	"bitcoin-go/ecc"
+	"bitcoin-go/utility"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// Bitcoin Signed Messages (BIP137), the format used by Bitcoin Core's signmessage and most wallets.
+// The signature is a base64 encoded compact recoverable signature whose header byte records which
+// kind of address the key belongs to:
+//
+//	27-30: P2PKH, uncompressed key
+//	31-34: P2PKH, compressed key
+//	35-38: P2SH-P2WPKH
+//	39-42: P2WPKH
+//
+// The low two bits of each range are the recovery id.
+
+const messagePrefix = "Bitcoin Signed Message:\n"
+
+type AddressType int
+
+const (
+	P2PKHUncompressed AddressType = iota
+	P2PKHCompressed
+	P2SHP2WPKH
+	P2WPKH
+)
+
+const headerBase = 27
+
+// The double-SHA256 of the prefixed message, which is what actually gets signed.
+func MessageHash(message string) *big.Int {
+	var buffer bytes.Buffer
+
+	utility.WriteVarInt(&buffer, uint64(len(messagePrefix)))
+	buffer.WriteString(messagePrefix)
+	utility.WriteVarInt(&buffer, uint64(len(message)))
+	buffer.WriteString(message)
+
+	return new(big.Int).SetBytes(utility.Hash256(buffer.Bytes()))
+}
+
+// Signs the message and returns the base64 signature for the given kind of address.
+func SignMessage(key *ecc.PrivateKey, message string, addressType AddressType) (string, error) {
+	if addressType < P2PKHUncompressed || addressType > P2WPKH {
+		return "", errors.New("unknown address type")
+	}
+
+	sig := key.SignRecoverable(MessageHash(message))
+
+	buffer := sig.SerializeCompact(false)
+	buffer[0] = headerBase + byte(addressType)*4 + sig.RecoveryId
+
+	return base64.StdEncoding.EncodeToString(buffer), nil
+}
+
+// Verifies a base64 signature over the message against an address. A malformed signature is
+// reported as an error; a well formed signature from a different key just returns false.
+//
+// Some wallets (Electrum among them) sign for SegWit addresses with the P2PKH compressed header,
+// so signatures with any compressed header are accepted for all three compressed address types.
+func VerifyMessage(address string, message string, signature string) (bool, error) {
+
+	buffer, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false, errors.New("signature is not valid base64")
+	}
+
+	if len(buffer) != 65 {
+		return false, errors.New("signature must be 65 bytes")
+	}
+
+	header := buffer[0]
+	if header < headerBase || header >= headerBase+16 {
+		return false, errors.New("signature has an invalid header byte")
+	}
+
+	addressType := AddressType((header - headerBase) / 4)
+	recoveryId := (header - headerBase) & 3
+
+	// Re-use the generic compact parser, which only knows about the P2PKH headers.
+	compact := append([]byte{}, buffer...)
+	compact[0] = headerBase + recoveryId
+	sig, _, err := ecc.ParseCompactSignature(compact)
+	if err != nil {
+		return false, err
+	}
+
+	hash := MessageHash(message)
+	pub, err := ecc.RecoverPublicKey(hash, sig)
+	if err != nil {
+		return false, nil
+	}
+
+	if !pub.Verify(hash, sig.Signature) {
+		return false, nil
+	}
+
+	candidates := []AddressType{addressType}
+	if addressType != P2PKHUncompressed {
+		candidates = []AddressType{P2PKHCompressed, P2SHP2WPKH, P2WPKH}
+	}
+
+	for _, candidate := range candidates {
+		for _, testnet := range []bool{false, true} {
+			if addressMatches(address, messageAddress(&pub, candidate, testnet), candidate) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+func addressMatches(address string, expected string, addressType AddressType) bool {
+	// Bech32 addresses may be written in upper case.
+	if addressType == P2WPKH {
+		return strings.ToLower(address) == expected
+	}
+	return address == expected
+}
+
+func messageAddress(pub *ecc.Point, addressType AddressType, testnet bool) string {
+	switch addressType {
+	case P2PKHUncompressed:
+		return pub.Address(false, testnet)
+	case P2PKHCompressed:
+		return pub.Address(true, testnet)
+	case P2SHP2WPKH:
+		redeemScript := append([]byte{0x00, 0x14}, pub.Hash160(true)...)
+		return utility.H160ToP2SHAddress(utility.Hash160(redeemScript), testnet)
+	default:
+		return pub.P2WPKHAddress(testnet)
+	}
+}
@@ -0,0 +1,107 @@
+package message
+
+import (
+	"bitcoin-go/ecc"
+	"bitcoin-go/utility"
+	"math/big"
+	"testing"
+)
+
+// Vectors from bitcoinjs-message, key L4rK1yDtCWekvXuE6oXD9jCYfFNV2cWRpVuPLBcCU2z8TrisoyY1.
+const testSecret = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+const testMessage = "This is an example of a signed message."
+
+func TestSignMessageVectors(t *testing.T) {
+
+	key := ecc.NewPrivateKey(utility.HexStringToBigInt(testSecret))
+
+	testCases := []struct {
+		addressType AddressType
+		address     string
+		signature   string
+	}{
+		{P2PKHUncompressed, "1HZwkjkeaoZfTSaJxDw6aKkxp45agDiEzN", "G9L5yLFjti0QTHhPyFrZCT1V/MMnBtXKmoiKDZ78NDBjERki6ZTQZdSMCtkgoNmp17By9ItJr8o7ChX0XxY91nk="},
+		{P2PKHCompressed, "1F3sAm6ZtwLAUnj7d38pGFxtP3RVEvtsbV", "H9L5yLFjti0QTHhPyFrZCT1V/MMnBtXKmoiKDZ78NDBjERki6ZTQZdSMCtkgoNmp17By9ItJr8o7ChX0XxY91nk="},
+		{P2SHP2WPKH, "3DnW8JGpPViEZdpqat8qky1zc26EKbXnmM", "I9L5yLFjti0QTHhPyFrZCT1V/MMnBtXKmoiKDZ78NDBjERki6ZTQZdSMCtkgoNmp17By9ItJr8o7ChX0XxY91nk="},
+		{P2WPKH, "bc1qngw83fg8dz0k749cg7k3emc7v98wy0c74dlrkd", "J9L5yLFjti0QTHhPyFrZCT1V/MMnBtXKmoiKDZ78NDBjERki6ZTQZdSMCtkgoNmp17By9ItJr8o7ChX0XxY91nk="},
+	}
+
+	for _, testCase := range testCases {
+		signature, err := SignMessage(&key, testMessage, testCase.addressType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if signature != testCase.signature {
+			t.Errorf("%v: expected %v, got %v", testCase.address, testCase.signature, signature)
+		}
+
+		ok, err := VerifyMessage(testCase.address, testMessage, testCase.signature)
+		if err != nil || !ok {
+			t.Errorf("%v: signature didn't verify (%v)", testCase.address, err)
+		}
+
+		ok, _ = VerifyMessage(testCase.address, testMessage+"!", testCase.signature)
+		if ok {
+			t.Errorf("%v: signature verified for a different message", testCase.address)
+		}
+	}
+}
+
+func TestVerifyMessageElectrumHeader(t *testing.T) {
+
+	// Electrum signs for SegWit addresses using the P2PKH compressed header.
+	signature := "H9L5yLFjti0QTHhPyFrZCT1V/MMnBtXKmoiKDZ78NDBjERki6ZTQZdSMCtkgoNmp17By9ItJr8o7ChX0XxY91nk="
+	for _, address := range []string{"3DnW8JGpPViEZdpqat8qky1zc26EKbXnmM", "BC1QNGW83FG8DZ0K749CG7K3EMC7V98WY0C74DLRKD"} {
+		if ok, err := VerifyMessage(address, testMessage, signature); err != nil || !ok {
+			t.Errorf("%v: signature didn't verify (%v)", address, err)
+		}
+	}
+
+	// But an uncompressed key can't be used for any of those.
+	uncompressed := "G9L5yLFjti0QTHhPyFrZCT1V/MMnBtXKmoiKDZ78NDBjERki6ZTQZdSMCtkgoNmp17By9ItJr8o7ChX0XxY91nk="
+	if ok, _ := VerifyMessage("1F3sAm6ZtwLAUnj7d38pGFxtP3RVEvtsbV", testMessage, uncompressed); ok {
+		t.Errorf("Uncompressed signature verified against a compressed address")
+	}
+}
+
+func TestSignMessageRoundTrip(t *testing.T) {
+
+	key := ecc.NewPrivateKey(new(big.Int).SetBytes(utility.RandomData(32)))
+	pub := ecc.G.ScalarMultiply(key.Secret)
+	other := ecc.G.ScalarMultiply(big.NewInt(42))
+
+	for _, addressType := range []AddressType{P2PKHUncompressed, P2PKHCompressed, P2SHP2WPKH, P2WPKH} {
+		for _, testnet := range []bool{false, true} {
+			signature, err := SignMessage(&key, "hello", addressType)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			address := messageAddress(&pub, addressType, testnet)
+			if ok, err := VerifyMessage(address, "hello", signature); err != nil || !ok {
+				t.Errorf("%v: signature didn't verify (%v)", address, err)
+			}
+
+			wrongAddress := messageAddress(&other, addressType, testnet)
+			if ok, _ := VerifyMessage(wrongAddress, "hello", signature); ok {
+				t.Errorf("%v: verified against the wrong address", wrongAddress)
+			}
+		}
+	}
+}
+
+func TestVerifyMessageMalformed(t *testing.T) {
+
+	address := "1F3sAm6ZtwLAUnj7d38pGFxtP3RVEvtsbV"
+
+	for _, signature := range []string{
+		"not base64!",
+		"H9L5yLFjti0QTHhPyFrZCT1V/MMnBtXKmoiKDZ78NDBj",
+		// Header byte 43 is out of range.
+		"K9L5yLFjti0QTHhPyFrZCT1V/MMnBtXKmoiKDZ78NDBjERki6ZTQZdSMCtkgoNmp17By9ItJr8o7ChX0XxY91nk=",
+	} {
+		if _, err := VerifyMessage(address, testMessage, signature); err == nil {
+			t.Errorf("Expected an error for %v", signature)
+		}
+	}
+}
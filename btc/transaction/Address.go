@@ -0,0 +1,198 @@
+package transaction
+
+import (
+	"bitcoin-go/utility"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Addresses are a human friendly encoding of a scriptPubKey. Legacy ones (P2PKH and P2SH) are
+// Base58Check encoded hashes with a version byte that also tells the network, SegWit ones encode a
+// witness version and program in Bech32 (version 0) or Bech32m (version 1 and up), with "bc" or
+// "tb" as the human readable part.
+
+type AddressType int
+
+const (
+	P2PKH AddressType = iota
+	P2SH
+	P2WPKH
+	P2WSH
+	P2TR
+	WitnessUnknown // A witness version or program length without a defined meaning yet
+)
+
+func (t AddressType) String() string {
+	switch t {
+	case P2PKH:
+		return "P2PKH"
+	case P2SH:
+		return "P2SH"
+	case P2WPKH:
+		return "P2WPKH"
+	case P2WSH:
+		return "P2WSH"
+	case P2TR:
+		return "P2TR"
+	default:
+		return "WitnessUnknown"
+	}
+}
+
+// Base58 version bytes.
+const (
+	p2pkhMainnetVersion = 0x00
+	p2shMainnetVersion  = 0x05
+	p2pkhTestnetVersion = 0x6f
+	p2shTestnetVersion  = 0xc4
+)
+
+type Address struct {
+	addressType    AddressType
+	testnet        bool
+	witnessVersion byte
+	program        []byte // The HASH160 for legacy addresses, the witness program for SegWit ones
+}
+
+// Parses and validates any address. Bech32 addresses may be upper case.
+func ParseAddress(s string) (Address, error) {
+
+	lower := strings.ToLower(s)
+	for _, hrp := range []string{"bc", "tb"} {
+		if strings.HasPrefix(lower, hrp+"1") {
+			version, program, err := utility.DecodeSegWitAddress(hrp, s)
+			if err != nil {
+				return Address{}, err
+			}
+			return newWitnessAddress(version, program, hrp == "tb"), nil
+		}
+	}
+
+	payload, err := utility.DecodeBase58Checksum(s)
+	if err != nil {
+		return Address{}, err
+	}
+	if len(payload) != 21 {
+		return Address{}, fmt.Errorf("address payload must be 21 bytes, not %v", len(payload))
+	}
+
+	address := Address{program: payload[1:]}
+	switch payload[0] {
+	case p2pkhMainnetVersion:
+		address.addressType = P2PKH
+	case p2shMainnetVersion:
+		address.addressType = P2SH
+	case p2pkhTestnetVersion:
+		address.addressType, address.testnet = P2PKH, true
+	case p2shTestnetVersion:
+		address.addressType, address.testnet = P2SH, true
+	default:
+		return Address{}, fmt.Errorf("unknown address version byte 0x%02x", payload[0])
+	}
+
+	return address, nil
+}
+
+func IsValidAddress(s string) bool {
+	_, err := ParseAddress(s)
+	return err == nil
+}
+
+func newWitnessAddress(version byte, program []byte, testnet bool) Address {
+	address := Address{addressType: WitnessUnknown, testnet: testnet, witnessVersion: version, program: program}
+	switch {
+	case version == 0 && len(program) == 20:
+		address.addressType = P2WPKH
+	case version == 0 && len(program) == 32:
+		address.addressType = P2WSH
+	case version == 1 && len(program) == 32:
+		address.addressType = P2TR
+	}
+	return address
+}
+
+// Works out the address a scriptPubKey pays to. Scripts that don't match any of the address
+// templates (bare multisig, OP_RETURN, ...) have no address and return an error.
+func AddressFromScript(script Script, testnet bool) (Address, error) {
+
+	raw := script.RawData
+
+	switch {
+	case len(raw) == 25 && raw[0] == 0x76 && raw[1] == 0xa9 && raw[2] == 0x14 && raw[23] == 0x88 && raw[24] == 0xac:
+		return Address{addressType: P2PKH, testnet: testnet, program: raw[3:23]}, nil
+
+	case len(raw) == 23 && raw[0] == 0xa9 && raw[1] == 0x14 && raw[22] == 0x87:
+		return Address{addressType: P2SH, testnet: testnet, program: raw[2:22]}, nil
+	}
+
+	if version, program, ok := script.WitnessProgram(); ok {
+		if version == 0 && len(program) != 20 && len(program) != 32 {
+			return Address{}, fmt.Errorf("version 0 witness program must be 20 or 32 bytes, not %v", len(program))
+		}
+		return newWitnessAddress(version, program, testnet), nil
+	}
+
+	return Address{}, errors.New("script doesn't match any address type")
+}
+
+func (a Address) Type() AddressType {
+	return a.addressType
+}
+
+func (a Address) IsTestnet() bool {
+	return a.testnet
+}
+
+func (a Address) IsWitness() bool {
+	return a.addressType != P2PKH && a.addressType != P2SH
+}
+
+func (a Address) WitnessVersion() byte {
+	return a.witnessVersion
+}
+
+// The HASH160 of a legacy address or the witness program of a SegWit one.
+func (a Address) Program() []byte {
+	return a.program
+}
+
+// The address in its canonical (lower case for Bech32) form.
+func (a Address) String() string {
+	switch a.addressType {
+	case P2PKH:
+		return utility.H160ToP2PKHAddress(a.program, a.testnet)
+	case P2SH:
+		return utility.H160ToP2SHAddress(a.program, a.testnet)
+	default:
+		return utility.EncodeSegWitAddress(utility.IIF(a.testnet, "tb", "bc").(string), a.witnessVersion, a.program)
+	}
+}
+
+// The scriptPubKey of outputs paying to this address.
+func (a Address) ScriptPubKey() Script {
+	script := Script{}
+
+	switch a.addressType {
+	case P2PKH:
+		script.AddOpCode(0x76) // OP_DUP
+		script.AddOpCode(0xa9) // OP_HASH160
+		script.AddData(a.program)
+		script.AddOpCode(0x88) // OP_EQUALVERIFY
+		script.AddOpCode(0xac) // OP_CHECKSIG
+	case P2SH:
+		script.AddOpCode(0xa9) // OP_HASH160
+		script.AddData(a.program)
+		script.AddOpCode(0x87) // OP_EQUAL
+	default:
+		// OP_0 or OP_1 - OP_16, followed by the program.
+		if a.witnessVersion == 0 {
+			script.AddOpCode(0x00)
+		} else {
+			script.AddOpCode(0x50 + a.witnessVersion)
+		}
+		script.AddData(a.program)
+	}
+
+	return script
+}
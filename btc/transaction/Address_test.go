@@ -0,0 +1,104 @@
+package transaction
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestParseAddress(t *testing.T) {
+
+	testCases := []struct {
+		address      string
+		addressType  AddressType
+		testnet      bool
+		scriptPubKey string
+	}{
+		{"1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH", P2PKH, false, "76a914751e76e8199196d454941c45d1b3a323f1433bd688ac"},
+		{"mnrVtF8DWjMu839VW3rBfgYaAfKk8983Xf", P2PKH, true, "76a914507b27411ccf7f16f10297de6cef3f291623eddf88ac"},
+		{"1111111111111111111114oLvT2", P2PKH, false, "76a914000000000000000000000000000000000000000088ac"},
+		{"3CLoMMyuoDQTPRD3XYZtCvgvkadrAdvdXh", P2SH, false, "a91474d691da1574e6b3c192ecfb52cc8984ee7b6c5687"},
+		{"2N3u1R6uwQfuobCqbCgBkpsgBxvr1tZpe7B", P2SH, true, "a91474d691da1574e6b3c192ecfb52cc8984ee7b6c5687"},
+		{"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", P2WPKH, false, "0014751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", P2WSH, true, "00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262"},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", P2TR, false, "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+		{"tb1pqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesf3hn0c", P2TR, true, "5120000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433"},
+		{"bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kt5nd6y", WitnessUnknown, false, "5128751e76e8199196d454941c45d1b3a323f1433bd6751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"BC1SW50QGDZ25J", WitnessUnknown, false, "6002751e"},
+	}
+
+	for _, testCase := range testCases {
+		address, err := ParseAddress(testCase.address)
+		if err != nil {
+			t.Errorf("%v: %v", testCase.address, err)
+			continue
+		}
+
+		if address.Type() != testCase.addressType {
+			t.Errorf("%v: expected type %v, got %v", testCase.address, testCase.addressType, address.Type())
+		}
+		if address.IsTestnet() != testCase.testnet {
+			t.Errorf("%v: expected testnet %v", testCase.address, testCase.testnet)
+		}
+
+		script := address.ScriptPubKey()
+		if actual := hex.EncodeToString(script.RawData); actual != testCase.scriptPubKey {
+			t.Errorf("%v: expected scriptPubKey %v, got %v", testCase.address, testCase.scriptPubKey, actual)
+		}
+
+		// And back again.
+		fromScript, err := AddressFromScript(script, testCase.testnet)
+		if err != nil {
+			t.Errorf("%v: %v", testCase.address, err)
+			continue
+		}
+		expected := testCase.address
+		if address.IsWitness() {
+			expected = strings.ToLower(expected)
+		}
+		if fromScript.String() != expected || address.String() != expected {
+			t.Errorf("%v: round tripped to %v and %v", testCase.address, fromScript.String(), address.String())
+		}
+		if fromScript.Type() != testCase.addressType {
+			t.Errorf("%v: script classified as %v", testCase.address, fromScript.Type())
+		}
+	}
+}
+
+func TestParseAddressInvalid(t *testing.T) {
+
+	invalid := []string{
+		"",
+		"1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMJ", // Bad checksum
+		"1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAM0", // Not Base58
+		"5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTJ",                                                             // A WIF private key
+		"xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8", // Too long
+		"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5",                                                                      // Bad checksum
+		"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd",                                                  // Bech32 instead of Bech32m
+		"tc1qw508d6qejxtdg4y5r3zarvary0c5xw7kg3g4ty",                                                                      // Unknown HRP
+		"BC1QR508D6QEJXTDG4Y5R3ZARVARYV98GJ9P",                                                                            // Bad version 0 program length
+	}
+
+	for _, s := range invalid {
+		if IsValidAddress(s) {
+			t.Errorf("%q should be invalid", s)
+		}
+	}
+}
+
+func TestAddressFromScriptInvalid(t *testing.T) {
+
+	testCases := []string{
+		"6a0568656c6c6f", // OP_RETURN
+		"5121030000000000000000000000000000000000000000000000000000000000000000000051ae", // Bare multisig
+		"0015751e76e8199196d454941c45d1b3a323f1433bd600",                                 // Version 0 with a 21 byte program
+		"76a914751e76e8199196d454941c45d1b3a323f1433bd688",                               // Truncated P2PKH
+	}
+
+	for _, testCase := range testCases {
+		raw, _ := hex.DecodeString(testCase)
+		if address, err := AddressFromScript(Script{RawData: raw}, false); err == nil {
+			t.Errorf("%v: expected an error, got %v", testCase, address)
+		}
+	}
+}
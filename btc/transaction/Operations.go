@@ -1,7 +1,7 @@
 package transaction
 
 import (
-	"bitcoin-go/utility"
+	"encoding/binary"
 	"fmt"
 	"io"
 )
@@ -25,6 +25,10 @@ func (op GenericOperation) GetOpName() string {
 	return op.OpName
 }
 func (op GenericOperation) Execute(context *ExecutionContext) bool {
+	// Undefined op codes fail the script when they're executed.
+	if op.OpFxn == nil {
+		return false
+	}
 	return op.OpFxn(context)
 }
 
@@ -73,7 +77,7 @@ func NewOperation(reader io.Reader) (Operation, error) {
 			return nil, err
 		}
 		data = b
-	} else if opCode == 0x49 {
+	} else if opCode == 0x4f {
 		data = encodeNumber(-1)
 	} else if opCode >= 0x51 && opCode <= 0x60 {
 		num := opCode - 0x50
@@ -92,23 +96,25 @@ func extractScriptData(op byte, reader io.Reader) ([]byte, error) {
 
 	var dataLength uint32 = (uint32)(op)
 
-	if op == 0x4c {
-		dataLength = (uint32)(utility.ReadByte(reader))
-	} else if op == 0x4d {
-		dataLength = uint32(utility.ReadUInt16(reader, true))
-	} else if op == 0x4e {
-		dataLength = utility.ReadUint32(reader, true)
+	// OP_PUSHDATA1, 2 and 4 are followed by the length. A missing length is an error rather than the
+	// end of the script.
+	if op >= 0x4c {
+		buffer := make([]byte, 4)
+		size := map[byte]int{0x4c: 1, 0x4d: 2, 0x4e: 4}[op]
+		if _, err := io.ReadFull(reader, buffer[:size]); err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		dataLength = binary.LittleEndian.Uint32(buffer)
 	}
 
-	data := make([]byte, dataLength)
-	n, err := reader.Read(data)
-
+	// Read through a limited reader so a bogus length doesn't allocate before the data turns out to be missing.
+	data, err := io.ReadAll(io.LimitReader(reader, int64(dataLength)))
 	if err != nil {
 		return nil, err
 	}
 
-	if n != int(dataLength) {
-		return nil, fmt.Errorf("only was able to read in %v bytes, not %v", n, dataLength)
+	if len(data) != int(dataLength) {
+		return nil, fmt.Errorf("only was able to read in %v bytes, not %v", len(data), dataLength)
 	}
 
 	return data, nil
@@ -25,6 +25,33 @@ func (script *Script) Serialize(writer io.Writer) {
 	writer.Write(script.RawData)
 }
 
+// Appends a bare op code to the script.
+func (script *Script) AddOpCode(opCode byte) {
+	script.RawData = append(script.RawData, opCode)
+	script.operations = nil
+}
+
+// Appends an operation that pushes data onto the stack, using the smallest push op that fits.
+func (script *Script) AddData(data []byte) {
+	length := len(data)
+
+	switch {
+	case length == 0:
+		script.RawData = append(script.RawData, 0x00)
+	case length <= 0x4b:
+		script.RawData = append(script.RawData, byte(length))
+	case length <= 0xff:
+		script.RawData = append(script.RawData, 0x4c, byte(length))
+	case length <= 0xffff:
+		script.RawData = append(script.RawData, 0x4d, byte(length), byte(length>>8))
+	default:
+		script.RawData = append(script.RawData, 0x4e, byte(length), byte(length>>8), byte(length>>16), byte(length>>24))
+	}
+
+	script.RawData = append(script.RawData, data...)
+	script.operations = nil
+}
+
 func (script *Script) parseOperations() ([]Operation, error) {
 	ops := make([]Operation, 0)
 	reader := bytes.NewBuffer(script.RawData)
@@ -45,23 +72,52 @@ func (script *Script) parseOperations() ([]Operation, error) {
 	return ops, nil
 }
 
+// The script without its OP_CODESEPARATORs, as legacy signatures hash it. A script that can't be
+// decoded is left as it is.
+func (script *Script) withoutCodeSeparators() Script {
+	stripped := Script{RawData: make([]byte, 0, len(script.RawData))}
+	reader := bytes.NewReader(script.RawData)
+
+	for reader.Len() > 0 {
+		start := len(script.RawData) - reader.Len()
+		op, err := NewOperation(reader)
+		if err != nil {
+			return Script{RawData: script.RawData}
+		}
+		if op.GetOpCode() != 0xab {
+			stripped.RawData = append(stripped.RawData, script.RawData[start:len(script.RawData)-reader.Len()]...)
+		}
+	}
+
+	return stripped
+}
+
+// The script's operations. Panics if the script can't be decoded, use Operations for untrusted scripts.
 func (script *Script) GetOperations() []Operation {
+	ops, err := script.Operations()
+	if err != nil {
+		panic(err)
+	}
+	return ops
+}
+
+// The script's operations, or an error if it can't be decoded (e.g. a push running past the end).
+func (script *Script) Operations() ([]Operation, error) {
 	if script.operations == nil {
 		ops, err := script.parseOperations()
 		if err != nil {
-			panic("AHHHH!")
+			return nil, err
 		}
 
 		script.operations = ops
 	}
-	return script.operations
+	return script.operations, nil
 }
 
 func (script *Script) IsPayToScriptHash() bool {
 
-	ops := script.GetOperations()
-
-	if len(ops) != 3 {
+	ops, err := script.Operations()
+	if err != nil || len(ops) != 3 {
 		return false
 	}
 
@@ -77,6 +133,27 @@ func (script *Script) IsPayToScriptHash() bool {
 	return true
 }
 
+// A witness program is a version op code (OP_0, OP_1 - OP_16) followed by a single 2-40 byte push.
+// Returns the version and the program.
+func (script *Script) WitnessProgram() (byte, []byte, bool) {
+	raw := script.RawData
+	if len(raw) < 4 || len(raw) > 42 {
+		return 0, nil, false
+	}
+	if raw[0] != 0x00 && (raw[0] < 0x51 || raw[0] > 0x60) {
+		return 0, nil, false
+	}
+	if int(raw[1])+2 != len(raw) {
+		return 0, nil, false
+	}
+
+	version := raw[0]
+	if version != 0 {
+		version -= 0x50
+	}
+	return version, raw[2:], true
+}
+
 func (script *Script) GetRedeemScriptHash() *Script {
 	if !script.IsPayToScriptHash() {
 		return nil
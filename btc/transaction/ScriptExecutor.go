@@ -5,26 +5,51 @@ import (
 	"bitcoin-go/utility"
 	"bytes"
 	"fmt"
-	"math/big"
+)
+
+// Consensus limits on witness scripts: stack items they start with can't be bigger than what a
+// script could push, for version 0 and Tapscript alike, and P2WSH scripts have the same size limit
+// as legacy ones. No script can have more than maxStackSize elements on its stacks combined.
+const (
+	maxStackItemSize     = 520
+	maxWitnessScriptSize = 10000
+	maxStackSize         = 1000
 )
 
 type ScriptExecutor struct {
 	scriptPubKey    *Script
 	scriptSignature *Script
-	hash            *big.Int
+	witness         [][]byte
+	sigHasher       SigHasher
+	taprootHasher   TaprootSigHasher
+}
+
+func NewScriptExecutor(pubkey *Script, sig *Script, sigHasher SigHasher) ScriptExecutor {
+	return NewWitnessScriptExecutor(pubkey, sig, nil, sigHasher, nil)
 }
 
-func NewScriptExecutor(pubkey *Script, sig *Script, hash *big.Int) ScriptExecutor {
-	return ScriptExecutor{scriptPubKey: pubkey, scriptSignature: sig, hash: hash}
+// An executor for inputs that can have a witness. For version 0 SegWit inputs the hasher computes
+// BIP143 signature hashes, since signatures only ever get checked in the witness. Taproot inputs
+// use the Taproot hasher instead, which can be nil for anything else.
+func NewWitnessScriptExecutor(pubkey *Script, sig *Script, witness [][]byte, sigHasher SigHasher, taprootHasher TaprootSigHasher) ScriptExecutor {
+	return ScriptExecutor{scriptPubKey: pubkey, scriptSignature: sig, witness: witness, sigHasher: sigHasher, taprootHasher: taprootHasher}
 }
 
 func (ex *ScriptExecutor) Execute() bool {
 
+	// Native SegWit outputs must have an empty script signature, everything is in the witness.
+	if version, program, ok := ex.scriptPubKey.WitnessProgram(); ok {
+		if len(ex.scriptSignature.RawData) != 0 {
+			return false
+		}
+		return ex.executeWitness(version, program, false)
+	}
+
 	// Allocate new stacks for this execution run.
 	stack := collections.NewStack()
 	altStack := collections.NewStack()
 
-	executionContext := ExecutionContext{Stack: &stack, AltStack: &altStack, Hash: ex.hash}
+	executionContext := ExecutionContext{Stack: &stack, AltStack: &altStack, SigHasher: ex.sigHasher}
 
 	// 1. Parse, load and execute script signature
 	ok := executeScript(ex.scriptSignature, &executionContext)
@@ -59,6 +84,16 @@ func (ex *ScriptExecutor) Execute() bool {
 		buffer.Write(redeemScriptBytes)
 		newScript := ParseScript(buffer)
 
+		// 6. P2SH wrapped SegWit, the script signature must be nothing but the push of the witness program.
+		if version, program, ok := newScript.WitnessProgram(); ok {
+			push := Script{}
+			push.AddData(redeemScriptBytes)
+			if !bytes.Equal(ex.scriptSignature.RawData, push.RawData) {
+				return false
+			}
+			return ex.executeWitness(version, program, true)
+		}
+
 		ok := executeScript(&newScript, &executionContext)
 		if !ok {
 			return false
@@ -70,12 +105,168 @@ func (ex *ScriptExecutor) Execute() bool {
 			return false
 		}
 	}
-	return true
+
+	// Anything else can't have a witness.
+	return len(ex.witness) == 0
+}
+
+func (ex *ScriptExecutor) executeWitness(version byte, program []byte, wrapped bool) bool {
+
+	// Taproot outputs can't be wrapped in P2SH, those are left for future upgrades like any other
+	// version, and spending them always succeeds.
+	if version == 1 && len(program) == 32 && !wrapped {
+		return ex.executeTaproot(program)
+	}
+	if version != 0 {
+		return true
+	}
+
+	script, items, ok := witnessScript(program, ex.witness)
+	if !ok {
+		return false
+	}
+
+	return executeWitnessScript(script, items, ex.sigHasher, nil)
+}
+
+// Spends a Taproot output, either with a signature for the output key (the key path) or with a
+// script, the control block proving it's committed to by the output key, and the script's inputs
+// (the script path).
+func (ex *ScriptExecutor) executeTaproot(outputKey []byte) bool {
+
+	witness := ex.witness
+	if len(witness) == 0 {
+		return false
+	}
+
+	// An annex is an optional last item starting with 0x50. It's signed, but has no meaning yet.
+	var annex []byte
+	if last := witness[len(witness)-1]; len(witness) >= 2 && len(last) > 0 && last[0] == taprootAnnexTag {
+		annex = last
+		witness = witness[:len(witness)-1]
+	}
+
+	if len(witness) == 1 {
+		return verifyTaprootSignature(outputKey, witness[0], func(hashType byte) ([]byte, bool) {
+			return ex.taprootHasher(hashType, annex, nil)
+		})
+	}
+
+	script := witness[len(witness)-2]
+	controlBlock, err := ParseControlBlock(witness[len(witness)-1])
+	if err != nil || !controlBlock.VerifyCommitment(outputKey, script) {
+		return false
+	}
+
+	// Only Tapscript is defined, other leaf versions are reserved for future upgrades and always succeed.
+	if controlBlock.LeafVersion != TapscriptLeafVersion {
+		return true
+	}
+
+	// OP_SUCCESSx op codes make the script succeed, as long as it can be decoded up to them.
+	reader := bytes.NewReader(script)
+	for reader.Len() > 0 {
+		op, err := NewOperation(reader)
+		if err != nil {
+			return false
+		}
+		if isOpSuccess(op.GetOpCode()) {
+			return true
+		}
+	}
+
+	items := witness[:len(witness)-2]
+
+	// The signature budget is 50 plus the size of the whole witness.
+	serialized := bytes.NewBuffer(make([]byte, 0))
+	serializeWitness(serialized, ex.witness)
+
+	leafHash := TapLeafHash(controlBlock.LeafVersion, script)
+	tapscript := TapscriptContext{
+		SigHasher: func(hashType byte) ([]byte, bool) {
+			return ex.taprootHasher(hashType, annex, leafHash)
+		},
+		SigOpsBudget: tapscriptSigOpsCost + int64(serialized.Len()),
+	}
+
+	return executeWitnessScript(&Script{RawData: script}, items, nil, &tapscript)
+}
+
+// Runs a witness script with the witness items on the stack, none of which may be bigger than
+// maxStackItemSize. It has to leave exactly one true element behind.
+func executeWitnessScript(script *Script, items [][]byte, sigHasher SigHasher, tapscript *TapscriptContext) bool {
+
+	if len(items) > maxStackSize {
+		return false
+	}
+
+	stack := collections.NewStack()
+	altStack := collections.NewStack()
+	for _, item := range items {
+		if len(item) > maxStackItemSize {
+			return false
+		}
+		// Copy the items, some ops modify stack elements in place.
+		stack.Push(append([]byte{}, item...))
+	}
+
+	executionContext := ExecutionContext{Stack: &stack, AltStack: &altStack, SigHasher: sigHasher, Tapscript: tapscript}
+	if !executeScript(script, &executionContext) {
+		return false
+	}
+
+	if stack.Length() != 1 {
+		return false
+	}
+	return opVerify(&executionContext)
+}
+
+// Op codes that are undefined in Tapscript. Any script containing one is valid, so they can be
+// given a meaning later.
+func isOpSuccess(opCode byte) bool {
+	return opCode == 0x50 || opCode == 0x62 ||
+		(opCode >= 0x7e && opCode <= 0x81) ||
+		(opCode >= 0x83 && opCode <= 0x86) ||
+		(opCode >= 0x89 && opCode <= 0x8a) ||
+		(opCode >= 0x8d && opCode <= 0x8e) ||
+		(opCode >= 0x95 && opCode <= 0x99) ||
+		(opCode >= 0xbb && opCode <= 0xfe)
+}
+
+// Works out the script a version 0 witness program runs, and the witness items it starts with on
+// the stack. A 20 byte program is the HASH160 of a public key and runs the equivalent P2PKH script
+// with the signature and key from the witness. A 32 byte program is the SHA256 of the witness
+// script, which is the last witness item and at most maxWitnessScriptSize bytes.
+func witnessScript(program []byte, witness [][]byte) (*Script, [][]byte, bool) {
+
+	switch len(program) {
+	case 20:
+		if len(witness) != 2 {
+			return nil, nil, false
+		}
+		script := Address{addressType: P2PKH, program: program}.ScriptPubKey()
+		return &script, witness, true
+
+	case 32:
+		if len(witness) == 0 {
+			return nil, nil, false
+		}
+		raw := witness[len(witness)-1]
+		if len(raw) > maxWitnessScriptSize || !bytes.Equal(utility.Sha256(raw), program) {
+			return nil, nil, false
+		}
+		return &Script{RawData: raw}, witness[:len(witness)-1], true
+	}
+
+	return nil, nil, false
 }
 
 func executeScript(script *Script, context *ExecutionContext) bool {
 
-	operations := script.GetOperations()
+	operations, err := script.Operations()
+	if err != nil {
+		return false
+	}
 
 	for i := 0; i < len(operations); i++ {
 		op := operations[i]
@@ -93,6 +284,10 @@ func executeScript(script *Script, context *ExecutionContext) bool {
 				fmt.Printf("Failed processing op %+v.\n", op.GetOpName())
 				return false
 			}
+
+			if context.Stack.Length()+context.AltStack.Length() > maxStackSize {
+				return false
+			}
 		}
 	}
 
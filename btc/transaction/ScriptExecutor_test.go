@@ -1,6 +1,7 @@
 package transaction
 
 import (
+	"bitcoin-go/utility"
 	"bytes"
 	"encoding/hex"
 	"testing"
@@ -26,3 +27,101 @@ func TestScriptExecution(t *testing.T) {
 	// 	t.Error()
 	// }
 }
+
+func TestExecuteMalformedScripts(t *testing.T) {
+
+	testCases := []struct {
+		name      string
+		scriptSig []byte
+		decodes   bool
+	}{
+		{"OP_PUSHDATA1 without a length", []byte{0x4c}, false},
+		{"OP_PUSHDATA2 with half a length", []byte{0x4d, 0x01}, false},
+		{"push past the end", []byte{0x05, 0x01, 0x02}, false},
+		{"huge push past the end", []byte{0x4e, 0xff, 0xff, 0xff, 0x7f, 0x01}, false},
+		{"undefined op code", []byte{0x51, 0xff}, true},
+	}
+
+	// OP_1 leaves a true value behind, so only the script signature decides the outcome.
+	scriptPubKey := Script{RawData: []byte{0x51}}
+
+	for _, testCase := range testCases {
+		scriptSig := Script{RawData: testCase.scriptSig}
+		if _, err := scriptSig.Operations(); (err == nil) != testCase.decodes {
+			t.Errorf("%v: unexpected decoding error %v", testCase.name, err)
+		}
+
+		exec := NewScriptExecutor(&scriptPubKey, &scriptSig, nil)
+		if exec.Execute() {
+			t.Errorf("%v: script succeeded", testCase.name)
+		}
+	}
+}
+
+func TestOp1Negate(t *testing.T) {
+
+	op, err := NewOperation(bytes.NewReader([]byte{0x4f}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	push, ok := op.(AddDataToStackOperation)
+	if !ok || !bytes.Equal(push.Data, encodeNumber(-1)) {
+		t.Fatalf("OP_1NEGATE should push -1, got %+v", op)
+	}
+
+	// OP_1NEGATE <-1> OP_EQUAL
+	scriptSig := Script{RawData: []byte{0x4f}}
+	scriptPubKey := Script{}
+	scriptPubKey.AddData(encodeNumber(-1))
+	scriptPubKey.AddOpCode(0x87)
+	exec := NewScriptExecutor(&scriptPubKey, &scriptSig, nil)
+	if !exec.Execute() {
+		t.Error("OP_1NEGATE should push -1")
+	}
+}
+
+func TestWitnessV0Limits(t *testing.T) {
+
+	// Spends a P2WSH output with the witness script and the items below it.
+	execute := func(witnessScript []byte, items ...[]byte) bool {
+		scriptPubKey := Script{}
+		scriptPubKey.AddOpCode(0x00)
+		scriptPubKey.AddData(utility.Sha256(witnessScript))
+		exec := NewWitnessScriptExecutor(&scriptPubKey, &Script{}, append(items, witnessScript), nil, nil)
+		return exec.Execute()
+	}
+
+	// OP_DROP OP_1
+	dropScript := []byte{0x75, 0x51}
+	if !execute(dropScript, make([]byte, maxStackItemSize)) {
+		t.Error("A 520 byte witness item should be allowed")
+	}
+	if execute(dropScript, make([]byte, maxStackItemSize+1)) {
+		t.Error("A 521 byte witness item shouldn't be allowed")
+	}
+	if execute(dropScript, make([]byte, maxStackItemSize+1), []byte{0x01}) {
+		t.Error("A 521 byte witness item shouldn't be allowed below the top of the stack")
+	}
+
+	// A script of exactly size bytes that leaves OP_1 behind: 520 byte pushes that get dropped,
+	// padded with OP_NOPs.
+	paddedScript := func(size int) []byte {
+		script := Script{}
+		for len(script.RawData)+3+maxStackItemSize+1+1 <= size {
+			script.AddData(make([]byte, maxStackItemSize))
+			script.AddOpCode(0x75)
+		}
+		for len(script.RawData)+1 < size {
+			script.AddOpCode(0x61)
+		}
+		script.AddOpCode(0x51)
+		return script.RawData
+	}
+
+	if script := paddedScript(maxWitnessScriptSize); len(script) != maxWitnessScriptSize || !execute(script) {
+		t.Errorf("A %v byte witness script should be allowed", len(script))
+	}
+	if script := paddedScript(maxWitnessScriptSize + 1); len(script) != maxWitnessScriptSize+1 || execute(script) {
+		t.Errorf("A %v byte witness script shouldn't be allowed", len(script))
+	}
+}
@@ -0,0 +1,219 @@
+package transaction
+
+import (
+	"bitcoin-go/ecc"
+	"bitcoin-go/utility"
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// Taproot (BIP341) outputs are OP_1 followed by a 32 byte output key Q = P + hash_TapTweak(P || m)G,
+// where P is the internal key and m the merkle root of a tree of scripts (or nothing). They can be
+// spent with a Schnorr signature for Q (the key path), or by revealing one of the scripts along with
+// a control block proving it's in the tree (the script path).
+
+const (
+	// The leaf version of Tapscript (BIP342), the only one defined so far.
+	TapscriptLeafVersion = 0xc0
+
+	// The hash type of 64 byte Taproot signatures, which signs the same as SIGHASH_ALL.
+	SIGHASH_DEFAULT = 0x00
+
+	taprootAnnexTag         = 0x50
+	controlBlockBaseSize    = 33
+	controlBlockNodeSize    = 32
+	controlBlockMaxNodes    = 128
+	tapscriptSigOpsCost     = 50
+	taprootSigHashEpoch     = 0x00
+	tapscriptKeyVersion     = 0x00
+	noCodeSeparatorPosition = 0xffffffff
+)
+
+// The hash of a script tree leaf.
+func TapLeafHash(leafVersion byte, script []byte) []byte {
+	buffer := bytes.NewBuffer(make([]byte, 0))
+	buffer.WriteByte(leafVersion)
+	s := Script{RawData: script}
+	s.Serialize(buffer)
+	return utility.TaggedHash("TapLeaf", buffer.Bytes())
+}
+
+// The hash of an inner node of the script tree. The children are sorted, so proofs don't need to
+// say which side they're on.
+func TapBranchHash(a []byte, b []byte) []byte {
+	if bytes.Compare(a, b) > 0 {
+		a, b = b, a
+	}
+	return utility.TaggedHash("TapBranch", a, b)
+}
+
+// The last witness item of a script path spend. It has the leaf version, the parity of the output
+// key, the internal key and the hashes needed to get from the leaf to the merkle root.
+type ControlBlock struct {
+	LeafVersion     byte
+	OutputKeyParity bool // Whether the output key has an odd y
+	InternalKey     ecc.XOnlyPublicKey
+	Path            [][]byte
+}
+
+func ParseControlBlock(buffer []byte) (ControlBlock, error) {
+
+	if len(buffer) < controlBlockBaseSize || (len(buffer)-controlBlockBaseSize)%controlBlockNodeSize != 0 {
+		return ControlBlock{}, fmt.Errorf("control block can't be %v bytes", len(buffer))
+	}
+
+	nodes := (len(buffer) - controlBlockBaseSize) / controlBlockNodeSize
+	if nodes > controlBlockMaxNodes {
+		return ControlBlock{}, fmt.Errorf("control block has %v nodes, the limit is %v", nodes, controlBlockMaxNodes)
+	}
+
+	internalKey, err := ecc.ParseXOnlyPublicKey(buffer[1:controlBlockBaseSize])
+	if err != nil {
+		return ControlBlock{}, err
+	}
+
+	path := make([][]byte, nodes)
+	for i := range path {
+		start := controlBlockBaseSize + i*controlBlockNodeSize
+		path[i] = buffer[start : start+controlBlockNodeSize]
+	}
+
+	return ControlBlock{LeafVersion: buffer[0] & 0xfe, OutputKeyParity: buffer[0]&1 == 1, InternalKey: internalKey, Path: path}, nil
+}
+
+func (c *ControlBlock) Serialize() []byte {
+	buffer := make([]byte, 0, controlBlockBaseSize+len(c.Path)*controlBlockNodeSize)
+	buffer = append(buffer, c.LeafVersion|utility.IIF(c.OutputKeyParity, byte(1), byte(0)).(byte))
+	buffer = append(buffer, c.InternalKey.Serialize()...)
+	for _, node := range c.Path {
+		buffer = append(buffer, node...)
+	}
+	return buffer
+}
+
+// The merkle root of the tree, starting from the hash of the leaf being spent.
+func (c *ControlBlock) MerkleRoot(leafHash []byte) []byte {
+	root := leafHash
+	for _, node := range c.Path {
+		root = TapBranchHash(root, node)
+	}
+	return root
+}
+
+// Checks that the script is committed to by the output key: tweaking the internal key with the
+// merkle root the path leads to has to give the output key, with the parity the block claims.
+func (c *ControlBlock) VerifyCommitment(outputKey []byte, script []byte) bool {
+
+	merkleRoot := c.MerkleRoot(TapLeafHash(c.LeafVersion, script))
+
+	key, parity, err := ecc.TaprootOutputKey(&c.InternalKey, merkleRoot)
+	if err != nil {
+		return false
+	}
+
+	return parity == c.OutputKeyParity && bytes.Equal(key.Serialize(), outputKey)
+}
+
+// The BIP341 signature hash of a Taproot input. Unlike earlier versions it commits to the amounts
+// and scriptPubKeys of all the outputs being spent (prevOuts, in input order), and for script path
+// spends to the leaf being executed (leafHash, nil for the key path). The annex is the optional
+// last witness item starting with 0x50, nil without one.
+func (tx *Tx) SigHashTaproot(index int, prevOuts []TxOut, hashType byte, annex []byte, leafHash []byte) ([]byte, error) {
+
+	msg, err := tx.taprootSigMsg(index, prevOuts, hashType, annex, leafHash)
+	if err != nil {
+		return nil, err
+	}
+	return utility.TaggedHash("TapSighash", []byte{taprootSigHashEpoch}, msg), nil
+}
+
+func (tx *Tx) taprootSigMsg(index int, prevOuts []TxOut, hashType byte, annex []byte, leafHash []byte) ([]byte, error) {
+
+	baseType := hashType & 0x03
+	anyoneCanPay := hashType&SIGHASH_ANYONECANPAY != 0
+
+	if hashType&^(SIGHASH_ANYONECANPAY|0x03) != 0 || (baseType == SIGHASH_DEFAULT && hashType != SIGHASH_DEFAULT) {
+		return nil, fmt.Errorf("invalid Taproot hash type 0x%02x", hashType)
+	}
+	if len(prevOuts) != len(tx.TxIns) {
+		return nil, fmt.Errorf("expected %v previous outputs, got %v", len(tx.TxIns), len(prevOuts))
+	}
+	if baseType == SIGHASH_SINGLE && index >= len(tx.TxOuts) {
+		return nil, errors.New("SIGHASH_SINGLE without a matching output")
+	}
+
+	txIn := tx.TxIns[index]
+
+	buff := bytes.NewBuffer(make([]byte, 0))
+	buff.WriteByte(hashType)
+	utility.WriteUint32(buff, tx.Version, true)
+	utility.WriteUint32(buff, tx.LockTime, true)
+
+	// Unlike BIP143 these are single SHA256 hashes.
+	if !anyoneCanPay {
+		prevouts := bytes.NewBuffer(make([]byte, 0))
+		amounts := bytes.NewBuffer(make([]byte, 0))
+		scriptPubKeys := bytes.NewBuffer(make([]byte, 0))
+		sequences := bytes.NewBuffer(make([]byte, 0))
+		for i, in := range tx.TxIns {
+			in.serializeOutpoint(prevouts)
+			utility.WriteUint64(amounts, prevOuts[i].Satoshis, true)
+			prevOuts[i].ScriptPubKey.Serialize(scriptPubKeys)
+			utility.WriteUint32(sequences, in.Sequence, true)
+		}
+		buff.Write(utility.Sha256(prevouts.Bytes()))
+		buff.Write(utility.Sha256(amounts.Bytes()))
+		buff.Write(utility.Sha256(scriptPubKeys.Bytes()))
+		buff.Write(utility.Sha256(sequences.Bytes()))
+	}
+
+	if baseType != SIGHASH_NONE && baseType != SIGHASH_SINGLE {
+		outputs := bytes.NewBuffer(make([]byte, 0))
+		for _, out := range tx.TxOuts {
+			out.Serialize(outputs)
+		}
+		buff.Write(utility.Sha256(outputs.Bytes()))
+	}
+
+	// The spend type: whether it's a script path spend, and whether there's an annex.
+	var spendType byte = 0
+	if leafHash != nil {
+		spendType |= 2
+	}
+	if annex != nil {
+		spendType |= 1
+	}
+	buff.WriteByte(spendType)
+
+	if anyoneCanPay {
+		txIn.serializeOutpoint(buff)
+		utility.WriteUint64(buff, prevOuts[index].Satoshis, true)
+		prevOuts[index].ScriptPubKey.Serialize(buff)
+		utility.WriteUint32(buff, txIn.Sequence, true)
+	} else {
+		utility.WriteUint32(buff, uint32(index), true)
+	}
+
+	if annex != nil {
+		a := Script{RawData: annex}
+		serialized := bytes.NewBuffer(make([]byte, 0))
+		a.Serialize(serialized)
+		buff.Write(utility.Sha256(serialized.Bytes()))
+	}
+
+	if baseType == SIGHASH_SINGLE {
+		output := bytes.NewBuffer(make([]byte, 0))
+		tx.TxOuts[index].Serialize(output)
+		buff.Write(utility.Sha256(output.Bytes()))
+	}
+
+	// OP_CODESEPARATOR isn't supported, so the position is always "none".
+	if leafHash != nil {
+		buff.Write(leafHash)
+		buff.WriteByte(tapscriptKeyVersion)
+		utility.WriteUint32(buff, noCodeSeparatorPosition, true)
+	}
+
+	return buff.Bytes(), nil
+}
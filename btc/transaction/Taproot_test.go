@@ -0,0 +1,598 @@
+package transaction
+
+import (
+	"bitcoin-go/ecc"
+	"bitcoin-go/utility"
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+// The key path spending vectors from BIP341's wallet-test-vectors.json.
+const taprootKeyPathTx = "02000000097de20cbff686da83a54981d2b9bab3586f4ca7e48f57f5b55963115f3b334e9c010000000000000000d7b7cab57b1393ace2d064f4d4a2cb8af6def61273e127517d44759b6dafdd990000000000fffffffff8e1f583384333689228c5d28eac13366be082dc57441760d957275419a418420000000000fffffffff0689180aa63b30cb162a73c6d2a38b7eeda2a83ece74310fda0843ad604853b0100000000feffffffaa5202bdf6d8ccd2ee0f0202afbbb7461d9264a25e5bfd3c5a52ee1239e0ba6c0000000000feffffff956149bdc66faa968eb2be2d2faa29718acbfe3941215893a2a3446d32acd050000000000000000000e664b9773b88c09c32cb70a2a3e4da0ced63b7ba3b22f848531bbb1d5d5f4c94010000000000000000e9aa6b8e6c9de67619e6a3924ae25696bb7b694bb677a632a74ef7eadfd4eabf0000000000ffffffffa778eb6a263dc090464cd125c466b5a99667720b1c110468831d058aa1b82af10100000000ffffffff0200ca9a3b000000001976a91406afd46bcdfd22ef94ac122aa11f241244a37ecc88ac807840cb0000000020ac9a87f5594be208f8532db38cff670c450ed2fea8fcdefcc9a663f78bab962b0065cd1d"
+
+var taprootKeyPathUtxos = []struct {
+	scriptPubKey string
+	amount       uint64
+}{
+	{"512053a1f6e454df1aa2776a2814a721372d6258050de330b3c6d10ee8f4e0dda343", 420000000},
+	{"5120147c9c57132f6e7ecddba9800bb0c4449251c92a1e60371ee77557b6620f3ea3", 462000000},
+	{"76a914751e76e8199196d454941c45d1b3a323f1433bd688ac", 294000000},
+	{"5120e4d810fd50586274face62b8a807eb9719cef49c04177cc6b76a9a4251d5450e", 504000000},
+	{"512091b64d5324723a985170e4dc5a0f84c041804f2cd12660fa5dec09fc21783605", 630000000},
+	{"00147dd65592d0ab2fe0d0257d571abf032cd9db93dc", 378000000},
+	{"512075169f4001aa68f15bbed28b218df1d0a62cbbcf1188c6665110c293c907b831", 672000000},
+	{"5120712447206d7a5238acc7ff53fbe94a3b64539ad291c7cdbc490b7577e4b17df5", 546000000},
+	{"512077e30a5522dd9f894c3f8b8bd4c4b2cf82ca7da8a3ea6a239655c39c050ab220", 588000000},
+}
+
+var taprootKeyPathInputs = []struct {
+	index    int
+	hashType byte
+	sigMsg   string
+	sigHash  string
+	witness  string
+}{
+	{0, 0x03, "0003020000000065cd1de3b33bb4ef3a52ad1fffb555c0d82828eb22737036eaeb02a235d82b909c4c3f58a6964a4f5f8f0b642ded0a8a553be7622a719da71d1f5befcefcdee8e0fde623ad0f61ad2bca5ba6a7693f50fce988e17c3780bf2b1e720cfbb38fbdd52e2118959c7221ab5ce9e26c3cd67b22c24f8baa54bac281d8e6b05e400e6c3a957e0000000000d0418f0e9a36245b9a50ec87f8bf5be5bcae434337b87139c3a5b1f56e33cba0", "2514a6272f85cfa0f45eb907fcb0d121b808ed37c6ea160a5a9046ed5526d555", "ed7c1647cb97379e76892be0cacff57ec4a7102aa24296ca39af7541246d8ff14d38958d4cc1e2e478e4d4a764bbfd835b16d4e314b72937b29833060b87276c03"},
+	{1, 0x83, "0083020000000065cd1d00d7b7cab57b1393ace2d064f4d4a2cb8af6def61273e127517d44759b6dafdd9900000000808f891b00000000225120147c9c57132f6e7ecddba9800bb0c4449251c92a1e60371ee77557b6620f3ea3ffffffffffcef8fb4ca7efc5433f591ecfc57391811ce1e186a3793024def5c884cba51d", "325a644af47e8a5a2591cda0ab0723978537318f10e6a63d4eed783b96a71a4d", "052aedffc554b41f52b521071793a6b88d6dbca9dba94cf34c83696de0c1ec35ca9c5ed4ab28059bd606a4f3a657eec0bb96661d42921b5f50a95ad33675b54f83"},
+	{3, 0x01, "0001020000000065cd1de3b33bb4ef3a52ad1fffb555c0d82828eb22737036eaeb02a235d82b909c4c3f58a6964a4f5f8f0b642ded0a8a553be7622a719da71d1f5befcefcdee8e0fde623ad0f61ad2bca5ba6a7693f50fce988e17c3780bf2b1e720cfbb38fbdd52e2118959c7221ab5ce9e26c3cd67b22c24f8baa54bac281d8e6b05e400e6c3a957ea2e6dab7c1f0dcd297c8d61647fd17d821541ea69c3cc37dcbad7f90d4eb4bc50003000000", "bf013ea93474aa67815b1b6cc441d23b64fa310911d991e713cd34c7f5d46669", "ff45f742a876139946a149ab4d9185574b98dc919d2eb6754f8abaa59d18b025637a3aa043b91817739554f4ed2026cf8022dbd83e351ce1fabc272841d2510a01"},
+	{4, 0x00, "0000020000000065cd1de3b33bb4ef3a52ad1fffb555c0d82828eb22737036eaeb02a235d82b909c4c3f58a6964a4f5f8f0b642ded0a8a553be7622a719da71d1f5befcefcdee8e0fde623ad0f61ad2bca5ba6a7693f50fce988e17c3780bf2b1e720cfbb38fbdd52e2118959c7221ab5ce9e26c3cd67b22c24f8baa54bac281d8e6b05e400e6c3a957ea2e6dab7c1f0dcd297c8d61647fd17d821541ea69c3cc37dcbad7f90d4eb4bc50004000000", "4f900a0bae3f1446fd48490c2958b5a023228f01661cda3496a11da502a7f7ef", "b4010dd48a617db09926f729e79c33ae0b4e94b79f04a1ae93ede6315eb3669de185a17d2b0ac9ee09fd4c64b678a0b61a0a86fa888a273c8511be83bfd6810f"},
+	{6, 0x02, "0002020000000065cd1de3b33bb4ef3a52ad1fffb555c0d82828eb22737036eaeb02a235d82b909c4c3f58a6964a4f5f8f0b642ded0a8a553be7622a719da71d1f5befcefcdee8e0fde623ad0f61ad2bca5ba6a7693f50fce988e17c3780bf2b1e720cfbb38fbdd52e2118959c7221ab5ce9e26c3cd67b22c24f8baa54bac281d8e6b05e400e6c3a957e0006000000", "15f25c298eb5cdc7eb1d638dd2d45c97c4c59dcaec6679cfc16ad84f30876b85", "a3785919a2ce3c4ce26f298c3d51619bc474ae24014bcdd31328cd8cfbab2eff3395fa0a16fe5f486d12f22a9cedded5ae74feb4bbe5351346508c5405bcfee002"},
+	{7, 0x82, "0082020000000065cd1d00e9aa6b8e6c9de67619e6a3924ae25696bb7b694bb677a632a74ef7eadfd4eabf00000000804c8b2000000000225120712447206d7a5238acc7ff53fbe94a3b64539ad291c7cdbc490b7577e4b17df5ffffffff", "cd292de50313804dabe4685e83f923d2969577191a3e1d2882220dca88cbeb10", "ea0c6ba90763c2d3a296ad82ba45881abb4f426b3f87af162dd24d5109edc1cdd11915095ba47c3a9963dc1e6c432939872bc49212fe34c632cd3ab9fed429c482"},
+	{8, 0x81, "0081020000000065cd1da2e6dab7c1f0dcd297c8d61647fd17d821541ea69c3cc37dcbad7f90d4eb4bc500a778eb6a263dc090464cd125c466b5a99667720b1c110468831d058aa1b82af101000000002b0c230000000022512077e30a5522dd9f894c3f8b8bd4c4b2cf82ca7da8a3ea6a239655c39c050ab220ffffffff", "cccb739eca6c13a8a89e6e5cd317ffe55669bbda23f2fd37b0f18755e008edd2", "bbc9584a11074e83bc8c6759ec55401f0ae7b03ef290c3139814f545b58a9f8127258000874f44bc46db7646322107d4d86aec8e73b8719a61fff761d75b5dd981"},
+}
+
+func parseTaprootKeyPathTx() (Tx, []TxOut) {
+	b, _ := hex.DecodeString(taprootKeyPathTx)
+	tx := ParseTx(bytes.NewBuffer(b), false)
+
+	prevOuts := make([]TxOut, len(taprootKeyPathUtxos))
+	for i, utxo := range taprootKeyPathUtxos {
+		raw, _ := hex.DecodeString(utxo.scriptPubKey)
+		prevOuts[i] = NewTxOut(utxo.amount, Script{RawData: raw})
+	}
+	return tx, prevOuts
+}
+
+func TestSigHashTaprootVectors(t *testing.T) {
+
+	tx, prevOuts := parseTaprootKeyPathTx()
+
+	for _, input := range taprootKeyPathInputs {
+		msg, err := tx.taprootSigMsg(input.index, prevOuts, input.hashType, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := "00" + hex.EncodeToString(msg); actual != input.sigMsg {
+			t.Errorf("Input %v: expected message %v, got %v", input.index, input.sigMsg, actual)
+		}
+
+		hash, err := tx.SigHashTaproot(input.index, prevOuts, input.hashType, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := hex.EncodeToString(hash); actual != input.sigHash {
+			t.Errorf("Input %v: expected hash %v, got %v", input.index, input.sigHash, actual)
+		}
+	}
+
+	for _, hashType := range []byte{0x04, 0x80, 0x84, 0xc1} {
+		if _, err := tx.SigHashTaproot(0, prevOuts, hashType, nil, nil); err == nil {
+			t.Errorf("Expected an error for hash type 0x%02x", hashType)
+		}
+	}
+	if _, err := tx.SigHashTaproot(0, prevOuts[1:], SIGHASH_DEFAULT, nil, nil); err == nil {
+		t.Error("Expected an error for a missing previous output")
+	}
+	if _, err := tx.SigHashTaproot(2, prevOuts, SIGHASH_SINGLE, nil, nil); err == nil {
+		t.Error("Expected an error for SIGHASH_SINGLE without a matching output")
+	}
+}
+
+func TestVerifyTaprootKeyPathVectors(t *testing.T) {
+
+	tx, prevOuts := parseTaprootKeyPathTx()
+	for i := range tx.TxIns {
+		storePreviousOutput(tx.TxIns[i], prevOuts[i].Satoshis, hex.EncodeToString(prevOuts[i].ScriptPubKey.RawData))
+	}
+
+	for _, input := range taprootKeyPathInputs {
+		sig, _ := hex.DecodeString(input.witness)
+		tx.TxIns[input.index].Witness = [][]byte{sig}
+
+		if !tx.VerifyInput(input.index) {
+			t.Errorf("Input %v didn't verify", input.index)
+		}
+
+		// With the hash type changed, or dropped.
+		changed := append(append([]byte{}, sig[:64]...), input.hashType^0x80)
+		tx.TxIns[input.index].Witness = [][]byte{changed}
+		if tx.VerifyInput(input.index) {
+			t.Errorf("Input %v verified with a different hash type", input.index)
+		}
+		tx.TxIns[input.index].Witness = [][]byte{sig[:64]}
+		if input.hashType != SIGHASH_DEFAULT && tx.VerifyInput(input.index) {
+			t.Errorf("Input %v verified without its hash type", input.index)
+		}
+
+		tx.TxIns[input.index].Witness = [][]byte{sig}
+	}
+
+	// An explicit SIGHASH_DEFAULT byte isn't allowed.
+	sig, _ := hex.DecodeString(taprootKeyPathInputs[3].witness)
+	tx.TxIns[4].Witness = [][]byte{append(sig, SIGHASH_DEFAULT)}
+	if tx.VerifyInput(4) {
+		t.Error("Signature with an explicit SIGHASH_DEFAULT byte shouldn't verify")
+	}
+}
+
+// The script trees from BIP341's wallet-test-vectors.json, with the control block of each leaf.
+var taprootScriptTreeVectors = []struct {
+	outputKey     string
+	scripts       []string
+	leafVersions  []byte
+	leafHashes    []string
+	controlBlocks []string
+}{
+	{
+		"147c9c57132f6e7ecddba9800bb0c4449251c92a1e60371ee77557b6620f3ea3",
+		[]string{"20d85a959b0290bf19bb89ed43c916be835475d013da4b362117393e25a48229b8ac"},
+		[]byte{0xc0},
+		[]string{"5b75adecf53548f3ec6ad7d78383bf84cc57b55a3127c72b9a2481752dd88b21"},
+		[]string{"c1187791b6f712a8ea41c8ecdd0ee77fab3e85263b37e1ec18a3651926b3a6cf27"},
+	},
+	{
+		"e4d810fd50586274face62b8a807eb9719cef49c04177cc6b76a9a4251d5450e",
+		[]string{"20b617298552a72ade070667e86ca63b8f5789a9fe8731ef91202a91c9f3459007ac"},
+		[]byte{0xc0},
+		[]string{"c525714a7f49c28aedbbba78c005931a81c234b2f6c99a73e4d06082adc8bf2b"},
+		[]string{"c093478e9488f956df2396be2ce6c5cced75f900dfa18e7dabd2428aae78451820"},
+	},
+	{
+		"712447206d7a5238acc7ff53fbe94a3b64539ad291c7cdbc490b7577e4b17df5",
+		[]string{"20387671353e273264c495656e27e39ba899ea8fee3bb69fb2a680e22093447d48ac", "06424950333431"},
+		[]byte{0xc0, 0xfa},
+		[]string{"8ad69ec7cf41c2a4001fd1f738bf1e505ce2277acdcaa63fe4765192497f47a7", "f224a923cd0021ab202ab139cc56802ddb92dcfc172b9212261a539df79a112a"},
+		[]string{
+			"c0ee4fe085983462a184015d1f782d6a5f8b9c2b60130aff050ce221ecf3786592f224a923cd0021ab202ab139cc56802ddb92dcfc172b9212261a539df79a112a",
+			"faee4fe085983462a184015d1f782d6a5f8b9c2b60130aff050ce221ecf37865928ad69ec7cf41c2a4001fd1f738bf1e505ce2277acdcaa63fe4765192497f47a7",
+		},
+	},
+	{
+		"77e30a5522dd9f894c3f8b8bd4c4b2cf82ca7da8a3ea6a239655c39c050ab220",
+		[]string{"2044b178d64c32c4a05cc4f4d1407268f764c940d20ce97abfd44db5c3592b72fdac", "07546170726f6f74"},
+		[]byte{0xc0, 0xc0},
+		[]string{"64512fecdb5afa04f98839b50e6f0cb7b1e539bf6f205f67934083cdcc3c8d89", "2cb2b90daa543b544161530c925f285b06196940d6085ca9474d41dc3822c5cb"},
+		[]string{
+			"c1f9f400803e683727b14f463836e1e78e1c64417638aa066919291a225f0e8dd82cb2b90daa543b544161530c925f285b06196940d6085ca9474d41dc3822c5cb",
+			"c1f9f400803e683727b14f463836e1e78e1c64417638aa066919291a225f0e8dd864512fecdb5afa04f98839b50e6f0cb7b1e539bf6f205f67934083cdcc3c8d89",
+		},
+	},
+	{
+		"91b64d5324723a985170e4dc5a0f84c041804f2cd12660fa5dec09fc21783605",
+		[]string{
+			"2072ea6adcf1d371dea8fba1035a09f3d24ed5a059799bae114084130ee5898e69ac",
+			"202352d137f2f3ab38d1eaa976758873377fa5ebb817372c71e2c542313d4abda8ac",
+			"207337c0dd4253cb86f2c43a2351aadd82cccb12a172cd120452b9bb8324f2186aac",
+		},
+		[]byte{0xc0, 0xc0, 0xc0},
+		[]string{
+			"2645a02e0aac1fe69d69755733a9b7621b694bb5b5cde2bbfc94066ed62b9817",
+			"ba982a91d4fc552163cb1c0da03676102d5b7a014304c01f0c77b2b8e888de1c",
+			"9e31407bffa15fefbf5090b149d53959ecdf3f62b1246780238c24501d5ceaf6",
+		},
+		[]string{
+			"c0e0dfe2300b0dd746a3f8674dfd4525623639042569d829c7f0eed9602d263e6fffe578e9ea769027e4f5a3de40732f75a88a6353a09d767ddeb66accef85e553",
+			"c0e0dfe2300b0dd746a3f8674dfd4525623639042569d829c7f0eed9602d263e6f9e31407bffa15fefbf5090b149d53959ecdf3f62b1246780238c24501d5ceaf62645a02e0aac1fe69d69755733a9b7621b694bb5b5cde2bbfc94066ed62b9817",
+			"c0e0dfe2300b0dd746a3f8674dfd4525623639042569d829c7f0eed9602d263e6fba982a91d4fc552163cb1c0da03676102d5b7a014304c01f0c77b2b8e888de1c2645a02e0aac1fe69d69755733a9b7621b694bb5b5cde2bbfc94066ed62b9817",
+		},
+	},
+	{
+		"75169f4001aa68f15bbed28b218df1d0a62cbbcf1188c6665110c293c907b831",
+		[]string{
+			"2071981521ad9fc9036687364118fb6ccd2035b96a423c59c5430e98310a11abe2ac",
+			"20d5094d2dbe9b76e2c245a2b89b6006888952e2faa6a149ae318d69e520617748ac",
+			"20c440b462ad48c7a77f94cd4532d8f2119dcebbd7c9764557e62726419b08ad4cac",
+		},
+		[]byte{0xc0, 0xc0, 0xc0},
+		[]string{
+			"f154e8e8e17c31d3462d7132589ed29353c6fafdb884c5a6e04ea938834f0d9d",
+			"737ed1fe30bc42b8022d717b44f0d93516617af64a64753b7a06bf16b26cd711",
+			"d7485025fceb78b9ed667db36ed8b8dc7b1f0b307ac167fa516fe4352b9f4ef7",
+		},
+		[]string{
+			"c155adf4e8967fbd2e29f20ac896e60c3b0f1d5b0efa9d34941b5958c7b0a0312d3cd369a528b326bc9d2133cbd2ac21451acb31681a410434672c8e34fe757e91",
+			"c155adf4e8967fbd2e29f20ac896e60c3b0f1d5b0efa9d34941b5958c7b0a0312dd7485025fceb78b9ed667db36ed8b8dc7b1f0b307ac167fa516fe4352b9f4ef7f154e8e8e17c31d3462d7132589ed29353c6fafdb884c5a6e04ea938834f0d9d",
+			"c155adf4e8967fbd2e29f20ac896e60c3b0f1d5b0efa9d34941b5958c7b0a0312d737ed1fe30bc42b8022d717b44f0d93516617af64a64753b7a06bf16b26cd711f154e8e8e17c31d3462d7132589ed29353c6fafdb884c5a6e04ea938834f0d9d",
+		},
+	},
+}
+
+func TestTaprootScriptTreeVectors(t *testing.T) {
+
+	for _, vector := range taprootScriptTreeVectors {
+		outputKey, _ := hex.DecodeString(vector.outputKey)
+
+		for i, scriptHex := range vector.scripts {
+			script, _ := hex.DecodeString(scriptHex)
+
+			if leafHash := hex.EncodeToString(TapLeafHash(vector.leafVersions[i], script)); leafHash != vector.leafHashes[i] {
+				t.Errorf("%v, leaf %v: expected leaf hash %v, got %v", vector.outputKey, i, vector.leafHashes[i], leafHash)
+			}
+
+			raw, _ := hex.DecodeString(vector.controlBlocks[i])
+			controlBlock, err := ParseControlBlock(raw)
+			if err != nil {
+				t.Errorf("%v, leaf %v: %v", vector.outputKey, i, err)
+				continue
+			}
+			if controlBlock.LeafVersion != vector.leafVersions[i] {
+				t.Errorf("%v, leaf %v: expected leaf version 0x%02x, got 0x%02x", vector.outputKey, i, vector.leafVersions[i], controlBlock.LeafVersion)
+			}
+			if !bytes.Equal(controlBlock.Serialize(), raw) {
+				t.Errorf("%v, leaf %v: control block didn't round trip", vector.outputKey, i)
+			}
+
+			if !controlBlock.VerifyCommitment(outputKey, script) {
+				t.Errorf("%v, leaf %v: commitment didn't verify", vector.outputKey, i)
+			}
+
+			// Another script, or the wrong parity.
+			if controlBlock.VerifyCommitment(outputKey, append(script, 0x75)) {
+				t.Errorf("%v, leaf %v: commitment verified for a different script", vector.outputKey, i)
+			}
+			controlBlock.OutputKeyParity = !controlBlock.OutputKeyParity
+			if controlBlock.VerifyCommitment(outputKey, script) {
+				t.Errorf("%v, leaf %v: commitment verified with the wrong parity", vector.outputKey, i)
+			}
+		}
+	}
+}
+
+func TestParseControlBlockInvalid(t *testing.T) {
+
+	valid, _ := hex.DecodeString(taprootScriptTreeVectors[0].controlBlocks[0])
+
+	testCases := map[string][]byte{
+		"too short":          valid[:32],
+		"partial node":       append(append([]byte{}, valid...), make([]byte, 31)...),
+		"too many nodes":     append(append([]byte{}, valid...), make([]byte, 129*32)...),
+		"internal key not x": append([]byte{0xc0}, bytes.Repeat([]byte{0xff}, 32)...),
+	}
+
+	for name, buffer := range testCases {
+		if _, err := ParseControlBlock(buffer); err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
+
+// A Taproot output with a two leaf script tree, spent by the test's transaction.
+type tapscriptFixture struct {
+	tx          Tx
+	outputKey   []byte
+	scripts     []Script
+	controlBlks []ControlBlock
+}
+
+func newTapscriptFixture(t *testing.T, scripts ...Script) tapscriptFixture {
+
+	internal := ecc.NewPrivateKey(big.NewInt(3001))
+	internalPub := internal.PublicKey()
+	internalKey, _, _ := internalPub.ToXOnlyPublicKey()
+
+	leafHashes := make([][]byte, len(scripts))
+	for i, script := range scripts {
+		leafHashes[i] = TapLeafHash(TapscriptLeafVersion, script.RawData)
+	}
+	merkleRoot := TapBranchHash(leafHashes[0], leafHashes[1])
+
+	outputKey, parity, err := ecc.TaprootOutputKey(&internalKey, merkleRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	controlBlocks := []ControlBlock{
+		{LeafVersion: TapscriptLeafVersion, OutputKeyParity: parity, InternalKey: internalKey, Path: [][]byte{leafHashes[1]}},
+		{LeafVersion: TapscriptLeafVersion, OutputKeyParity: parity, InternalKey: internalKey, Path: [][]byte{leafHashes[0]}},
+	}
+
+	scriptPubKey := Script{}
+	scriptPubKey.AddOpCode(0x51)
+	scriptPubKey.AddData(outputKey.Serialize())
+
+	var prevTxHash [32]byte
+	copy(prevTxHash[:], utility.Hash256(append([]byte("tapscript"), merkleRoot...)))
+	txIn := NewTxIn(prevTxHash, 0, &Script{}, 0xffffffff)
+	storePreviousOutput(txIn, 100000, hex.EncodeToString(scriptPubKey.RawData))
+
+	tx := NewTx(2, []TxIn{txIn}, []TxOut{NewTxOut(90000, scriptPubKey)}, 0, false)
+
+	return tapscriptFixture{tx: tx, outputKey: outputKey.Serialize(), scripts: scripts, controlBlks: controlBlocks}
+}
+
+func (f *tapscriptFixture) sign(t *testing.T, key *ecc.PrivateKey, leaf int, hashType byte) []byte {
+	leafHash := TapLeafHash(TapscriptLeafVersion, f.scripts[leaf].RawData)
+	hash, err := f.tx.SigHashTaproot(0, f.tx.previousOutputs(), hashType, nil, leafHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := key.SignSchnorr(hash, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serialized := sig.Serialize()
+	if hashType != SIGHASH_DEFAULT {
+		serialized = append(serialized, hashType)
+	}
+	return serialized
+}
+
+func (f *tapscriptFixture) spend(leaf int, items ...[]byte) bool {
+	f.tx.TxIns[0].Witness = append(items, f.scripts[leaf].RawData, f.controlBlks[leaf].Serialize())
+	return f.tx.VerifyInput(0)
+}
+
+func TestVerifyTapscript(t *testing.T) {
+
+	keyA := ecc.NewPrivateKey(big.NewInt(3002))
+	keyB := ecc.NewPrivateKey(big.NewInt(3003))
+	pubA := keyA.PublicKey()
+	pubB := keyB.PublicKey()
+
+	// <A> OP_CHECKSIG
+	single := Script{}
+	single.AddData(pubA.ToXOnly())
+	single.AddOpCode(0xac)
+
+	// <A> OP_CHECKSIG <B> OP_CHECKSIGADD OP_2 OP_NUMEQUAL
+	multi := Script{}
+	multi.AddData(pubA.ToXOnly())
+	multi.AddOpCode(0xac)
+	multi.AddData(pubB.ToXOnly())
+	multi.AddOpCode(0xba)
+	multi.AddOpCode(0x52)
+	multi.AddOpCode(0x9c)
+
+	f := newTapscriptFixture(t, single, multi)
+
+	sigA := f.sign(t, &keyA, 0, SIGHASH_DEFAULT)
+	if !f.spend(0, sigA) {
+		t.Error("Single signature leaf should verify")
+	}
+	if !f.spend(0, f.sign(t, &keyA, 0, SIGHASH_SINGLE|SIGHASH_ANYONECANPAY)) {
+		t.Error("Single signature leaf should verify with an explicit hash type")
+	}
+	if f.spend(0, f.sign(t, &keyA, 1, SIGHASH_DEFAULT)) {
+		t.Error("Signature for another leaf shouldn't verify")
+	}
+	if f.spend(0, f.sign(t, &keyB, 0, SIGHASH_DEFAULT)) {
+		t.Error("Signature from another key shouldn't verify")
+	}
+	if f.spend(0, []byte{}) {
+		t.Error("An empty signature should fail the check")
+	}
+
+	// Items are popped from the end, so B's signature goes first.
+	sigA = f.sign(t, &keyA, 1, SIGHASH_DEFAULT)
+	sigB := f.sign(t, &keyB, 1, SIGHASH_ALL)
+	if !f.spend(1, sigB, sigA) {
+		t.Error("2-of-2 OP_CHECKSIGADD leaf should verify")
+	}
+	if f.spend(1, []byte{}, sigA) {
+		t.Error("2-of-2 OP_CHECKSIGADD leaf shouldn't verify with one signature")
+	}
+	if f.spend(1, sigA, sigB) {
+		t.Error("2-of-2 OP_CHECKSIGADD leaf shouldn't verify with the signatures swapped")
+	}
+
+	// A control block for the other leaf.
+	f.tx.TxIns[0].Witness = [][]byte{sigA, f.scripts[0].RawData, f.controlBlks[1].Serialize()}
+	if f.tx.VerifyInput(0) {
+		t.Error("Script shouldn't verify with the wrong control block")
+	}
+
+	// Tapscripts have to leave exactly one element on the stack.
+	if f.spend(0, []byte{0x01}, f.sign(t, &keyA, 0, SIGHASH_DEFAULT)) {
+		t.Error("Script shouldn't verify leaving two elements on the stack")
+	}
+}
+
+func TestTapscriptRules(t *testing.T) {
+
+	key := ecc.NewPrivateKey(big.NewInt(3004))
+	pub := key.PublicKey()
+	pubKey := pub.ToXOnly()
+
+	// OP_RESERVED (0x50) is an OP_SUCCESS, it makes the script valid without looking at the rest.
+	success := Script{RawData: []byte{0x50, 0x6a}}
+
+	// OP_CHECKMULTISIG is disabled.
+	multisig := Script{}
+	multisig.AddOpCode(0x51)
+	multisig.AddData(pubKey)
+	multisig.AddOpCode(0x51)
+	multisig.AddOpCode(0xae)
+
+	f := newTapscriptFixture(t, success, multisig)
+	if !f.spend(0) {
+		t.Error("OP_SUCCESS leaf should verify")
+	}
+	if f.spend(1, []byte{}, f.sign(t, &key, 1, SIGHASH_DEFAULT)) {
+		t.Error("OP_CHECKMULTISIG shouldn't be usable in Tapscript")
+	}
+
+	// A leaf that can't be decoded fails, even with an OP_SUCCESS after the problem.
+	truncated := Script{RawData: []byte{0x4c, 0x05, 0x01}}
+	f = newTapscriptFixture(t, truncated, Script{RawData: []byte{0x51}})
+	if f.spend(0) {
+		t.Error("Leaf that can't be decoded shouldn't verify")
+	}
+	if !f.spend(1) {
+		t.Error("OP_1 leaf should verify")
+	}
+
+	// Every signature checked costs 50 of the budget, which is 50 plus the witness size. Checking
+	// the same signature over and over runs out.
+	repeated := func(checks int) Script {
+		script := Script{}
+		for i := 0; i < checks; i++ {
+			script.AddOpCode(0x76) // OP_DUP
+			script.AddData(pubKey)
+			script.AddOpCode(0xad) // OP_CHECKSIGVERIFY
+		}
+		script.AddData(pubKey)
+		script.AddOpCode(0xac)
+		return script
+	}
+
+	f = newTapscriptFixture(t, repeated(4), repeated(20))
+	if !f.spend(0, f.sign(t, &key, 0, SIGHASH_DEFAULT)) {
+		t.Error("Five signature checks should be within the budget")
+	}
+	if f.spend(1, f.sign(t, &key, 1, SIGHASH_DEFAULT)) {
+		t.Error("Twenty one signature checks should exceed the budget")
+	}
+}
+
+func TestTapscriptLimits(t *testing.T) {
+
+	key := ecc.NewPrivateKey(big.NewInt(3005))
+	pub := key.PublicKey()
+
+	// <key> OP_CHECKSIGADD OP_1 OP_NUMEQUAL, with the number to add to from the witness.
+	checkSigAdd := Script{}
+	checkSigAdd.AddData(pub.ToXOnly())
+	checkSigAdd.AddOpCode(0xba)
+	checkSigAdd.AddOpCode(0x51)
+	checkSigAdd.AddOpCode(0x9c)
+
+	f := newTapscriptFixture(t, checkSigAdd, Script{RawData: []byte{0x51}})
+	sig := f.sign(t, &key, 0, SIGHASH_DEFAULT)
+	if !f.spend(0, sig, []byte{}) {
+		t.Error("OP_CHECKSIGADD should add to zero")
+	}
+	for _, num := range [][]byte{{0x00}, {0x80}, {0x00, 0x00}, {0x00, 0x00, 0x00, 0x00}} {
+		if f.spend(0, sig, num) {
+			t.Errorf("OP_CHECKSIGADD shouldn't accept the non-minimal zero %x", num)
+		}
+	}
+
+	// Minimal numbers need the extra byte when the top bit is taken.
+	for _, testCase := range []struct {
+		num     []byte
+		minimal bool
+	}{
+		{[]byte{}, true},
+		{[]byte{0x01}, true},
+		{[]byte{0x81}, true},
+		{[]byte{0x80, 0x00}, true},
+		{[]byte{0x80, 0x80}, true},
+		{[]byte{0x01, 0x00}, false},
+		{[]byte{0x01, 0x80}, false},
+	} {
+		if isMinimalNumber(testCase.num) != testCase.minimal {
+			t.Errorf("%x: expected minimal to be %v", testCase.num, testCase.minimal)
+		}
+	}
+
+	items := func(count int) [][]byte {
+		result := make([][]byte, count)
+		for i := range result {
+			result[i] = []byte{0x01}
+		}
+		return result
+	}
+
+	// Drops all but one of count initial items, after moving an extra element to the alt stack,
+	// so there are count+1 elements on the two stacks.
+	altStackScript := func(count int) Script {
+		script := Script{}
+		script.AddOpCode(0x51)
+		script.AddOpCode(0x6b) // OP_TOALTSTACK
+		for i := 1; i < count; i++ {
+			script.AddOpCode(0x75) // OP_DROP
+		}
+		script.AddOpCode(0x6c) // OP_FROMALTSTACK
+		script.AddOpCode(0x75)
+		return script
+	}
+
+	f = newTapscriptFixture(t, altStackScript(maxStackSize-1), altStackScript(maxStackSize))
+	if !f.spend(0, items(maxStackSize-1)...) {
+		t.Errorf("%v elements on the stack and alt stack should be allowed", maxStackSize)
+	}
+	if f.spend(1, items(maxStackSize)...) {
+		t.Errorf("%v elements on the stack and alt stack shouldn't be allowed", maxStackSize+1)
+	}
+
+	// Too many initial items fail before anything runs.
+	dropScript := func(drops int) Script {
+		script := Script{}
+		for i := 0; i < drops; i++ {
+			script.AddOpCode(0x75)
+		}
+		return script
+	}
+
+	f = newTapscriptFixture(t, dropScript(maxStackSize-1), dropScript(maxStackSize))
+	if !f.spend(0, items(maxStackSize)...) {
+		t.Errorf("%v initial items should be allowed", maxStackSize)
+	}
+	if f.spend(1, items(maxStackSize+1)...) {
+		t.Errorf("%v initial items shouldn't be allowed", maxStackSize+1)
+	}
+}
+
+func TestTaprootKeyPathSigning(t *testing.T) {
+
+	key := ecc.NewPrivateKey(big.NewInt(4001))
+	pub := key.PublicKey()
+	address, err := pub.P2TRAddress(nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _ := ParseAddress(address)
+	scriptPubKey := parsed.ScriptPubKey()
+
+	var prevTxHash [32]byte
+	copy(prevTxHash[:], utility.Hash256([]byte("taproot key path")))
+	txIn := NewTxIn(prevTxHash, 3, &Script{}, 0xfffffffd)
+	storePreviousOutput(txIn, 75000, hex.EncodeToString(scriptPubKey.RawData))
+
+	for _, hashType := range []byte{SIGHASH_DEFAULT, SIGHASH_ALL, SIGHASH_NONE | SIGHASH_ANYONECANPAY} {
+		tx := NewTx(2, []TxIn{txIn}, []TxOut{NewTxOut(70000, scriptPubKey)}, 0, false)
+		if err := tx.SignInput(0, &key, hashType); err != nil {
+			t.Fatal(err)
+		}
+		if expected := utility.IIF(hashType == SIGHASH_DEFAULT, 64, 65).(int); len(tx.TxIns[0].Witness[0]) != expected {
+			t.Errorf("0x%02x: expected a %v byte signature", hashType, expected)
+		}
+		if !tx.Verify() {
+			t.Errorf("0x%02x: key path spend didn't verify", hashType)
+		}
+
+		// The annex is signed, so adding one invalidates the signature.
+		tx.TxIns[0].Witness = append(tx.TxIns[0].Witness, []byte{0x50, 0x01})
+		if tx.VerifyInput(0) {
+			t.Errorf("0x%02x: key path spend verified with an annex added", hashType)
+		}
+	}
+
+	// Signed with an annex.
+	tx := NewTx(2, []TxIn{txIn}, []TxOut{NewTxOut(70000, scriptPubKey)}, 0, false)
+	annex := []byte{0x50, 0xde, 0xad}
+	tweaked, _ := key.TaprootTweak(nil)
+	hash, _ := tx.SigHashTaproot(0, tx.previousOutputs(), SIGHASH_DEFAULT, annex, nil)
+	sig, _ := tweaked.SignSchnorr(hash, nil)
+	tx.TxIns[0].Witness = [][]byte{sig.Serialize(), annex}
+	if !tx.VerifyInput(0) {
+		t.Error("Key path spend with an annex didn't verify")
+	}
+
+	otherKey := ecc.NewPrivateKey(big.NewInt(4002))
+	if err := tx.SignInput(0, &otherKey, SIGHASH_DEFAULT); err == nil {
+		t.Error("Signed for an output that belongs to another key")
+	}
+}
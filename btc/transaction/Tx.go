@@ -1,14 +1,26 @@
 package transaction
 
 import (
+	"bitcoin-go/ecc"
 	"bitcoin-go/utility"
 	"bytes"
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
 	"math/big"
 )
 
-const SIGHASH_ALL = 1
+// Signature hash types, the last byte of every signature. The base type picks which outputs are
+// signed: all of them, none, or the single one with the same index as the input. ANYONECANPAY can
+// be combined with any of them to sign only the input itself, leaving others free to add inputs.
+const (
+	SIGHASH_ALL          = 0x01
+	SIGHASH_NONE         = 0x02
+	SIGHASH_SINGLE       = 0x03
+	SIGHASH_ANYONECANPAY = 0x80
+)
 
 type Tx struct {
 	Version  uint32
@@ -22,54 +34,312 @@ func NewTx(version uint32, txIns []TxIn, txOuts []TxOut, lockTime uint32, testNe
 	return Tx{Version: version, TxIns: txIns, TxOuts: txOuts, LockTime: lockTime, TestNet: testNet}
 }
 
+// Parses a transaction in either the legacy or the SegWit (BIP144) serialization. Panics on
+// malformed input, use DecodeTx for untrusted data.
 func ParseTx(reader io.Reader, testnet bool) Tx {
-	version := utility.ReadUint32(reader, true)
+	tx, err := DecodeTx(reader, testnet)
+	if err != nil {
+		panic(err)
+	}
+	return tx
+}
+
+// Parses a transaction in either the legacy or the SegWit (BIP144) serialization, returning an
+// error if it's truncated or malformed. The latter has a zero marker byte where the input count
+// would be, followed by a flag byte (1), and the witnesses of all inputs after the outputs.
+func DecodeTx(reader io.Reader, testnet bool) (Tx, error) {
+	d := txDecoder{reader: reader}
+
+	version := d.readUint32()
+
+	txInCount := d.readVarInt()
 
-	txInCount := utility.ReadVarInt(reader)
+	// A transaction without inputs isn't valid, so a zero count is the SegWit marker.
+	segwit := false
+	if txInCount == 0 && d.err == nil {
+		if flag := d.readByte(); flag != 0x01 && d.err == nil {
+			return Tx{}, fmt.Errorf("unknown SegWit flag 0x%02x", flag)
+		}
+		segwit = true
+		txInCount = d.readVarInt()
+	}
+
+	// The counts aren't trusted to size anything, a bogus one just runs out of data.
+	txIns := make([]TxIn, 0)
+	for i := uint64(0); i < txInCount && d.err == nil; i++ {
+		txIns = append(txIns, d.readTxIn())
+	}
+
+	txOutCount := d.readVarInt()
 
-	txIns := make([]TxIn, txInCount)
-	for i := (uint64)(0); i < txInCount; i++ {
-		txIns[i] = ParseTxIn(reader)
+	txOuts := make([]TxOut, 0)
+	for i := uint64(0); i < txOutCount && d.err == nil; i++ {
+		txOuts = append(txOuts, d.readTxOut())
 	}
 
-	txOutCount := utility.ReadVarInt(reader)
+	if segwit {
+		for i := 0; i < len(txIns) && d.err == nil; i++ {
+			txIns[i].Witness = d.readWitness()
+		}
+	}
+
+	lockTime := d.readUint32()
+
+	if d.err != nil {
+		return Tx{}, d.err
+	}
+
+	// The SegWit serialization is only used when there's a witness, so there's a single way to serialize each transaction.
+	tx := Tx{Version: version, TxIns: txIns, TxOuts: txOuts, LockTime: lockTime, TestNet: testnet}
+	if segwit && !tx.HasWitness() {
+		return Tx{}, errors.New("SegWit serialization without any witness")
+	}
+
+	return tx, nil
+}
+
+// The largest size a serialized length can claim, the same limit as Bitcoin Core's.
+const maxSerializedSize = 0x02000000
+
+// Reads the parts of a transaction, remembering the first error so it only has to be checked at
+// the end. Once something failed every read returns zero values.
+type txDecoder struct {
+	reader io.Reader
+	err    error
+}
 
-	txOuts := make([]TxOut, txOutCount)
-	for i := (uint64)(0); i < txOutCount; i++ {
-		txOuts[i] = ParseTxOut(reader)
+func (d *txDecoder) readBytes(length uint64) []byte {
+	if d.err != nil {
+		return nil
 	}
+	if length > maxSerializedSize {
+		d.err = fmt.Errorf("length %v exceeds the maximum size", length)
+		return nil
+	}
+
+	// Read through a buffer so a bogus length doesn't allocate before the data turns out to be missing.
+	buffer := bytes.NewBuffer(make([]byte, 0))
+	n, err := io.CopyN(buffer, d.reader, int64(length))
+	if uint64(n) != length {
+		d.err = fmt.Errorf("transaction is truncated: %v", utility.IIF(err != nil, err, io.ErrUnexpectedEOF))
+		return nil
+	}
+	return buffer.Bytes()
+}
+
+func (d *txDecoder) readByte() byte {
+	if b := d.readBytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *txDecoder) readUint32() uint32 {
+	if b := d.readBytes(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *txDecoder) readUint64() uint64 {
+	if b := d.readBytes(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+// Reads a CompactSize, which has to use the shortest encoding for its value.
+func (d *txDecoder) readVarInt() uint64 {
+	var value, min uint64
+	switch first := d.readByte(); first {
+	case 0xfd:
+		if b := d.readBytes(2); b != nil {
+			value, min = uint64(binary.LittleEndian.Uint16(b)), 0xfd
+		}
+	case 0xfe:
+		value, min = uint64(d.readUint32()), 0x10000
+	case 0xff:
+		value, min = d.readUint64(), 0x100000000
+	default:
+		return uint64(first)
+	}
+
+	if d.err == nil && value < min {
+		d.err = fmt.Errorf("non-canonical CompactSize %v", value)
+	}
+	return value
+}
+
+func (d *txDecoder) readScript() Script {
+	return Script{RawData: d.readBytes(d.readVarInt())}
+}
+
+func (d *txDecoder) readTxIn() TxIn {
+	txIn := TxIn{}
+	copy(txIn.PreviousTxHash[:], utility.ReverseBytes(d.readBytes(32)))
+	txIn.PreviousTxId = d.readUint32()
+	script := d.readScript()
+	txIn.ScriptSignature = &script
+	txIn.Sequence = d.readUint32()
+	return txIn
+}
 
-	lockTime := utility.ReadUint32(reader, true)
+func (d *txDecoder) readTxOut() TxOut {
+	sats := d.readUint64()
+	return TxOut{Satoshis: sats, ScriptPubKey: d.readScript()}
+}
 
-	return Tx{Version: version, TxIns: txIns, TxOuts: txOuts, LockTime: lockTime, TestNet: testnet}
+func (d *txDecoder) readWitness() [][]byte {
+	count := d.readVarInt()
+	witness := make([][]byte, 0)
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		witness = append(witness, d.readBytes(d.readVarInt()))
+	}
+	return witness
 }
 
+// The transaction id (txid), which doesn't cover the witnesses.
 func (tx *Tx) Id() string {
 	return hex.EncodeToString(tx.Hash())
 }
 
 func (tx *Tx) Hash() []byte {
-	h := tx.SigHash(-1, nil)
-	return utility.ReverseBytes(h)
+	buff := bytes.NewBuffer(make([]byte, 0))
+	tx.serializeLegacy(buff)
+	return utility.ReverseBytes(utility.Hash256(buff.Bytes()))
 }
 
-func (tx *Tx) SigHash(txSigHash int, redeemScript *Script) []byte {
+// The witness transaction id (wtxid), which also covers the witnesses. It's the same as the
+// txid for transactions without any.
+func (tx *Tx) WitnessId() string {
+	return hex.EncodeToString(tx.WitnessHash())
+}
 
+func (tx *Tx) WitnessHash() []byte {
 	buff := bytes.NewBuffer(make([]byte, 0))
-	tx.Serialize(buff, txSigHash, redeemScript)
-	if txSigHash >= 0 {
-		utility.WriteUint32(buff, SIGHASH_ALL, true)
+	tx.Serialize(buff, -1, nil)
+	return utility.ReverseBytes(utility.Hash256(buff.Bytes()))
+}
+
+// Whether any of the inputs has a witness, which means the transaction is serialized in the
+// SegWit format.
+func (tx *Tx) HasWitness() bool {
+	for _, txIn := range tx.TxIns {
+		if len(txIn.Witness) > 0 {
+			return true
+		}
 	}
+	return false
+}
+
+// The legacy signature hash of an input for the hash type. P2SH inputs sign their redeem script
+// rather than the scriptPubKey. OP_CODESEPARATORs in the script aren't signed.
+func (tx *Tx) SigHash(index int, redeemScript *Script, hashType byte) []byte {
+	return tx.sigHashLegacy(index, redeemScript, uint32(hashType))
+}
+
+// SigHash with all 32 bits of the hash type that gets appended to the serialization. Only the
+// lowest byte can come from a signature, but Bitcoin Core's test vectors use random values.
+func (tx *Tx) sigHashLegacy(index int, redeemScript *Script, hashType uint32) []byte {
+
+	// The SIGHASH_SINGLE bug: without an output for the input, the original client signed the
+	// number one (an error value it didn't check for) and consensus has been stuck with it since.
+	if hashType&0x1f == SIGHASH_SINGLE && index >= len(tx.TxOuts) {
+		one := make([]byte, 32)
+		one[0] = 1
+		return one
+	}
+
+	if redeemScript == nil {
+		scriptPubKey := tx.TxIns[index].ScriptPubKey(tx.TestNet)
+		redeemScript = &scriptPubKey
+	}
+	scriptCode := redeemScript.withoutCodeSeparators()
+
+	buff := bytes.NewBuffer(make([]byte, 0))
+	tx.serializeForSigHash(buff, index, &scriptCode, byte(hashType))
+	utility.WriteUint32(buff, hashType, true)
 	return utility.Hash256(buff.Bytes())
 }
 
+// The BIP143 signature hash of a SegWit version 0 input. Rather than reserializing the whole
+// transaction for every input it commits to hashes of all outpoints, sequences and outputs (which
+// can be reused between inputs), and to the amount being spent so signers can't be lied to about
+// the fee. The scriptCode is the P2PKH script for P2WPKH inputs and the witness script for P2WSH.
+func (tx *Tx) SigHashSegWit(index int, scriptCode *Script, amount uint64, hashType byte) []byte {
+
+	txIn := tx.TxIns[index]
+	baseType := hashType & 0x1f
+	anyoneCanPay := hashType&SIGHASH_ANYONECANPAY != 0
+
+	// Whatever the hash type doesn't sign is left as zeros.
+	hashPrevouts := make([]byte, 32)
+	hashSequence := make([]byte, 32)
+	hashOutputs := make([]byte, 32)
+
+	if !anyoneCanPay {
+		prevouts := bytes.NewBuffer(make([]byte, 0))
+		for _, in := range tx.TxIns {
+			in.serializeOutpoint(prevouts)
+		}
+		hashPrevouts = utility.Hash256(prevouts.Bytes())
+	}
+
+	if !anyoneCanPay && baseType != SIGHASH_NONE && baseType != SIGHASH_SINGLE {
+		sequences := bytes.NewBuffer(make([]byte, 0))
+		for _, in := range tx.TxIns {
+			utility.WriteUint32(sequences, in.Sequence, true)
+		}
+		hashSequence = utility.Hash256(sequences.Bytes())
+	}
+
+	if baseType != SIGHASH_NONE && baseType != SIGHASH_SINGLE {
+		outputs := bytes.NewBuffer(make([]byte, 0))
+		for _, out := range tx.TxOuts {
+			out.Serialize(outputs)
+		}
+		hashOutputs = utility.Hash256(outputs.Bytes())
+	} else if baseType == SIGHASH_SINGLE && index < len(tx.TxOuts) {
+		output := bytes.NewBuffer(make([]byte, 0))
+		tx.TxOuts[index].Serialize(output)
+		hashOutputs = utility.Hash256(output.Bytes())
+	}
+
+	buff := bytes.NewBuffer(make([]byte, 0))
+	utility.WriteUint32(buff, tx.Version, true)
+	buff.Write(hashPrevouts)
+	buff.Write(hashSequence)
+	txIn.serializeOutpoint(buff)
+	scriptCode.Serialize(buff)
+	utility.WriteUint64(buff, amount, true)
+	utility.WriteUint32(buff, txIn.Sequence, true)
+	buff.Write(hashOutputs)
+	utility.WriteUint32(buff, tx.LockTime, true)
+	utility.WriteUint32(buff, uint32(hashType), true)
+
+	return utility.Hash256(buff.Bytes())
+}
+
+// Serializes the transaction. With a negative txSigHash that's the full transaction, in the SegWit
+// format if it has witnesses, otherwise it's the legacy SIGHASH_ALL serialization for that input.
 func (tx *Tx) Serialize(writer io.Writer, txSigHash int, redeemScript *Script) {
 
+	if txSigHash >= 0 {
+		tx.serializeForSigHash(writer, txSigHash, redeemScript, SIGHASH_ALL)
+		return
+	}
+
+	if !tx.HasWitness() {
+		tx.serializeLegacy(writer)
+		return
+	}
+
 	utility.WriteUint32(writer, tx.Version, true)
+	writer.Write([]byte{0x00, 0x01}) // Marker and flag
 	utility.WriteVarInt(writer, (uint64)(len(tx.TxIns)))
 
-	for i, txin := range tx.TxIns {
-		txin.Serialize(writer, i == txSigHash, tx.TestNet, redeemScript)
+	for _, txin := range tx.TxIns {
+		txin.Serialize(writer, false, tx.TestNet, nil)
 	}
 
 	utility.WriteVarInt(writer, (uint64)(len(tx.TxOuts)))
@@ -78,6 +348,92 @@ func (tx *Tx) Serialize(writer io.Writer, txSigHash int, redeemScript *Script) {
 		txout.Serialize(writer)
 	}
 
+	for _, txin := range tx.TxIns {
+		serializeWitness(writer, txin.Witness)
+	}
+
+	utility.WriteUint32(writer, tx.LockTime, true)
+}
+
+// The item count followed by the length prefixed items.
+func serializeWitness(writer io.Writer, witness [][]byte) {
+	utility.WriteVarInt(writer, (uint64)(len(witness)))
+	for _, item := range witness {
+		utility.WriteVarInt(writer, (uint64)(len(item)))
+		writer.Write(item)
+	}
+}
+
+func (tx *Tx) serializeLegacy(writer io.Writer) {
+
+	utility.WriteUint32(writer, tx.Version, true)
+	utility.WriteVarInt(writer, (uint64)(len(tx.TxIns)))
+
+	for _, txin := range tx.TxIns {
+		txin.Serialize(writer, false, tx.TestNet, nil)
+	}
+
+	utility.WriteVarInt(writer, (uint64)(len(tx.TxOuts)))
+
+	for _, txout := range tx.TxOuts {
+		txout.Serialize(writer)
+	}
+
+	utility.WriteUint32(writer, tx.LockTime, true)
+}
+
+// The transaction as it's signed by a legacy signature with the hash type (without the hash type
+// itself). The input being signed gets the scriptPubKey (or redeem script) as its script signature
+// and the others an empty one. NONE and SINGLE also zero the other inputs' sequences, so they can
+// be replaced, and ANYONECANPAY drops the other inputs altogether.
+func (tx *Tx) serializeForSigHash(writer io.Writer, index int, redeemScript *Script, hashType byte) {
+
+	baseType := hashType & 0x1f
+
+	utility.WriteUint32(writer, tx.Version, true)
+
+	if hashType&SIGHASH_ANYONECANPAY != 0 {
+		utility.WriteVarInt(writer, 1)
+		tx.TxIns[index].Serialize(writer, true, tx.TestNet, redeemScript)
+	} else {
+		utility.WriteVarInt(writer, (uint64)(len(tx.TxIns)))
+
+		for i, txin := range tx.TxIns {
+			if i == index {
+				txin.Serialize(writer, true, tx.TestNet, redeemScript)
+				continue
+			}
+
+			txin.serializeOutpoint(writer)
+			utility.WriteVarInt(writer, 0)
+			if baseType == SIGHASH_NONE || baseType == SIGHASH_SINGLE {
+				utility.WriteUint32(writer, 0, true)
+			} else {
+				utility.WriteUint32(writer, txin.Sequence, true)
+			}
+		}
+	}
+
+	switch baseType {
+	case SIGHASH_NONE:
+		utility.WriteVarInt(writer, 0)
+
+	case SIGHASH_SINGLE:
+		// The outputs before the input's are blanked to a value of -1 and an empty script.
+		utility.WriteVarInt(writer, (uint64)(index+1))
+		for i := 0; i < index; i++ {
+			utility.WriteUint64(writer, 0xffffffffffffffff, true)
+			utility.WriteVarInt(writer, 0)
+		}
+		tx.TxOuts[index].Serialize(writer)
+
+	default:
+		utility.WriteVarInt(writer, (uint64)(len(tx.TxOuts)))
+		for _, txout := range tx.TxOuts {
+			txout.Serialize(writer)
+		}
+	}
+
 	utility.WriteUint32(writer, tx.LockTime, true)
 }
 
@@ -110,33 +466,189 @@ func (tx *Tx) Verify() bool {
 	return true
 }
 
+// Verifies an input, fetching the outputs it spends.
 func (tx *Tx) VerifyInput(index int) bool {
+	return tx.verifyInput(index, tx.previousOutput(index), tx.previousOutputs)
+}
+
+// Verifies an input against the outputs spent by the transaction (prevOuts, in input order), rather
+// than fetching them. That's also how transactions that only exist locally can be verified.
+func (tx *Tx) VerifyInputWithPrevOuts(index int, prevOuts []TxOut) bool {
+	if len(prevOuts) != len(tx.TxIns) {
+		return false
+	}
+	return tx.verifyInput(index, prevOuts[index], func() []TxOut { return prevOuts })
+}
+
+// Verifies an input spending the output. Only Taproot signatures need all the outputs being spent,
+// which is why they're only looked up on demand.
+func (tx *Tx) verifyInput(index int, spent TxOut, prevOuts func() []TxOut) bool {
 	txIn := tx.TxIns[index]
-	scriptPubKey := txIn.ScriptPubKey(tx.TestNet)
+	scriptPubKey := spent.ScriptPubKey
 
 	var redeemScript *Script = nil
 	if scriptPubKey.IsPayToScriptHash() {
-		var redeemScriptBytes []byte = nil
-		sigOps := txIn.ScriptSignature.GetOperations()
-		redeemScriptBytes = sigOps[len(sigOps)-1].(AddDataToStackOperation).Data // The redeem script is the last operation in the signature.
+		// The redeem script is the last operation in the signature.
+		sigOps, err := txIn.ScriptSignature.Operations()
+		if err != nil || len(sigOps) == 0 {
+			return false
+		}
+		push, ok := sigOps[len(sigOps)-1].(AddDataToStackOperation)
+		if !ok {
+			return false
+		}
+		redeemScript = &Script{RawData: push.Data}
+	}
 
-		// Create a buffer with a prepended varint.
-		buff := bytes.NewBuffer(make([]byte, 0))
-		utility.WriteVarInt(buff, uint64(len(redeemScriptBytes)))
-		redeemScriptBytes = append(buff.Bytes(), redeemScriptBytes...)
-		buff = bytes.NewBuffer(redeemScriptBytes)
-		s := ParseScript(buff)
-		redeemScript = &s
+	// SegWit inputs, native or wrapped in P2SH, sign the BIP143 hash of the script the witness runs.
+	witnessProgram := &scriptPubKey
+	if redeemScript != nil {
+		witnessProgram = redeemScript
 	}
 
-	z := tx.SigHash(index, redeemScript)
+	// Signatures say which parts of the transaction they sign, so the hash depends on the signature.
+	// Legacy signatures sign the redeem script of P2SH inputs and the scriptPubKey of anything else.
+	scriptCode := &scriptPubKey
+	if redeemScript != nil {
+		scriptCode = redeemScript
+	}
+	sigHasher := func(hashType byte) *big.Int {
+		return new(big.Int).SetBytes(tx.SigHash(index, scriptCode, hashType))
+	}
 
-	hash := big.NewInt(0)
-	hash.SetBytes(z)
-	exec := NewScriptExecutor(&scriptPubKey, txIn.ScriptSignature, hash)
+	if version, program, ok := witnessProgram.WitnessProgram(); ok && version == 0 {
+		scriptCode, _, ok := witnessScript(program, txIn.Witness)
+		if !ok {
+			return false
+		}
+		amount := spent.Satoshis
+		sigHasher = func(hashType byte) *big.Int {
+			return new(big.Int).SetBytes(tx.SigHashSegWit(index, scriptCode, amount, hashType))
+		}
+	}
+
+	// Taproot signatures commit to all the outputs being spent.
+	taprootHasher := func(hashType byte, annex []byte, leafHash []byte) ([]byte, bool) {
+		hash, err := tx.SigHashTaproot(index, prevOuts(), hashType, annex, leafHash)
+		return hash, err == nil
+	}
+
+	exec := NewWitnessScriptExecutor(&scriptPubKey, txIn.ScriptSignature, txIn.Witness, sigHasher, taprootHasher)
 	return exec.Execute()
 }
 
+// Signs a P2PKH, P2WPKH or P2TR (key path) input with the key, committing to the parts of the
+// transaction the hash type selects. The signature goes in the script signature for P2PKH and in
+// the witness otherwise. The outputs being spent are fetched.
+func (tx *Tx) SignInput(index int, key *ecc.PrivateKey, hashType byte) error {
+	return tx.signInput(index, key, hashType, tx.previousOutput(index), tx.previousOutputs)
+}
+
+// Signs an input like SignInput, with the outputs spent by the transaction (prevOuts, in input
+// order) given rather than fetched.
+func (tx *Tx) SignInputWithPrevOuts(index int, key *ecc.PrivateKey, hashType byte, prevOuts []TxOut) error {
+	if len(prevOuts) != len(tx.TxIns) {
+		return fmt.Errorf("expected %v previous outputs, got %v", len(tx.TxIns), len(prevOuts))
+	}
+	return tx.signInput(index, key, hashType, prevOuts[index], func() []TxOut { return prevOuts })
+}
+
+func (tx *Tx) signInput(index int, key *ecc.PrivateKey, hashType byte, spent TxOut, prevOuts func() []TxOut) error {
+
+	// Legacy inputs would sign the SIGHASH_SINGLE bug's constant, which anyone could reuse for any
+	// transaction spending the same output, and the other versions sign no output at all.
+	if hashType&0x1f == SIGHASH_SINGLE && index >= len(tx.TxOuts) {
+		return fmt.Errorf("SIGHASH_SINGLE needs an output for input %v", index)
+	}
+
+	txIn := &tx.TxIns[index]
+	pub := key.PublicKey()
+
+	address, err := AddressFromScript(spent.ScriptPubKey, tx.TestNet)
+	if err != nil {
+		return err
+	}
+
+	switch address.Type() {
+	case P2WPKH:
+		if !bytes.Equal(address.Program(), pub.Hash160(true)) {
+			return errors.New("input isn't locked to this key")
+		}
+
+		scriptCode := Address{addressType: P2PKH, program: address.Program()}.ScriptPubKey()
+		z := tx.SigHashSegWit(index, &scriptCode, spent.Satoshis, hashType)
+		sig := key.Sign(new(big.Int).SetBytes(z))
+
+		txIn.ScriptSignature = &Script{}
+		txIn.Witness = [][]byte{append(sig.ToDER(), hashType), pub.ToSEC(true)}
+
+	case P2PKH:
+		compressed := true
+		if !bytes.Equal(address.Program(), pub.Hash160(true)) {
+			compressed = false
+			if !bytes.Equal(address.Program(), pub.Hash160(false)) {
+				return errors.New("input isn't locked to this key")
+			}
+		}
+
+		z := tx.SigHash(index, &spent.ScriptPubKey, hashType)
+		sig := key.Sign(new(big.Int).SetBytes(z))
+
+		scriptSig := Script{}
+		scriptSig.AddData(append(sig.ToDER(), hashType))
+		scriptSig.AddData(pub.ToSEC(compressed))
+		txIn.ScriptSignature = &scriptSig
+
+	case P2TR:
+		// Only the key path of outputs without a script tree.
+		tweaked, err := key.TaprootTweak(nil)
+		if err != nil {
+			return err
+		}
+		outputKey := tweaked.PublicKey()
+		if !bytes.Equal(address.Program(), outputKey.ToXOnly()) {
+			return errors.New("input isn't locked to this key")
+		}
+
+		z, err := tx.SigHashTaproot(index, prevOuts(), hashType, nil, nil)
+		if err != nil {
+			return err
+		}
+		sig, err := tweaked.SignSchnorr(z, nil)
+		if err != nil {
+			return err
+		}
+
+		serialized := sig.Serialize()
+		if hashType != SIGHASH_DEFAULT {
+			serialized = append(serialized, hashType)
+		}
+		txIn.ScriptSignature = &Script{}
+		txIn.Witness = [][]byte{serialized}
+
+	default:
+		return fmt.Errorf("signing %v inputs is not supported", address.Type())
+	}
+
+	return nil
+}
+
+// The output an input spends, fetched.
+func (tx *Tx) previousOutput(index int) TxOut {
+	txIn := tx.TxIns[index]
+	prevTx := txIn.PreviousTx(tx.TestNet)
+	return prevTx.TxOuts[txIn.PreviousTxId]
+}
+
+// The outputs spent by all the inputs, which Taproot signatures commit to.
+func (tx *Tx) previousOutputs() []TxOut {
+	prevOuts := make([]TxOut, len(tx.TxIns))
+	for i := range tx.TxIns {
+		prevOuts[i] = tx.previousOutput(i)
+	}
+	return prevOuts
+}
+
 func (tx *Tx) IsCoinbase() bool {
 	if len(tx.TxIns) != 1 {
 		return false
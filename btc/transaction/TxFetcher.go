@@ -7,68 +7,94 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"sync"
 )
 
+// Fetches transactions from a block explorer, caching them for the whole process. It's shared by
+// all goroutines, so the cache is behind a lock.
 type TxFetcher struct {
+	lock  sync.Mutex
 	cache map[[32]byte]Tx
 }
 
 var once sync.Once
-var singleton TxFetcher
+var singleton *TxFetcher
 
-func GetTxFetcher() TxFetcher {
+func GetTxFetcher() *TxFetcher {
 
 	once.Do(func() {
-		singleton = TxFetcher{}
+		singleton = &TxFetcher{}
 		singleton.cache = make(map[[32]byte]Tx)
 	})
 
 	return singleton
 }
 
+// Fetches a transaction by its id. Panics if it can't be fetched, use Fetch to handle errors.
 func (f *TxFetcher) FetchById(txId [32]byte, testNet bool, fresh bool) Tx {
-	tx, ok := singleton.cache[txId]
+	tx, err := f.Fetch(txId, testNet, fresh)
+	if err != nil {
+		panic(err)
+	}
+	return tx
+}
+
+// Fetches a transaction by its id, from the cache unless fresh is set.
+func (f *TxFetcher) Fetch(txId [32]byte, testNet bool, fresh bool) (Tx, error) {
+	f.lock.Lock()
+	tx, ok := f.cache[txId]
+	f.lock.Unlock()
+
 	if !ok || fresh {
-		tx = f.fetchTransaction(txId, testNet)
+		var err error
+		if tx, err = f.fetchTransaction(txId, testNet); err != nil {
+			return Tx{}, err
+		}
+
+		f.lock.Lock()
 		f.cache[txId] = tx // TODO: Create a disk-persisting cache.
+		f.lock.Unlock()
 	}
 
-	return tx
+	return tx, nil
 }
 
-func (f *TxFetcher) fetchTransaction(txId [32]byte, testNet bool) Tx {
+func (f *TxFetcher) fetchTransaction(txId [32]byte, testNet bool) (Tx, error) {
 	url := utility.IIF(testNet, "https://blockstream.info/testnet/api/tx/%v/hex", "https://blockstream.info/api/tx/%v/hex").(string)
 
 	url = fmt.Sprintf(url, hex.EncodeToString(txId[:]))
 
 	client := http.DefaultClient
 	rsp, err := client.Get(url)
-
 	if err != nil {
-		panic(err)
+		return Tx{}, err
 	}
+	defer rsp.Body.Close()
 
-	// The body comes back as an ASCII string of the hex.
-	b, _ := io.ReadAll(rsp.Body)
-	s := (string)(b)
-	b, _ = hex.DecodeString(s)
-
-	/*
-		TODO: Figure out why they do this:
-		if raw[4] == 0:
-			raw = raw[:4] + raw[6:]
-			tx = Tx.parse(BytesIO(raw), testnet=testnet)
-			tx.locktime = little_endian_to_int(raw[-4:])
+	if rsp.StatusCode != http.StatusOK {
+		return Tx{}, fmt.Errorf("fetching transaction %x: %v", txId, rsp.Status)
+	}
 
-	*/
+	// The body comes back as an ASCII string of the hex.
+	b, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return Tx{}, err
+	}
+	b, err = hex.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil {
+		return Tx{}, fmt.Errorf("fetching transaction %x: %v", txId, err)
+	}
 
-	reader := bytes.NewBuffer(b)
-	tx := ParseTx(reader, testNet)
-	txHash := tx.Hash()
+	// DecodeTx handles the SegWit serialization, so the witnesses are kept and the id is still
+	// computed without them.
+	tx, err := DecodeTx(bytes.NewReader(b), testNet)
+	if err != nil {
+		return Tx{}, fmt.Errorf("fetching transaction %x: %v", txId, err)
+	}
 
-	if !bytes.Equal(txHash, txId[:]) {
-		fmt.Println("Sanity check broke: Id mismatch between requested and received Transaction!")
+	if !bytes.Equal(tx.Hash(), txId[:]) {
+		return Tx{}, fmt.Errorf("fetched transaction has id %v, not %x", tx.Id(), txId)
 	}
-	return tx
+	return tx, nil
 }
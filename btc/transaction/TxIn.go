@@ -10,6 +10,7 @@ type TxIn struct {
 	PreviousTxId    uint32
 	ScriptSignature *Script
 	Sequence        uint32
+	Witness         [][]byte // The witness stack, empty for legacy inputs
 }
 
 func NewTxIn(prevTxHash [32]byte, prevTxId uint32, scriptSig *Script, sequence uint32) TxIn {
@@ -29,20 +30,17 @@ func ParseTxIn(reader io.Reader) TxIn {
 
 func (txin *TxIn) Serialize(writer io.Writer, sigHash bool, testNet bool, redeemScript *Script) {
 
-	var reversed [32]byte
-	copy(reversed[:], txin.PreviousTxHash[:])
-	writer.Write(utility.ReverseBytes(reversed[:]))
-	utility.WriteUint32(writer, txin.PreviousTxId, true)
+	txin.serializeOutpoint(writer)
 
 	if sigHash {
-		spk := txin.ScriptPubKey(testNet)
 		clone := Script{}
 
+		// The scriptPubKey is only fetched when no script was given to sign.
 		if redeemScript != nil {
 			clone.RawData = redeemScript.RawData
 		} else {
+			spk := txin.ScriptPubKey(testNet)
 			clone.RawData = append(clone.RawData, spk.RawData...)
-
 		}
 		clone.Serialize(writer)
 	} else {
@@ -52,6 +50,14 @@ func (txin *TxIn) Serialize(writer io.Writer, sigHash bool, testNet bool, redeem
 	utility.WriteUint32(writer, txin.Sequence, true)
 }
 
+// The previous transaction hash (little endian) and output index being spent.
+func (txin *TxIn) serializeOutpoint(writer io.Writer) {
+	var reversed [32]byte
+	copy(reversed[:], txin.PreviousTxHash[:])
+	writer.Write(utility.ReverseBytes(reversed[:]))
+	utility.WriteUint32(writer, txin.PreviousTxId, true)
+}
+
 func (txin *TxIn) Value(testNet bool) uint64 {
 	tx := txin.PreviousTx(testNet)
 	return tx.TxOuts[txin.PreviousTxId].Satoshis
@@ -1,8 +1,14 @@
 package transaction
 
 import (
+	"bitcoin-go/ecc"
+	"bitcoin-go/utility"
 	"bytes"
 	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"os"
+	"strings"
 	"testing"
 )
 
@@ -212,7 +218,7 @@ func TestSigHash(t *testing.T) {
 	fetch := GetTxFetcher()
 	tx := fetch.FetchById(txHash, false, false)
 	expected, _ := hex.DecodeString("27e0c5994dec7824e56dec6b2fcb342eb7cdb0d0957c2fce9882f715e85d81a6")
-	hash := tx.SigHash(0, nil)
+	hash := tx.SigHash(0, nil, SIGHASH_ALL)
 	if !bytes.Equal(hash, expected) {
 		t.Error()
 	}
@@ -304,3 +310,474 @@ func roundTripParseAndSerializationCheck(encodedHex string) bool {
 
 	return bytes.Equal(b, b2)
 }
+
+// Signed transactions from the BIP143 examples: a P2PK and a native P2WPKH input, and a single
+// P2SH-P2WPKH input.
+const segwitP2WPKHTx = "01000000000102fff7f7881a8099afa6940d42d1e7f6362bec38171ea3edf433541db4e4ad969f00000000494830450221008b9d1dc26ba6a9cb62127b02742fa9d754cd3bebf337f7a55d114c8e5cdd30be022040529b194ba3f9281a99f2b1c0a19c0489bc22ede944ccf4ecbab4cc618ef3ed01eeffffffef51e1b804cc89d182d279655c3aa89e815b1b309fe287d9b2b55d57b90ec68a0100000000ffffffff02202cb206000000001976a9148280b37df378db99f66f85c95a783a76ac7a6d5988ac9093510d000000001976a9143bde42dbee7e4dbe6a21b2d50ce2f0167faa815988ac000247304402203609e17b84f6a7d30c80bfa610b5b4542f32a8a0d5447a12fb1366d7f01cc44a0220573a954c4518331561406f90300e8f3358f51928d43c212a8caed02de67eebee0121025476c2e83188368da1ff3e292e7acafcdb3566bb0ad253f62fc70f07aeee635711000000"
+const segwitP2SHP2WPKHTx = "01000000000101db6b1b20aa0fd7b23880be2ecbd4a98130974cf4748fb66092ac4d3ceb1a5477010000001716001479091972186c449eb1ded22b78e40d009bdf0089feffffff02b8b4eb0b000000001976a914a457b684d7f0d539a46a45bbc043f35b59d0d96388ac0008af2f000000001976a914fd270b1ee6abcaea97fea7ad0402e8bd8ad6d77c88ac02473044022047ac8e878352d3ebbde1c94ce3a10d057c24175747116f8288e5d794d12d482f0220217f36a485cae903c713331d877c1f64677e3622ad4010726870540656fe9dcb012103ad1d8e89212f0b92c74d23bb710c00662ad1470198ac48c43f7d6f93a2a2687392040000"
+
+// The BOLT 3 commitment transaction with seven outputs, spending a 2-of-2 P2WSH funding output,
+// and its first HTLC transaction, which spends one of the commitment's P2WSH outputs.
+const segwitP2WSHTx = "02000000000101bef67e4e2fb9ddeeb3461973cd4c62abb35050b1add772995b820b584a488489000000000038b02b8007e80300000000000022002052bfef0479d7b293c27e0f1eb294bea154c63a3294ef092c19af51409bce0e2ad007000000000000220020403d394747cae42e98ff01734ad5c08f82ba123d3d9a620abda88989651e2ab5d007000000000000220020748eba944fedc8827f6b06bc44678f93c0f9e6078b35c6331ed31e75f8ce0c2db80b000000000000220020c20b5d1f8584fd90443e7b7b720136174fa4b9333c261d04dbbd012635c0f419a00f0000000000002200208c48d15160397c9731df9bc3b236656efb6665fbfe92b4a6878e88a499f741c4c0c62d0000000000160014ccf1af2f2aabee14bb40fa3851ab2301de843110e09c6a00000000002200204adb4e2f00643db396dd120d4e7dc17625f5f2c11a40d857accc862d6b7dd80e040048304502210094bfd8f5572ac0157ec76a9551b6c5216a4538c07cd13a51af4a54cb26fa14320220768efce8ce6f4a5efac875142ff19237c011343670adf9c7ac69704a120d116301483045022100a5c01383d3ec646d97e40f44318d49def817fcd61a0ef18008a665b3e151785502203e648efddd5838981ef55ec954be69c4a652d021e6081a100d034de366815e9b01475221023da092f6980e58d2c037173180e9a465476026ee50f96695963e8efe436f54eb21030e9f7b623d2ccc7c9bd44d66d5ce21ce504c0acf6385a132cec6d3c39fa711c152ae3e195220"
+const segwitP2WSHSpendTx = "020000000001018323148ce2419f21ca3d6780053747715832e18ac780931a514b187768882bb60000000000000000000122020000000000002200204adb4e2f00643db396dd120d4e7dc17625f5f2c11a40d857accc862d6b7dd80e05004730440220385a5afe75632f50128cbb029ee95c80156b5b4744beddc729ad339c9ca432c802202ba5f48550cad3379ac75b9b4fedb86a35baa6947f16ba5037fb8b11ab3437400147304402205999590b8a79fa346e003a68fd40366397119b2b0cdf37b149968d6bc6fbcc4702202b1e1fb5ab7864931caed4e732c359e0fe3d86a548b557be2246efb1708d579a012000000000000000000000000000000000000000000000000000000000000000008a76a91414011f7254d96b819c76986c277d115efce6f7b58763ac67210394854aa6eab5b2a8122cc726e9dded053a2184d88256816826d6231c068d4a5b7c8201208763a914b8bcb07f6344b42ab04250c86a6e8b75d3fdbbc688527c21030d417a46946384f88d5f3337267c5e579765875dc4daca813e21734b140639e752ae677502f401b175ac686800000000"
+
+// The signed transaction from the BIP341 key path spending vectors. It has P2TR, P2WPKH and P2PKH
+// inputs.
+const segwitP2TRTx = "020000000001097de20cbff686da83a54981d2b9bab3586f4ca7e48f57f5b55963115f3b334e9c010000000000000000d7b7cab57b1393ace2d064f4d4a2cb8af6def61273e127517d44759b6dafdd990000000000fffffffff8e1f583384333689228c5d28eac13366be082dc57441760d957275419a41842000000006b4830450221008f3b8f8f0537c420654d2283673a761b7ee2ea3c130753103e08ce79201cf32a022079e7ab904a1980ef1c5890b648c8783f4d10103dd62f740d13daa79e298d50c201210279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798fffffffff0689180aa63b30cb162a73c6d2a38b7eeda2a83ece74310fda0843ad604853b0100000000feffffffaa5202bdf6d8ccd2ee0f0202afbbb7461d9264a25e5bfd3c5a52ee1239e0ba6c0000000000feffffff956149bdc66faa968eb2be2d2faa29718acbfe3941215893a2a3446d32acd050000000000000000000e664b9773b88c09c32cb70a2a3e4da0ced63b7ba3b22f848531bbb1d5d5f4c94010000000000000000e9aa6b8e6c9de67619e6a3924ae25696bb7b694bb677a632a74ef7eadfd4eabf0000000000ffffffffa778eb6a263dc090464cd125c466b5a99667720b1c110468831d058aa1b82af10100000000ffffffff0200ca9a3b000000001976a91406afd46bcdfd22ef94ac122aa11f241244a37ecc88ac807840cb0000000020ac9a87f5594be208f8532db38cff670c450ed2fea8fcdefcc9a663f78bab962b0141ed7c1647cb97379e76892be0cacff57ec4a7102aa24296ca39af7541246d8ff14d38958d4cc1e2e478e4d4a764bbfd835b16d4e314b72937b29833060b87276c030141052aedffc554b41f52b521071793a6b88d6dbca9dba94cf34c83696de0c1ec35ca9c5ed4ab28059bd606a4f3a657eec0bb96661d42921b5f50a95ad33675b54f83000141ff45f742a876139946a149ab4d9185574b98dc919d2eb6754f8abaa59d18b025637a3aa043b91817739554f4ed2026cf8022dbd83e351ce1fabc272841d2510a010140b4010dd48a617db09926f729e79c33ae0b4e94b79f04a1ae93ede6315eb3669de185a17d2b0ac9ee09fd4c64b678a0b61a0a86fa888a273c8511be83bfd6810f0247304402202b795e4de72646d76eab3f0ab27dfa30b810e856ff3a46c9a702df53bb0d8cc302203ccc4d822edab5f35caddb10af1be93583526ccfbade4b4ead350781e2f8adcd012102f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f90141a3785919a2ce3c4ce26f298c3d51619bc474ae24014bcdd31328cd8cfbab2eff3395fa0a16fe5f486d12f22a9cedded5ae74feb4bbe5351346508c5405bcfee0020141ea0c6ba90763c2d3a296ad82ba45881abb4f426b3f87af162dd24d5109edc1cdd11915095ba47c3a9963dc1e6c432939872bc49212fe34c632cd3ab9fed429c4820141bbc9584a11074e83bc8c6759ec55401f0ae7b03ef290c3139814f545b58a9f8127258000874f44bc46db7646322107d4d86aec8e73b8719a61fff761d75b5dd9810065cd1d"
+
+func TestSegWitParse(t *testing.T) {
+
+	for _, raw := range []string{segwitP2WPKHTx, segwitP2SHP2WPKHTx, segwitP2WSHTx, segwitP2WSHSpendTx, segwitP2TRTx} {
+		if !roundTripParseAndSerializationCheck(raw) {
+			t.Errorf("%v didn't round trip", raw)
+		}
+	}
+
+	b, _ := hex.DecodeString(segwitP2WPKHTx)
+	tx := ParseTx(bytes.NewBuffer(b), false)
+
+	if len(tx.TxIns) != 2 || len(tx.TxOuts) != 2 || tx.LockTime != 0x11 {
+		t.Fatalf("Unexpected transaction shape: %v inputs, %v outputs, locktime %v", len(tx.TxIns), len(tx.TxOuts), tx.LockTime)
+	}
+	if len(tx.TxIns[0].Witness) != 0 {
+		t.Error("The P2PK input shouldn't have a witness")
+	}
+	witness := tx.TxIns[1].Witness
+	if len(witness) != 2 || len(witness[0]) != 71 || hex.EncodeToString(witness[1]) != "025476c2e83188368da1ff3e292e7acafcdb3566bb0ad253f62fc70f07aeee6357" {
+		t.Errorf("Unexpected witness %x", witness)
+	}
+}
+
+func TestSegWitIds(t *testing.T) {
+
+	// Ids computed from the raw transactions by a separate implementation of BIP141.
+	testCases := []struct {
+		raw   string
+		txid  string
+		wtxid string
+	}{
+		{segwitP2WPKHTx, "e8151a2af31c368a35053ddd4bdb285a8595c769a3ad83e0fa02314a602d4609", "c36c38370907df2324d9ce9d149d191192f338b37665a82e78e76a12c909b762"},
+		{segwitP2SHP2WPKHTx, "ef48d9d0f595052e0f8cdcf825f7a5e50b6a388a81f206f3f4846e5ecd7a0c23", "680f483b2bf6c5dcbf111e69e885ba248a41a5e92070cfb0afec3cfc49a9fabb"},
+		{segwitP2WSHTx, "b62b886877184b511a9380c78ae132587147370580673dca219f41e28c142383", "614ff52a7e081b9543650a975d7c0aa72de7e56e484146bd42fd3299feee6302"},
+		{segwitP2WSHSpendTx, "d8a8fdd02fc15421526f70e87e4c052cbf34589dc482daaf690757f8bd204813", "61fed03de031ca2d672cdffe3d3550152d103bd632d73b5ee55b0fad74abd9ea"},
+		{segwitP2TRTx, "fea03dc5c362e2ebd71f90960803aaa2cdbbc6cd536135f49980afedc19e3552", "4a5d2b15622b0c8e857527a6a1fc3c614cf7991aad19548cae678aa8306becf7"},
+	}
+
+	for _, testCase := range testCases {
+		b, _ := hex.DecodeString(testCase.raw)
+		tx := ParseTx(bytes.NewBuffer(b), false)
+
+		if tx.Id() != testCase.txid {
+			t.Errorf("Expected txid %v, got %v", testCase.txid, tx.Id())
+		}
+		if tx.WitnessId() != testCase.wtxid {
+			t.Errorf("Expected wtxid %v, got %v", testCase.wtxid, tx.WitnessId())
+		}
+
+		// Without witnesses it's serialized the legacy way, which is what the txid is the hash of.
+		for i := range tx.TxIns {
+			tx.TxIns[i].Witness = nil
+		}
+		writer := bytes.NewBuffer(make([]byte, 0))
+		tx.Serialize(writer, -1, nil)
+		if id := hex.EncodeToString(utility.ReverseBytes(utility.Hash256(writer.Bytes()))); id != testCase.txid {
+			t.Errorf("%v: legacy serialization hashes to %v", testCase.txid, id)
+		}
+		if tx.Id() != testCase.txid || tx.WitnessId() != testCase.txid {
+			t.Errorf("%v: txid and wtxid should both be the txid without witnesses, got %v and %v", testCase.txid, tx.Id(), tx.WitnessId())
+		}
+	}
+
+	// The HTLC transaction was made by a different program than this one and refers to the
+	// commitment transaction by its txid.
+	b, _ := hex.DecodeString(segwitP2WSHSpendTx)
+	spend := ParseTx(bytes.NewBuffer(b), false)
+	if prevTxId := hex.EncodeToString(spend.TxIns[0].PreviousTxHash[:]); prevTxId != testCases[2].txid {
+		t.Errorf("The HTLC transaction spends %v, expected %v", prevTxId, testCases[2].txid)
+	}
+}
+
+func TestSegWitInvalidFlag(t *testing.T) {
+
+	raw := strings.Replace(segwitP2SHP2WPKHTx, "010000000001", "010000000002", 1)
+	b, _ := hex.DecodeString(raw)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for an unknown flag")
+		}
+	}()
+	ParseTx(bytes.NewBuffer(b), false)
+}
+
+func TestDecodeTxMalformed(t *testing.T) {
+
+	b, _ := hex.DecodeString(segwitP2SHP2WPKHTx)
+	tx, err := DecodeTx(bytes.NewReader(b), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := bytes.NewBuffer(make([]byte, 0))
+	tx.Serialize(writer, -1, nil)
+	if !bytes.Equal(writer.Bytes(), b) {
+		t.Errorf("Expected %x, got %x", b, writer.Bytes())
+	}
+
+	legacy := segwitP2SHP2WSHTx
+	outpoint := "36641869ca081e70f394c6948e8af409e18b619df2ed74aa106c1ca29787b96e01000000"
+
+	testCases := map[string]string{
+		"empty":                     "",
+		"unknown flag":              strings.Replace(segwitP2SHP2WPKHTx, "010000000001", "010000000002", 1),
+		"truncated":                 segwitP2SHP2WPKHTx[:len(segwitP2SHP2WPKHTx)-10],
+		"truncated witness":         segwitP2SHP2WPKHTx[:len(segwitP2SHP2WPKHTx)-80],
+		"witness without items":     legacy[:8] + "0001" + legacy[8:len(legacy)-8] + "00" + legacy[len(legacy)-8:],
+		"huge input count":          "01000000ffffffffffffffffff" + outpoint,
+		"script longer than data":   "0100000001" + outpoint + "fe00000001" + "00",
+		"script over the size cap":  "0100000001" + outpoint + "ff0000000001000000",
+		"non-canonical input count": "01000000fd0100" + outpoint + "00ffffffff0000000000",
+	}
+
+	for name, raw := range testCases {
+		b, _ := hex.DecodeString(raw)
+		if _, err := DecodeTx(bytes.NewReader(b), false); err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
+
+// Puts a stand-in for the transaction an input spends in the fetcher's cache, with just the
+// output being spent, so inputs can be verified without going to the network.
+func storePreviousOutput(txIn TxIn, satoshis uint64, scriptPubKeyHex string) {
+	raw, _ := hex.DecodeString(scriptPubKeyHex)
+	prevTx := Tx{TxOuts: make([]TxOut, txIn.PreviousTxId+1)}
+	prevTx.TxOuts[txIn.PreviousTxId] = NewTxOut(satoshis, Script{RawData: raw})
+
+	fetcher := GetTxFetcher()
+	fetcher.lock.Lock()
+	fetcher.cache[txIn.PreviousTxHash] = prevTx
+	fetcher.lock.Unlock()
+}
+
+// The unsigned P2SH-P2WSH (6-of-6 multisig) transaction from the BIP143 examples.
+const segwitP2SHP2WSHTx = "010000000136641869ca081e70f394c6948e8af409e18b619df2ed74aa106c1ca29787b96e0100000000ffffffff0200e9a435000000001976a914389ffce9cd9ae88dcc0631e88a821ffdbe9bfe2688acc0832f05000000001976a9147480a33f950689af511e6e84c138dbbd3c3ee41588ac00000000"
+const segwitP2SHP2WSHWitnessScript = "56210307b8ae49ac90a048e9b53357a2354b3334e9c8bee813ecb98e99a7e07e8c3ba32103b28f0c28bfab54554ae8c658ac5c3e0ce6e79ad336331f78c428dd43eea8449b21034b8113d703413d57761b8b9781957b8c0ac1dfe69f492580ca4195f50376ba4a21033400f6afecb833092a9a21cfdf1ed1376e58c5d1f47de74683123987e967a8f42103a6d48b1131e94ba04d9737d61acdaa1322008af9602b3b14862c07a1789aac162102d8b661b0b3302ee2f162b09e07a55ad5dfbe673a9f01d9f0c19617681024306b56ae"
+
+func TestSigHashSegWit(t *testing.T) {
+
+	testCases := []struct {
+		tx         string
+		index      int
+		scriptCode string
+		amount     uint64
+		hashType   byte
+		expected   string
+	}{
+		{segwitP2WPKHTx, 1, "76a9141d0f172a0ecb48aee1be1f2687d2963ae33f71a188ac", 600000000, SIGHASH_ALL, "c37af31116d1b27caf68aae9e3ac82f1477929014d5b917657d0eb49478cb670"},
+		{segwitP2SHP2WPKHTx, 0, "76a91479091972186c449eb1ded22b78e40d009bdf008988ac", 1000000000, SIGHASH_ALL, "64f3b0f4dd2bb3aa1ce8566d220cc74dda9df97d8490cc81d89d735c92e59fb6"},
+		{segwitP2SHP2WSHTx, 0, segwitP2SHP2WSHWitnessScript, 987654321, SIGHASH_ALL, "185c0be5263dce5b4bb50a047973c1b6272bfbd0103a89444597dc40b248ee7c"},
+		{segwitP2SHP2WSHTx, 0, segwitP2SHP2WSHWitnessScript, 987654321, SIGHASH_NONE, "e9733bc60ea13c95c6527066bb975a2ff29a925e80aa14c213f686cbae5d2f36"},
+		{segwitP2SHP2WSHTx, 0, segwitP2SHP2WSHWitnessScript, 987654321, SIGHASH_SINGLE, "1e1f1c303dc025bd664acb72e583e933fae4cff9148bf78c157d1e8f78530aea"},
+		{segwitP2SHP2WSHTx, 0, segwitP2SHP2WSHWitnessScript, 987654321, SIGHASH_ALL | SIGHASH_ANYONECANPAY, "2a67f03e63a6a422125878b40b82da593be8d4efaafe88ee528af6e5a9955c6e"},
+		{segwitP2SHP2WSHTx, 0, segwitP2SHP2WSHWitnessScript, 987654321, SIGHASH_NONE | SIGHASH_ANYONECANPAY, "781ba15f3779d5542ce8ecb5c18716733a5ee42a6f51488ec96154934e2c890a"},
+		{segwitP2SHP2WSHTx, 0, segwitP2SHP2WSHWitnessScript, 987654321, SIGHASH_SINGLE | SIGHASH_ANYONECANPAY, "511e8e52ed574121fc1b654970395502128263f62662e076dc6baf05c2e6a99b"},
+	}
+
+	for _, testCase := range testCases {
+		b, _ := hex.DecodeString(testCase.tx)
+		tx := ParseTx(bytes.NewBuffer(b), false)
+		raw, _ := hex.DecodeString(testCase.scriptCode)
+
+		hash := tx.SigHashSegWit(testCase.index, &Script{RawData: raw}, testCase.amount, testCase.hashType)
+		if actual := hex.EncodeToString(hash); actual != testCase.expected {
+			t.Errorf("Expected %v, got %v", testCase.expected, actual)
+		}
+	}
+}
+
+func TestVerifySegWit(t *testing.T) {
+
+	b, _ := hex.DecodeString(segwitP2WPKHTx)
+	tx := ParseTx(bytes.NewBuffer(b), false)
+	storePreviousOutput(tx.TxIns[0], 625000000, "2103c9f4836b9a4f77fc0d81f7bcb01b7f1b35916864b9476c241ce9fc198bd25432ac")
+	storePreviousOutput(tx.TxIns[1], 600000000, "00141d0f172a0ecb48aee1be1f2687d2963ae33f71a1")
+
+	if !tx.Verify() {
+		t.Error("Native P2WPKH transaction should verify")
+	}
+
+	// The amount is signed, so a different one makes the signature invalid.
+	storePreviousOutput(tx.TxIns[1], 600000001, "00141d0f172a0ecb48aee1be1f2687d2963ae33f71a1")
+	if tx.VerifyInput(1) {
+		t.Error("P2WPKH input shouldn't verify with the wrong amount")
+	}
+	storePreviousOutput(tx.TxIns[1], 600000000, "00141d0f172a0ecb48aee1be1f2687d2963ae33f71a1")
+
+	// Native SegWit inputs must have an empty script signature.
+	tx.TxIns[1].ScriptSignature = &Script{RawData: []byte{0x51}}
+	if tx.VerifyInput(1) {
+		t.Error("P2WPKH input shouldn't verify with a script signature")
+	}
+
+	b, _ = hex.DecodeString(segwitP2SHP2WPKHTx)
+	tx = ParseTx(bytes.NewBuffer(b), false)
+	storePreviousOutput(tx.TxIns[0], 1000000000, "a9144733f37cf4db86fbc2efed2500b4f4e49f31202387")
+
+	if !tx.Verify() {
+		t.Error("P2SH-P2WPKH transaction should verify")
+	}
+
+	tx.TxIns[0].Witness[0][10] ^= 0x01
+	if tx.VerifyInput(0) {
+		t.Error("P2SH-P2WPKH input shouldn't verify with a corrupted signature")
+	}
+}
+
+func TestVerifyP2WSH(t *testing.T) {
+
+	key1 := ecc.NewPrivateKey(big.NewInt(1001))
+	key2 := ecc.NewPrivateKey(big.NewInt(1002))
+	pub1 := key1.PublicKey()
+	pub2 := key2.PublicKey()
+
+	// 2-of-2 multisig
+	witnessScript := Script{}
+	witnessScript.AddOpCode(0x52)
+	witnessScript.AddData(pub1.ToSEC(true))
+	witnessScript.AddData(pub2.ToSEC(true))
+	witnessScript.AddOpCode(0x52)
+	witnessScript.AddOpCode(0xae)
+
+	scriptPubKey := Script{}
+	scriptPubKey.AddOpCode(0x00)
+	scriptPubKey.AddData(utility.Sha256(witnessScript.RawData))
+
+	var prevTxHash [32]byte
+	copy(prevTxHash[:], utility.Hash256([]byte("p2wsh funding")))
+	txIn := NewTxIn(prevTxHash, 1, &Script{}, 0xffffffff)
+	storePreviousOutput(txIn, 50000, hex.EncodeToString(scriptPubKey.RawData))
+
+	tx := NewTx(2, []TxIn{txIn}, []TxOut{NewTxOut(40000, scriptPubKey)}, 0, false)
+
+	z := new(big.Int).SetBytes(tx.SigHashSegWit(0, &witnessScript, 50000, SIGHASH_ALL))
+	sig1 := key1.Sign(z)
+	sig2 := key2.Sign(z)
+	tx.TxIns[0].Witness = [][]byte{{}, append(sig1.ToDER(), SIGHASH_ALL), append(sig2.ToDER(), SIGHASH_ALL), witnessScript.RawData}
+
+	if !tx.Verify() {
+		t.Error("P2WSH transaction should verify")
+	}
+
+	// Signatures in the wrong order.
+	tx.TxIns[0].Witness = [][]byte{{}, append(sig2.ToDER(), SIGHASH_ALL), append(sig1.ToDER(), SIGHASH_ALL), witnessScript.RawData}
+	if tx.VerifyInput(0) {
+		t.Error("P2WSH input shouldn't verify with the signatures swapped")
+	}
+
+	// A witness script that doesn't hash to the program.
+	other := Script{RawData: append([]byte{}, witnessScript.RawData...)}
+	other.RawData[0] = 0x51
+	tx.TxIns[0].Witness = [][]byte{{}, append(sig1.ToDER(), SIGHASH_ALL), other.RawData}
+	if tx.VerifyInput(0) {
+		t.Error("P2WSH input shouldn't verify with a different witness script")
+	}
+}
+
+func TestSigHashTypes(t *testing.T) {
+
+	keys := []ecc.PrivateKey{ecc.NewPrivateKey(big.NewInt(2001)), ecc.NewPrivateKey(big.NewInt(2002))}
+	pub0 := keys[0].PublicKey()
+	pub1 := keys[1].PublicKey()
+	p2pkh := Address{addressType: P2PKH, program: pub0.Hash160(true)}.ScriptPubKey()
+	p2wpkh := Address{addressType: P2WPKH, program: pub1.Hash160(true)}.ScriptPubKey()
+
+	var prevTxHash0, prevTxHash1 [32]byte
+	copy(prevTxHash0[:], utility.Hash256([]byte("sighash types 0")))
+	copy(prevTxHash1[:], utility.Hash256([]byte("sighash types 1")))
+	txIns := []TxIn{NewTxIn(prevTxHash0, 0, &Script{}, 0xffffffff), NewTxIn(prevTxHash1, 1, &Script{}, 0xfffffffe)}
+	storePreviousOutput(txIns[0], 20000, hex.EncodeToString(p2pkh.RawData))
+	storePreviousOutput(txIns[1], 30000, hex.EncodeToString(p2wpkh.RawData))
+	txOuts := []TxOut{NewTxOut(10000, p2pkh), NewTxOut(20000, p2wpkh)}
+
+	hashTypes := []byte{
+		SIGHASH_ALL, SIGHASH_NONE, SIGHASH_SINGLE,
+		SIGHASH_ALL | SIGHASH_ANYONECANPAY, SIGHASH_NONE | SIGHASH_ANYONECANPAY, SIGHASH_SINGLE | SIGHASH_ANYONECANPAY,
+	}
+
+	// Sign each input (one legacy, one SegWit) and check that changing the transaction only
+	// invalidates the signature if the change is to something the hash type signs.
+	for _, hashType := range hashTypes {
+		baseType := hashType & 0x1f
+		anyoneCanPay := hashType&SIGHASH_ANYONECANPAY != 0
+
+		for index := range txIns {
+			tx := NewTx(1, append([]TxIn{}, txIns...), append([]TxOut{}, txOuts...), 0, false)
+			if err := tx.SignInput(index, &keys[index], hashType); err != nil {
+				t.Fatal(err)
+			}
+			if !tx.VerifyInput(index) {
+				t.Errorf("0x%02x, input %v: signature didn't verify", hashType, index)
+				continue
+			}
+
+			for j := range tx.TxOuts {
+				tx.TxOuts[j].Satoshis++
+				expected := baseType == SIGHASH_NONE || (baseType == SIGHASH_SINGLE && j != index)
+				if tx.VerifyInput(index) != expected {
+					t.Errorf("0x%02x, input %v: changing output %v should verify %v", hashType, index, j, expected)
+				}
+				tx.TxOuts[j].Satoshis--
+			}
+
+			tx.TxIns[1-index].Sequence--
+			expected := anyoneCanPay || baseType == SIGHASH_NONE || baseType == SIGHASH_SINGLE
+			if tx.VerifyInput(index) != expected {
+				t.Errorf("0x%02x, input %v: changing the other sequence should verify %v", hashType, index, expected)
+			}
+			tx.TxIns[1-index].Sequence++
+
+			tx.TxIns = append(tx.TxIns, NewTxIn(prevTxHash0, 7, &Script{}, 0))
+			if tx.VerifyInput(index) != anyoneCanPay {
+				t.Errorf("0x%02x, input %v: adding an input should verify %v", hashType, index, anyoneCanPay)
+			}
+		}
+	}
+
+	// SIGHASH_SINGLE without a matching output signs the number one, which doesn't commit to anything.
+	tx := NewTx(1, []TxIn{txIns[1], txIns[0]}, txOuts[:1], 0, false)
+	one := make([]byte, 32)
+	one[0] = 1
+	if hash := tx.SigHash(1, nil, SIGHASH_SINGLE); !bytes.Equal(hash, one) {
+		t.Errorf("Expected the SIGHASH_SINGLE bug hash, got %x", hash)
+	}
+
+	// Signing it is refused, for legacy and SegWit inputs alike.
+	noOutputs := NewTx(1, []TxIn{txIns[0], txIns[1]}, nil, 0, false)
+	for i := range noOutputs.TxIns {
+		if err := noOutputs.SignInput(i, &keys[i], SIGHASH_SINGLE|SIGHASH_ANYONECANPAY); err == nil {
+			t.Errorf("Input %v: expected an error signing SIGHASH_SINGLE without an output", i)
+		}
+	}
+
+	// But such signatures are valid, whatever the outputs.
+	sig := keys[0].Sign(new(big.Int).SetBytes(one))
+	scriptSig := Script{}
+	scriptSig.AddData(append(sig.ToDER(), SIGHASH_SINGLE))
+	scriptSig.AddData(pub0.ToSEC(true))
+	tx.TxIns[1].ScriptSignature = &scriptSig
+	tx.TxOuts[0].Satoshis = 1
+	if !tx.VerifyInput(1) {
+		t.Error("SIGHASH_SINGLE bug signature should verify whatever the outputs")
+	}
+}
+
+// Bitcoin Core's legacy signature hash vectors (sighash.json), with random transactions, scripts and
+// 32 bit hash types. The hashes are in Core's reversed display order.
+func TestSigHashCoreVectors(t *testing.T) {
+
+	data, err := os.ReadFile("testdata/sighash.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors [][]interface{}
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first entry describes the format.
+	for i, vector := range vectors[1:] {
+		rawTx, _ := hex.DecodeString(vector[0].(string))
+		script, _ := hex.DecodeString(vector[1].(string))
+		index := int(vector[2].(float64))
+		hashType := uint32(int32(vector[3].(float64)))
+
+		tx, err := DecodeTx(bytes.NewReader(rawTx), false)
+		if err != nil {
+			t.Errorf("Vector %v: %v", i, err)
+			continue
+		}
+
+		hash := tx.sigHashLegacy(index, &Script{RawData: script}, hashType)
+		if reversed := hex.EncodeToString(utility.ReverseBytes(hash)); reversed != vector[4].(string) {
+			t.Errorf("Vector %v: expected %v, got %v", i, vector[4], reversed)
+		}
+	}
+}
+
+func TestWithoutCodeSeparators(t *testing.T) {
+
+	testCases := []struct {
+		script   string
+		expected string
+	}{
+		{"ab51ab", "51"},
+		{"51", "51"},
+		{"", ""},
+		{"02abab51ab", "02abab51"}, // Pushed data isn't touched
+		{"ab4c", "ab4c"},           // Can't be decoded
+	}
+
+	for _, testCase := range testCases {
+		raw, _ := hex.DecodeString(testCase.script)
+		script := Script{RawData: raw}
+		stripped := script.withoutCodeSeparators()
+		if result := hex.EncodeToString(stripped.RawData); result != testCase.expected {
+			t.Errorf("%v: expected %v, got %v", testCase.script, testCase.expected, result)
+		}
+	}
+}
+
+func TestVerifyHashTypesMultiSig(t *testing.T) {
+
+	// The keys of the BIP143 P2SH-P2WSH example, which sign with a different hash type each.
+	secrets := []string{
+		"730fff80e1413068a05b57d6a58261f07551163369787f349438ea38ca80fac6",
+		"11fa3d25a17cbc22b29c44a484ba552b5a53149d106d3d853e22fdd05a2d8bb3",
+		"77bf4141a87d55bdd7f3cd0bdccf6e9e642935fec45f2f30047be7b799120661",
+		"14af36970f5025ea3e8b5542c0f8ebe7763e674838d08808896b63c3351ffe49",
+		"fe9a95c19eef81dde2b95c1284ef39be497d128e2aa46916fb02d552485e0323",
+		"428a7aee9f0c2af0cd19af3cf1c78149951ea528726989b2e83e4778d2c3f890",
+	}
+	hashTypes := []byte{
+		SIGHASH_ALL, SIGHASH_NONE, SIGHASH_SINGLE,
+		SIGHASH_ALL | SIGHASH_ANYONECANPAY, SIGHASH_NONE | SIGHASH_ANYONECANPAY, SIGHASH_SINGLE | SIGHASH_ANYONECANPAY,
+	}
+
+	b, _ := hex.DecodeString(segwitP2SHP2WSHTx)
+	tx := ParseTx(bytes.NewBuffer(b), false)
+	raw, _ := hex.DecodeString(segwitP2SHP2WSHWitnessScript)
+	witnessScript := Script{RawData: raw}
+
+	redeemScript := Script{}
+	redeemScript.AddOpCode(0x00)
+	redeemScript.AddData(utility.Sha256(witnessScript.RawData))
+	scriptPubKey := Address{addressType: P2SH, program: utility.Hash160(redeemScript.RawData)}.ScriptPubKey()
+	if hex.EncodeToString(scriptPubKey.RawData) != "a9149993a429037b5d912407a71c252019287b8d27a587" {
+		t.Errorf("Unexpected scriptPubKey %x", scriptPubKey.RawData)
+	}
+	storePreviousOutput(tx.TxIns[0], 987654321, hex.EncodeToString(scriptPubKey.RawData))
+
+	scriptSig := Script{}
+	scriptSig.AddData(redeemScript.RawData)
+	tx.TxIns[0].ScriptSignature = &scriptSig
+
+	witness := [][]byte{{}}
+	for i, secret := range secrets {
+		key := ecc.NewPrivateKey(utility.HexStringToBigInt(secret))
+		z := tx.SigHashSegWit(0, &witnessScript, 987654321, hashTypes[i])
+		sig := key.Sign(new(big.Int).SetBytes(z))
+		witness = append(witness, append(sig.ToDER(), hashTypes[i]))
+	}
+	tx.TxIns[0].Witness = append(witness, witnessScript.RawData)
+
+	if !tx.VerifyInput(0) {
+		t.Error("6-of-6 multisig with mixed hash types should verify")
+	}
+
+	// Claiming a different hash type than was signed changes the hash.
+	sig := witness[2]
+	sig[len(sig)-1] = SIGHASH_ALL
+	if tx.VerifyInput(0) {
+		t.Error("Signature shouldn't verify with a different hash type")
+	}
+}
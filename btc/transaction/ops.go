@@ -9,10 +9,26 @@ import (
 	"math/big"
 )
 
+// Computes the hash a signature with the given hash type signs.
+type SigHasher func(hashType byte) *big.Int
+
+// Computes the BIP341 signature hash of a Taproot input for the hash type, annex (nil without one)
+// and leaf hash (nil for the key path). Fails for hash types that aren't valid.
+type TaprootSigHasher func(hashType byte, annex []byte, leafHash []byte) ([]byte, bool)
+
 type ExecutionContext struct {
-	Stack    *collections.Stack
-	AltStack *collections.Stack
-	Hash     *big.Int
+	Stack     *collections.Stack
+	AltStack  *collections.Stack
+	SigHasher SigHasher
+	Tapscript *TapscriptContext // Only set while executing a Tapscript
+}
+
+// The extra state of a Tapscript (BIP342) execution. Signatures are Schnorr signatures of the
+// BIP341 signature hash, and every one that gets checked uses up some of a budget that grows with
+// the size of the witness.
+type TapscriptContext struct {
+	SigHasher    func(hashType byte) ([]byte, bool) // The signature hash for the leaf being executed
+	SigOpsBudget int64
 }
 
 type opFxn func(*ExecutionContext) bool
@@ -111,6 +127,7 @@ func init() {
 	opCodeFxns[0xaf] = opCheckMultiSigVerify
 	opCodeFxns[0xb1] = opCheckLockTimeVerify
 	opCodeFxns[0xb2] = opCheckSequenceVerify
+	opCodeFxns[0xba] = opCheckSigAdd
 
 	// Reserved
 	opCodeFxns[0x50] = opAutoFail
@@ -204,6 +221,7 @@ func init() {
 	opCodeNames[0xaf] = "OP_CHECKMULTISIGVERIFY"
 	opCodeNames[0xb1] = "OP_CHECKLOCKTIMEVERIFY"
 	opCodeNames[0xb2] = "OP_CHECKSEQUENCEVERIFY"
+	opCodeNames[0xba] = "OP_CHECKSIGADD"
 
 	// Reserved
 	opCodeNames[0x50] = "OP_RESERVED"
@@ -268,6 +286,18 @@ func decodeNumber(buffer []byte) int64 {
 	}
 }
 
+// Whether the number is encoded without extra bytes, as encodeNumber does it: the last byte can
+// only be 0x00 or 0x80 (just the sign) if the byte before needs its top bit.
+func isMinimalNumber(buffer []byte) bool {
+	if len(buffer) == 0 {
+		return true
+	}
+	if buffer[len(buffer)-1]&0x7f != 0 {
+		return true
+	}
+	return len(buffer) > 1 && buffer[len(buffer)-2]&0x80 != 0
+}
+
 func twoIntCompareOp(stack *collections.Stack, comparer twoIntOpComparator) bool {
 	if stack.Length() < 2 {
 		return false
@@ -825,12 +855,38 @@ func opCheckSig(context *ExecutionContext) bool {
 	}
 
 	secPubKey, _ := context.Stack.Pop()
-	derSignature, _ := context.Stack.Pop() // Do I have to reverse these?
+	derSignature, _ := context.Stack.Pop()
+
+	if context.Tapscript != nil {
+		valid, ok := checkTapscriptSignature(context.Tapscript, derSignature, secPubKey)
+		if !ok {
+			return false
+		}
+		context.Stack.Push(encodeNumber(utility.IIF(valid, int64(1), int64(0)).(int64)))
+		return true
+	}
+
+	// An empty signature is a valid way to make the check fail.
+	if len(derSignature) == 0 {
+		context.Stack.Push(encodeNumber(0))
+		return true
+	}
+
+	// Malformed signatures fail the script, a key that can't be parsed just doesn't match.
+	sig, err := ecc.ParseDER(derSignature[:len(derSignature)-1])
+	if err != nil {
+		return false
+	}
+	point, err := ecc.ParseSEC(secPubKey)
+	if err != nil {
+		context.Stack.Push(encodeNumber(0))
+		return true
+	}
 
-	point := ecc.NewPointFromSEC(secPubKey)
-	sig := ecc.NewSignatureFromDER(derSignature)
+	// The last byte of the signature is the hash type, which decides what it signs.
+	hash := context.SigHasher(derSignature[len(derSignature)-1])
 
-	if point.Verify(context.Hash, sig) {
+	if point.Verify(hash, sig) {
 		context.Stack.Push(encodeNumber(1))
 	} else {
 		context.Stack.Push(encodeNumber(0))
@@ -850,6 +906,11 @@ func opCheckMultiSig(context *ExecutionContext) bool {
 	// signatures must be placed in the scriptSig using the same order as their corresponding public keys were placed in the scriptPubKey or redeemScript.
 	// If all signatures are valid, 1 is returned, 0 otherwise. Due to a bug, one extra unused value is removed from the stack.
 
+	// Tapscript replaces this with OP_CHECKSIGADD, so signatures can be checked in batches.
+	if context.Tapscript != nil {
+		return false
+	}
+
 	// Get 'n'
 	tmp, ok := context.Stack.Pop()
 	if !ok {
@@ -857,15 +918,15 @@ func opCheckMultiSig(context *ExecutionContext) bool {
 	}
 	n := decodeNumber(tmp)
 
-	// Get n+1 elements off the stack and convert to points.
+	// Get n+1 elements off the stack.
 	if int64(context.Stack.Length()) < n+1 {
 		return false
 	}
 
-	pubKeys := make([]ecc.Point, n)
+	// Keys are only parsed when they're compared, one that can't be parsed doesn't match anything.
+	pubKeys := make([][]byte, n)
 	for i := 0; int64(i) < n; i++ {
-		tmp, _ := context.Stack.Pop()
-		pubKeys[i] = ecc.NewPointFromSEC(tmp)
+		pubKeys[i], _ = context.Stack.Pop()
 	}
 
 	// Get 'm'
@@ -880,10 +941,21 @@ func opCheckMultiSig(context *ExecutionContext) bool {
 		return false
 	}
 
-	sigs := make([]ecc.Signature, m)
+	// Each signature has its own hash type, and so signs its own hash. Empty signatures are left nil,
+	// they don't match any key.
+	sigs := make([]*ecc.Signature, m)
+	hashes := make([]*big.Int, m)
 	for i := 0; int64(i) < m; i++ {
 		tmp, _ := context.Stack.Pop()
-		sigs[i] = ecc.NewSignatureFromDER(tmp[:len(tmp)-1]) // signature is assumed to be using SIGHASH_ALL
+		if len(tmp) == 0 {
+			continue
+		}
+		sig, err := ecc.ParseDER(tmp[:len(tmp)-1])
+		if err != nil {
+			return false
+		}
+		sigs[i] = &sig
+		hashes[i] = context.SigHasher(tmp[len(tmp)-1])
 	}
 
 	// OP_CHECKMULTISIG bug: Pop off one additional, unused element.
@@ -892,29 +964,124 @@ func opCheckMultiSig(context *ExecutionContext) bool {
 		return false
 	}
 
+	// The stack holds the keys and signatures in reverse, but both lists are reversed the same way
+	// so the relative order the matching relies on is preserved.
 	pointCounter := 0
+	matched := 0
 	for i := 0; i < len(sigs); i++ {
-		if pointCounter >= len(pubKeys) {
-			return false
-		}
-
-		for len(pubKeys) > 0 {
+		for pointCounter < len(pubKeys) {
 			pk := pubKeys[pointCounter]
 			pointCounter++
 
-			if pk.Verify(context.Hash, sigs[i]) {
+			if sigs[i] == nil {
+				continue
+			}
+			point, err := ecc.ParseSEC(pk)
+			if err == nil && point.Verify(hashes[i], *sigs[i]) {
+				matched++
 				break
 			}
 		}
 	}
 
-	context.Stack.Push(encodeNumber(1))
+	if matched == len(sigs) {
+		context.Stack.Push(encodeNumber(1))
+	} else {
+		context.Stack.Push(encodeNumber(0))
+	}
 	return true
 }
 func opCheckMultiSigVerify(context *ExecutionContext) bool {
 	// Same as OP_CHECKMULTISIG, but OP_VERIFY is executed afterward.
 	return opCheckMultiSig(context) && opVerify(context)
 }
+func opCheckSigAdd(context *ExecutionContext) bool {
+	// Tapscript only. Pops a public key, a number n and a signature, and pushes n+1 if the signature is
+	// valid or n if it's empty. Any other signature fails the script.
+	if context.Tapscript == nil || context.Stack.Length() < 3 {
+		return false
+	}
+
+	pubKey, _ := context.Stack.Pop()
+	num, _ := context.Stack.Pop()
+	sig, _ := context.Stack.Pop()
+
+	if len(num) > 4 || !isMinimalNumber(num) {
+		return false
+	}
+	n := decodeNumber(num)
+
+	valid, ok := checkTapscriptSignature(context.Tapscript, sig, pubKey)
+	if !ok {
+		return false
+	}
+	if valid {
+		n++
+	}
+
+	context.Stack.Push(encodeNumber(n))
+	return true
+}
+
+// Checks a BIP340 signature in a Tapscript. Returns whether it's valid, and whether the script can
+// carry on: an empty signature is just a failed check, but any other invalid one fails the script.
+func checkTapscriptSignature(tapscript *TapscriptContext, sig []byte, pubKey []byte) (bool, bool) {
+
+	if len(pubKey) == 0 {
+		return false, false
+	}
+	if len(sig) == 0 {
+		return false, true
+	}
+
+	tapscript.SigOpsBudget -= tapscriptSigOpsCost
+	if tapscript.SigOpsBudget < 0 {
+		return false, false
+	}
+
+	// Other key sizes are reserved for future upgrades, for now any signature for them is valid.
+	if len(pubKey) != 32 {
+		return true, true
+	}
+
+	if !verifyTaprootSignature(pubKey, sig, tapscript.SigHasher) {
+		return false, false
+	}
+	return true, true
+}
+
+// Verifies a Taproot signature: a 64 byte BIP340 signature signing SIGHASH_DEFAULT, or one followed
+// by an explicit (non default) hash type.
+func verifyTaprootSignature(pubKey []byte, sig []byte, sigHasher func(hashType byte) ([]byte, bool)) bool {
+
+	hashType := byte(SIGHASH_DEFAULT)
+	switch len(sig) {
+	case 64:
+	case 65:
+		hashType = sig[64]
+		if hashType == SIGHASH_DEFAULT {
+			return false
+		}
+	default:
+		return false
+	}
+
+	key, err := ecc.ParseXOnlyPublicKey(pubKey)
+	if err != nil {
+		return false
+	}
+	signature, err := ecc.ParseSchnorrSignature(sig[:64])
+	if err != nil {
+		return false
+	}
+	hash, ok := sigHasher(hashType)
+	if !ok {
+		return false
+	}
+
+	return key.VerifySchnorr(hash, signature)
+}
+
 func opCheckLockTimeVerify(context *ExecutionContext) bool {
 	// Marks transaction as invalid if the top stack item is greater than the transaction's nLockTime field,
 	// otherwise script evaluation continues as though an OP_NOP was executed. Transaction is also invalid if:
@@ -2,8 +2,10 @@ package transaction
 
 import (
 	"bitcoin-go/collections"
+	"bitcoin-go/ecc"
 	"bitcoin-go/utility"
 	"bytes"
+	"math/big"
 	"testing"
 )
 
@@ -27,3 +29,108 @@ func TestOpHash160(t *testing.T) {
 	}
 
 }
+
+func TestOpCheckSigMalformed(t *testing.T) {
+
+	key := ecc.NewPrivateKey(big.NewInt(12345))
+	hash := new(big.Int).SetBytes(utility.Hash256([]byte("check sig")))
+	sig := key.Sign(hash)
+	goodSig := append(sig.ToDER(), SIGHASH_ALL)
+	pubKey := ecc.G.ScalarMultiply(key.Secret)
+	goodKey := pubKey.ToSEC(true)
+
+	testCases := []struct {
+		name   string
+		sig    []byte
+		pubKey []byte
+		ok     bool
+		result int64
+	}{
+		{"valid", goodSig, goodKey, true, 1},
+		{"empty signature", []byte{}, goodKey, true, 0},
+		{"truncated signature", goodSig[:20], goodKey, false, 0},
+		{"missing sighash byte", goodSig[:len(goodSig)-1], goodKey, false, 0},
+		{"bad public key", goodSig, goodKey[:20], true, 0},
+	}
+
+	for _, testCase := range testCases {
+		s := collections.NewStack()
+		alt := collections.NewStack()
+		ctxt := ExecutionContext{Stack: &s, AltStack: &alt, SigHasher: func(byte) *big.Int { return hash }}
+
+		s.Push(testCase.sig)
+		s.Push(testCase.pubKey)
+
+		if opCheckSig(&ctxt) != testCase.ok {
+			t.Errorf("%v: unexpected result", testCase.name)
+			continue
+		}
+
+		if testCase.ok {
+			result, _ := s.Pop()
+			if decodeNumber(result) != testCase.result {
+				t.Errorf("%v: expected %v", testCase.name, testCase.result)
+			}
+		}
+	}
+}
+
+func TestOpCheckMultiSigNonMatching(t *testing.T) {
+
+	keys := []ecc.PrivateKey{ecc.NewPrivateKey(big.NewInt(111)), ecc.NewPrivateKey(big.NewInt(222))}
+	hash := new(big.Int).SetBytes(utility.Hash256([]byte("check multisig")))
+
+	sigs := make([][]byte, len(keys))
+	pubKeys := make([][]byte, len(keys))
+	for i, key := range keys {
+		sig := key.Sign(hash)
+		sigs[i] = append(sig.ToDER(), SIGHASH_ALL)
+		pubKey := key.PublicKey()
+		pubKeys[i] = pubKey.ToSEC(true)
+	}
+	badKey := []byte{0x02, 0x01, 0x02}
+
+	// m signatures against n keys, each in script order.
+	testCases := []struct {
+		name    string
+		sigs    [][]byte
+		pubKeys [][]byte
+		result  int64
+	}{
+		{"2-of-2", sigs, pubKeys, 1},
+		{"1-of-2 with the second key", [][]byte{sigs[1]}, pubKeys, 1},
+		{"1-of-2 with an empty signature", [][]byte{{}}, pubKeys, 0},
+		{"2-of-2 with one empty signature", [][]byte{sigs[0], {}}, pubKeys, 0},
+		{"1-of-2 with a key that can't be parsed", [][]byte{sigs[1]}, [][]byte{badKey, pubKeys[1]}, 1},
+		{"2-of-2 with a key that can't be parsed", sigs, [][]byte{badKey, pubKeys[1]}, 0},
+		{"0-of-0", [][]byte{}, [][]byte{}, 1},
+	}
+
+	for _, testCase := range testCases {
+		s := collections.NewStack()
+		alt := collections.NewStack()
+		ctxt := ExecutionContext{Stack: &s, AltStack: &alt, SigHasher: func(byte) *big.Int { return hash }}
+
+		s.Push([]byte{})
+		for _, sig := range testCase.sigs {
+			s.Push(sig)
+		}
+		s.Push(encodeNumber(int64(len(testCase.sigs))))
+		for _, pubKey := range testCase.pubKeys {
+			s.Push(pubKey)
+		}
+		s.Push(encodeNumber(int64(len(testCase.pubKeys))))
+
+		if !opCheckMultiSig(&ctxt) {
+			t.Errorf("%v: script failed", testCase.name)
+			continue
+		}
+		result, _ := s.Pop()
+		if decodeNumber(result) != testCase.result {
+			t.Errorf("%v: expected %v", testCase.name, testCase.result)
+		}
+		if s.Length() != 0 {
+			t.Errorf("%v: %v elements left on the stack", testCase.name, s.Length())
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package wallet
+
+import (
+	"bitcoin-go/ecc"
+	"bitcoin-go/utility"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Hierarchical deterministic keys (BIP32). A master key is derived from a seed, and every key can
+// derive up to 2^32 children: normal ones (index < 2^31), which can also be derived from the parent's
+// public key, and hardened ones, which need the parent's private key.
+
+const HardenedKeyStart uint32 = 0x80000000
+
+// Version bytes of the Base58Check serialization.
+var (
+	xprvVersion = []byte{0x04, 0x88, 0xad, 0xe4}
+	xpubVersion = []byte{0x04, 0x88, 0xb2, 0x1e}
+	tprvVersion = []byte{0x04, 0x35, 0x83, 0x94}
+	tpubVersion = []byte{0x04, 0x35, 0x87, 0xcf}
+)
+
+const extendedKeyLength = 78
+
+type ExtendedKey struct {
+	secret            *big.Int // nil for public keys
+	publicKey         ecc.Point
+	chainCode         []byte
+	depth             byte
+	parentFingerprint []byte
+	childNumber       uint32
+	testnet           bool
+}
+
+// Derives the master key from a seed (16 to 64 bytes, usually the output of BIP39).
+func NewMasterKey(seed []byte, testnet bool) (*ExtendedKey, error) {
+
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, fmt.Errorf("seed must be between 16 and 64 bytes, not %v", len(seed))
+	}
+
+	i := hmacSha512([]byte("Bitcoin seed"), seed)
+
+	secret := new(big.Int).SetBytes(i[:32])
+	if secret.Sign() == 0 || secret.Cmp(ecc.N) >= 0 {
+		return nil, errors.New("seed produces an invalid master key")
+	}
+
+	return newPrivateExtendedKey(secret, i[32:], 0, make([]byte, 4), 0, testnet), nil
+}
+
+func newPrivateExtendedKey(secret *big.Int, chainCode []byte, depth byte, parentFingerprint []byte, childNumber uint32, testnet bool) *ExtendedKey {
+	return &ExtendedKey{
+		secret:            secret,
+		publicKey:         ecc.G.ScalarMultiplyConstantTime(ecc.NewScalar(secret)),
+		chainCode:         chainCode,
+		depth:             depth,
+		parentFingerprint: parentFingerprint,
+		childNumber:       childNumber,
+		testnet:           testnet,
+	}
+}
+
+func (k *ExtendedKey) IsPrivate() bool {
+	return k.secret != nil
+}
+
+func (k *ExtendedKey) IsTestnet() bool {
+	return k.testnet
+}
+
+// How many derivations away from the master key this key is.
+func (k *ExtendedKey) Depth() byte {
+	return k.depth
+}
+
+// The index this key was derived with, hardened indices include HardenedKeyStart.
+func (k *ExtendedKey) ChildNumber() uint32 {
+	return k.childNumber
+}
+
+func (k *ExtendedKey) ChainCode() []byte {
+	return k.chainCode
+}
+
+// The first 4 bytes of the HASH160 of the public key, used to identify the key's children.
+func (k *ExtendedKey) Fingerprint() []byte {
+	return k.publicKey.Hash160(true)[:4]
+}
+
+// The fingerprint of the key this one was derived from, all zeros for a master key.
+func (k *ExtendedKey) ParentFingerprint() []byte {
+	return k.parentFingerprint
+}
+
+func (k *ExtendedKey) PublicKey() ecc.Point {
+	return k.publicKey
+}
+
+func (k *ExtendedKey) PrivateKey() (ecc.PrivateKey, error) {
+	if k.secret == nil {
+		return ecc.PrivateKey{}, errors.New("extended key is public")
+	}
+	return ecc.NewPrivateKey(k.secret), nil
+}
+
+// The public version of the key, which can only derive non-hardened children.
+func (k *ExtendedKey) Neuter() *ExtendedKey {
+	return &ExtendedKey{
+		publicKey:         k.publicKey,
+		chainCode:         k.chainCode,
+		depth:             k.depth,
+		parentFingerprint: k.parentFingerprint,
+		childNumber:       k.childNumber,
+		testnet:           k.testnet,
+	}
+}
+
+// Derives the child key with the given index. Indices from HardenedKeyStart up are hardened and
+// need a private key. In the (astronomically unlikely) case that the index produces an invalid key,
+// an error is returned and the caller should move on to the next index.
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+
+	if k.depth == 0xff {
+		return nil, errors.New("maximum derivation depth reached")
+	}
+
+	data := make([]byte, 0, 37)
+	if index >= HardenedKeyStart {
+		if k.secret == nil {
+			return nil, errors.New("hardened children can't be derived from a public key")
+		}
+		data = append(data, 0x00)
+		data = append(data, padTo32(k.secret)...)
+	} else {
+		data = append(data, k.publicKey.ToSEC(true)...)
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	i := hmacSha512(k.chainCode, data)
+
+	tweak := new(big.Int).SetBytes(i[:32])
+	if tweak.Cmp(ecc.N) >= 0 {
+		return nil, fmt.Errorf("child %v is invalid", index)
+	}
+
+	if k.secret != nil {
+		secret := ecc.NewScalar(k.secret).Add(ecc.NewScalar(tweak))
+		if secret.IsZero() {
+			return nil, fmt.Errorf("child %v is invalid", index)
+		}
+		return newPrivateExtendedKey(secret.BigInt(), i[32:], k.depth+1, k.Fingerprint(), index, k.testnet), nil
+	}
+
+	child, err := k.publicKey.TweakAdd(i[:32])
+	if err != nil {
+		return nil, fmt.Errorf("child %v is invalid", index)
+	}
+
+	return &ExtendedKey{
+		publicKey:         child,
+		chainCode:         i[32:],
+		depth:             k.depth + 1,
+		parentFingerprint: k.Fingerprint(),
+		childNumber:       index,
+		testnet:           k.testnet,
+	}, nil
+}
+
+// Derives the key at the given path, e.g. "m/84'/0'/0'/0/5". Paths starting with "m" must be
+// derived from a master key, paths without it are relative to this key.
+func (k *ExtendedKey) Derive(path string) (*ExtendedKey, error) {
+
+	indices, absolute, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if absolute && k.depth != 0 {
+		return nil, errors.New("absolute paths can only be derived from a master key")
+	}
+
+	key := k
+	for _, index := range indices {
+		key, err = key.Child(index)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+// Parses a derivation path like "m/0'/1/2h" into child indices. Hardened indices can be marked
+// with ', h or H.
+func ParseDerivationPath(path string) ([]uint32, error) {
+	indices, _, err := parsePath(path)
+	return indices, err
+}
+
+func parsePath(path string) ([]uint32, bool, error) {
+
+	path = strings.TrimSpace(path)
+	absolute := false
+
+	parts := strings.Split(path, "/")
+	if parts[0] == "m" || parts[0] == "M" {
+		absolute = true
+		parts = parts[1:]
+	}
+
+	// "m" on its own is the master key, "" is the key itself.
+	if !absolute && len(parts) == 1 && parts[0] == "" {
+		parts = nil
+	}
+
+	indices := make([]uint32, len(parts))
+	for i, part := range parts {
+
+		hardened := false
+		if strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h") || strings.HasSuffix(part, "H") {
+			hardened = true
+			part = part[:len(part)-1]
+		}
+
+		if part == "" || strings.ContainsAny(part, "+-") {
+			return nil, false, fmt.Errorf("invalid path element %q", parts[i])
+		}
+
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || uint32(index) >= HardenedKeyStart {
+			return nil, false, fmt.Errorf("invalid path element %q", parts[i])
+		}
+
+		indices[i] = uint32(index)
+		if hardened {
+			indices[i] += HardenedKeyStart
+		}
+	}
+
+	return indices, absolute, nil
+}
+
+// The xprv/xpub (or tprv/tpub) Base58Check serialization.
+func (k *ExtendedKey) String() string {
+
+	buffer := make([]byte, 0, extendedKeyLength)
+
+	switch {
+	case k.secret != nil && !k.testnet:
+		buffer = append(buffer, xprvVersion...)
+	case k.secret != nil && k.testnet:
+		buffer = append(buffer, tprvVersion...)
+	case !k.testnet:
+		buffer = append(buffer, xpubVersion...)
+	default:
+		buffer = append(buffer, tpubVersion...)
+	}
+
+	buffer = append(buffer, k.depth)
+	buffer = append(buffer, k.parentFingerprint...)
+	buffer = binary.BigEndian.AppendUint32(buffer, k.childNumber)
+	buffer = append(buffer, k.chainCode...)
+
+	if k.secret != nil {
+		buffer = append(buffer, 0x00)
+		buffer = append(buffer, padTo32(k.secret)...)
+	} else {
+		buffer = append(buffer, k.publicKey.ToSEC(true)...)
+	}
+
+	return utility.EncodeBase58Checksum(buffer)
+}
+
+// Parses an xprv/xpub/tprv/tpub string.
+func ParseExtendedKey(s string) (*ExtendedKey, error) {
+
+	buffer, err := utility.DecodeBase58Checksum(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(buffer) != extendedKeyLength {
+		return nil, fmt.Errorf("extended key must be %v bytes, not %v", extendedKeyLength, len(buffer))
+	}
+
+	version := buffer[0:4]
+	private := bytes.Equal(version, xprvVersion) || bytes.Equal(version, tprvVersion)
+	public := bytes.Equal(version, xpubVersion) || bytes.Equal(version, tpubVersion)
+	if !private && !public {
+		return nil, fmt.Errorf("unknown extended key version %x", version)
+	}
+
+	key := &ExtendedKey{
+		depth:             buffer[4],
+		parentFingerprint: buffer[5:9],
+		childNumber:       binary.BigEndian.Uint32(buffer[9:13]),
+		chainCode:         buffer[13:45],
+		testnet:           bytes.Equal(version, tprvVersion) || bytes.Equal(version, tpubVersion),
+	}
+
+	if key.depth == 0 {
+		if !bytes.Equal(key.parentFingerprint, make([]byte, 4)) {
+			return nil, errors.New("master key with a non-zero parent fingerprint")
+		}
+		if key.childNumber != 0 {
+			return nil, errors.New("master key with a non-zero child number")
+		}
+	}
+
+	keyData := buffer[45:]
+
+	if private {
+		if keyData[0] != 0x00 {
+			return nil, errors.New("private key data must start with 0x00")
+		}
+
+		secret := new(big.Int).SetBytes(keyData[1:])
+		if secret.Sign() == 0 || secret.Cmp(ecc.N) >= 0 {
+			return nil, errors.New("private key must be in the range [1, N-1]")
+		}
+
+		key.secret = secret
+		key.publicKey = ecc.G.ScalarMultiplyConstantTime(ecc.NewScalar(secret))
+		return key, nil
+	}
+
+	if keyData[0] != 0x02 && keyData[0] != 0x03 {
+		return nil, errors.New("public key must be compressed")
+	}
+
+	key.publicKey, err = ecc.ParseSEC(keyData)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func hmacSha512(key []byte, data []byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func padTo32(i *big.Int) []byte {
+	return i.FillBytes(make([]byte, 32))
+}
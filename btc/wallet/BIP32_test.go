@@ -0,0 +1,227 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+type bip32TestStep struct {
+	path string
+	xpub string
+	xprv string
+}
+
+// From BIP32
+var bip32TestVectors = []struct {
+	seed  string
+	steps []bip32TestStep
+}{
+	{"000102030405060708090a0b0c0d0e0f", []bip32TestStep{
+		{"m", "xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8", "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi"},
+		{"m/0H", "xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw", "xprv9uHRZZhk6KAJC1avXpDAp4MDc3sQKNxDiPvvkX8Br5ngLNv1TxvUxt4cV1rGL5hj6KCesnDYUhd7oWgT11eZG7XnxHrnYeSvkzY7d2bhkJ7"},
+		{"m/0H/1", "xpub6ASuArnXKPbfEwhqN6e3mwBcDTgzisQN1wXN9BJcM47sSikHjJf3UFHKkNAWbWMiGj7Wf5uMash7SyYq527Hqck2AxYysAA7xmALppuCkwQ", "xprv9wTYmMFdV23N2TdNG573QoEsfRrWKQgWeibmLntzniatZvR9BmLnvSxqu53Kw1UmYPxLgboyZQaXwTCg8MSY3H2EU4pWcQDnRnrVA1xe8fs"},
+		{"m/0H/1/2H", "xpub6D4BDPcP2GT577Vvch3R8wDkScZWzQzMMUm3PWbmWvVJrZwQY4VUNgqFJPMM3No2dFDFGTsxxpG5uJh7n7epu4trkrX7x7DogT5Uv6fcLW5", "xprv9z4pot5VBttmtdRTWfWQmoH1taj2axGVzFqSb8C9xaxKymcFzXBDptWmT7FwuEzG3ryjH4ktypQSAewRiNMjANTtpgP4mLTj34bhnZX7UiM"},
+		{"m/0H/1/2H/2", "xpub6FHa3pjLCk84BayeJxFW2SP4XRrFd1JYnxeLeU8EqN3vDfZmbqBqaGJAyiLjTAwm6ZLRQUMv1ZACTj37sR62cfN7fe5JnJ7dh8zL4fiyLHV", "xprvA2JDeKCSNNZky6uBCviVfJSKyQ1mDYahRjijr5idH2WwLsEd4Hsb2Tyh8RfQMuPh7f7RtyzTtdrbdqqsunu5Mm3wDvUAKRHSC34sJ7in334"},
+		{"m/0H/1/2H/2/1000000000", "xpub6H1LXWLaKsWFhvm6RVpEL9P4KfRZSW7abD2ttkWP3SSQvnyA8FSVqNTEcYFgJS2UaFcxupHiYkro49S8yGasTvXEYBVPamhGW6cFJodrTHy", "xprvA41z7zogVVwxVSgdKUHDy1SKmdb533PjDz7J6N6mV6uS3ze1ai8FHa8kmHScGpWmj4WggLyQjgPie1rFSruoUihUZREPSL39UNdE3BBDu76"},
+	}},
+	{"fffcf9f6f3f0edeae7e4e1dedbd8d5d2cfccc9c6c3c0bdbab7b4b1aeaba8a5a29f9c999693908d8a8784817e7b7875726f6c696663605d5a5754514e4b484542", []bip32TestStep{
+		{"m", "xpub661MyMwAqRbcFW31YEwpkMuc5THy2PSt5bDMsktWQcFF8syAmRUapSCGu8ED9W6oDMSgv6Zz8idoc4a6mr8BDzTJY47LJhkJ8UB7WEGuduB", "xprv9s21ZrQH143K31xYSDQpPDxsXRTUcvj2iNHm5NUtrGiGG5e2DtALGdso3pGz6ssrdK4PFmM8NSpSBHNqPqm55Qn3LqFtT2emdEXVYsCzC2U"},
+		{"m/0", "xpub69H7F5d8KSRgmmdJg2KhpAK8SR3DjMwAdkxj3ZuxV27CprR9LgpeyGmXUbC6wb7ERfvrnKZjXoUmmDznezpbZb7ap6r1D3tgFxHmwMkQTPH", "xprv9vHkqa6EV4sPZHYqZznhT2NPtPCjKuDKGY38FBWLvgaDx45zo9WQRUT3dKYnjwih2yJD9mkrocEZXo1ex8G81dwSM1fwqWpWkeS3v86pgKt"},
+		{"m/0/2147483647H", "xpub6ASAVgeehLbnwdqV6UKMHVzgqAG8Gr6riv3Fxxpj8ksbH9ebxaEyBLZ85ySDhKiLDBrQSARLq1uNRts8RuJiHjaDMBU4Zn9h8LZNnBC5y4a", "xprv9wSp6B7kry3Vj9m1zSnLvN3xH8RdsPP1Mh7fAaR7aRLcQMKTR2vidYEeEg2mUCTAwCd6vnxVrcjfy2kRgVsFawNzmjuHc2YmYRmagcEPdU9"},
+		{"m/0/2147483647H/1", "xpub6DF8uhdarytz3FWdA8TvFSvvAh8dP3283MY7p2V4SeE2wyWmG5mg5EwVvmdMVCQcoNJxGoWaU9DCWh89LojfZ537wTfunKau47EL2dhHKon", "xprv9zFnWC6h2cLgpmSA46vutJzBcfJ8yaJGg8cX1e5StJh45BBciYTRXSd25UEPVuesF9yog62tGAQtHjXajPPdbRCHuWS6T8XA2ECKADdw4Ef"},
+		{"m/0/2147483647H/1/2147483646H", "xpub6ERApfZwUNrhLCkDtcHTcxd75RbzS1ed54G1LkBUHQVHQKqhMkhgbmJbZRkrgZw4koxb5JaHWkY4ALHY2grBGRjaDMzQLcgJvLJuZZvRcEL", "xprvA1RpRA33e1JQ7ifknakTFpgNXPmW2YvmhqLQYMmrj4xJXXWYpDPS3xz7iAxn8L39njGVyuoseXzU6rcxFLJ8HFsTjSyQbLYnMpCqE2VbFWc"},
+		{"m/0/2147483647H/1/2147483646H/2", "xpub6FnCn6nSzZAw5Tw7cgR9bi15UV96gLZhjDstkXXxvCLsUXBGXPdSnLFbdpq8p9HmGsApME5hQTZ3emM2rnY5agb9rXpVGyy3bdW6EEgAtqt", "xprvA2nrNbFZABcdryreWet9Ea4LvTJcGsqrMzxHx98MMrotbir7yrKCEXw7nadnHM8Dq38EGfSh6dqA9QWTyefMLEcBYJUuekgW4BYPJcr9E7j"},
+	}},
+	// Retention of leading zeros
+	{"4b381541583be4423346c643850da4b320e46a87ae3d2a4e6da11eba819cd4acba45d239319ac14f863b8d5ab5a0d0c64d2e8a1e7d1457df2e5a3c51c73235be", []bip32TestStep{
+		{"m", "xpub661MyMwAqRbcEZVB4dScxMAdx6d4nFc9nvyvH3v4gJL378CSRZiYmhRoP7mBy6gSPSCYk6SzXPTf3ND1cZAceL7SfJ1Z3GC8vBgp2epUt13", "xprv9s21ZrQH143K25QhxbucbDDuQ4naNntJRi4KUfWT7xo4EKsHt2QJDu7KXp1A3u7Bi1j8ph3EGsZ9Xvz9dGuVrtHHs7pXeTzjuxBrCmmhgC6"},
+		{"m/0H", "xpub68NZiKmJWnxxS6aaHmn81bvJeTESw724CRDs6HbuccFQN9Ku14VQrADWgqbhhTHBaohPX4CjNLf9fq9MYo6oDaPPLPxSb7gwQN3ih19Zm4Y", "xprv9uPDJpEQgRQfDcW7BkF7eTya6RPxXeJCqCJGHuCJ4GiRVLzkTXBAJMu2qaMWPrS7AANYqdq6vcBcBUdJCVVFceUvJFjaPdGZ2y9WACViL4L"},
+	}},
+	// Retention of leading zeros in the private key during hardened derivation
+	{"3ddd5602285899a946114506157c7997e5444528f3003f6134712147db19b678", []bip32TestStep{
+		{"m", "xpub661MyMwAqRbcGczjuMoRm6dXaLDEhW1u34gKenbeYqAix21mdUKJyuyu5F1rzYGVxyL6tmgBUAEPrEz92mBXjByMRiJdba9wpnN37RLLAXa", "xprv9s21ZrQH143K48vGoLGRPxgo2JNkJ3J3fqkirQC2zVdk5Dgd5w14S7fRDyHH4dWNHUgkvsvNDCkvAwcSHNAQwhwgNMgZhLtQC63zxwhQmRv"},
+		{"m/0H", "xpub69AUMk3qDBi3uW1sXgjCmVjJ2G6WQoYSnNHyzkmdCHEhSZ4tBok37xfFEqHd2AddP56Tqp4o56AePAgCjYdvpW2PU2jbUPFKsav5ut6Ch1m", "xprv9vB7xEWwNp9kh1wQRfCCQMnZUEG21LpbR9NPCNN1dwhiZkjjeGRnaALmPXCX7SgjFTiCTT6bXes17boXtjq3xLpcDjzEuGLQBM5ohqkao9G"},
+		{"m/0H/1H", "xpub6BJA1jSqiukeaesWfxe6sNK9CCGaujFFSJLomWHprUL9DePQ4JDkM5d88n49sMGJxrhpjazuXYWdMf17C9T5XnxkopaeS7jGk1GyyVziaMt", "xprv9xJocDuwtYCMNAo3Zw76WENQeAS6WGXQ55RCy7tDJ8oALr4FWkuVoHJeHVAcAqiZLE7Je3vZJHxspZdFHfnBEjHqU5hG1Jaj32dVoS6XLT1"},
+	}},
+}
+
+func TestBIP32Vectors(t *testing.T) {
+
+	for _, vector := range bip32TestVectors {
+		seed, _ := hex.DecodeString(vector.seed)
+		master, err := NewMasterKey(seed, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, step := range vector.steps {
+			key, err := master.Derive(step.path)
+			if err != nil {
+				t.Fatalf("%v: %v", step.path, err)
+			}
+
+			if xprv := key.String(); xprv != step.xprv {
+				t.Errorf("%v: expected %v, got %v", step.path, step.xprv, xprv)
+			}
+			if xpub := key.Neuter().String(); xpub != step.xpub {
+				t.Errorf("%v: expected %v, got %v", step.path, step.xpub, xpub)
+			}
+
+			// Both serializations parse back to the same key.
+			for _, s := range []string{step.xprv, step.xpub} {
+				parsed, err := ParseExtendedKey(s)
+				if err != nil {
+					t.Errorf("%v: failed to parse %v: %v", step.path, s, err)
+					continue
+				}
+				if parsed.String() != s {
+					t.Errorf("%v: round trip of %v gave %v", step.path, s, parsed.String())
+				}
+			}
+		}
+	}
+}
+
+// Non-hardened children can be derived from the public key alone.
+func TestBIP32PublicDerivation(t *testing.T) {
+
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, _ := NewMasterKey(seed, false)
+
+	account, err := master.Derive("m/0H/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	private, err := account.Derive("2/1000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	public, err := account.Neuter().Derive("2/1000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if public.String() != private.Neuter().String() {
+		t.Errorf("Public derivation gave %v, expected %v", public.String(), private.Neuter().String())
+	}
+
+	if _, err := account.Neuter().Child(HardenedKeyStart); err == nil {
+		t.Error("Expected an error deriving a hardened child from a public key")
+	}
+	if _, err := public.PrivateKey(); err == nil {
+		t.Error("Expected an error getting the private key of a public key")
+	}
+
+	// Absolute paths need a master key.
+	if _, err := account.Derive("m/0"); err == nil {
+		t.Error("Expected an error deriving an absolute path from a child key")
+	}
+}
+
+func TestBIP32ParentTracking(t *testing.T) {
+
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, _ := NewMasterKey(seed, true)
+
+	if fingerprint := hex.EncodeToString(master.Fingerprint()); fingerprint != "3442193e" {
+		t.Errorf("Expected fingerprint 3442193e, got %v", fingerprint)
+	}
+
+	child, _ := master.Derive("m/0H/1")
+	if child.Depth() != 2 || child.ChildNumber() != 1 {
+		t.Errorf("Unexpected depth %v or child number %v", child.Depth(), child.ChildNumber())
+	}
+
+	parent, _ := master.Child(HardenedKeyStart)
+	if hex.EncodeToString(child.ParentFingerprint()) != hex.EncodeToString(parent.Fingerprint()) {
+		t.Error("Parent fingerprint doesn't match")
+	}
+
+	if s := child.String(); s[:4] != "tprv" {
+		t.Errorf("Expected a tprv, got %v", s)
+	}
+	if s := child.Neuter().String(); s[:4] != "tpub" {
+		t.Errorf("Expected a tpub, got %v", s)
+	}
+
+	parsed, err := ParseExtendedKey(child.String())
+	if err != nil || !parsed.IsTestnet() || !parsed.IsPrivate() {
+		t.Errorf("Testnet key didn't round trip: %v", err)
+	}
+
+	// The private key matches the public key.
+	key, _ := child.PrivateKey()
+	public := child.PublicKey()
+	if address := key.PublicKey(); !address.Equals(&public) {
+		t.Error("Private and public keys don't match")
+	}
+}
+
+func TestParseDerivationPath(t *testing.T) {
+
+	testCases := []struct {
+		path     string
+		expected []uint32
+	}{
+		{"m", []uint32{}},
+		{"m/84'/0'/0'/0/5", []uint32{HardenedKeyStart + 84, HardenedKeyStart, HardenedKeyStart, 0, 5}},
+		{"m/0h/1H/2", []uint32{HardenedKeyStart, HardenedKeyStart + 1, 2}},
+		{"0/1", []uint32{0, 1}},
+		{"m/2147483647'", []uint32{0xffffffff}},
+	}
+
+	for _, testCase := range testCases {
+		indices, err := ParseDerivationPath(testCase.path)
+		if err != nil {
+			t.Errorf("%v: %v", testCase.path, err)
+			continue
+		}
+
+		if len(indices) != len(testCase.expected) {
+			t.Errorf("%v: expected %v, got %v", testCase.path, testCase.expected, indices)
+			continue
+		}
+		for i := range indices {
+			if indices[i] != testCase.expected[i] {
+				t.Errorf("%v: expected %v, got %v", testCase.path, testCase.expected, indices)
+			}
+		}
+	}
+
+	for _, invalid := range []string{"m/", "m//1", "m/a", "m/-1", "m/+1", "m/2147483648", "m/1''", "x/1"} {
+		if _, err := ParseDerivationPath(invalid); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+// Test vector 5 from BIP32
+func TestParseExtendedKeyInvalid(t *testing.T) {
+
+	invalid := []string{
+		"xpub661MyMwAqRbcEYS8w7XLSVeEsBXy79zSzH1J8vCdxAZningWLdN3zgtU6LBpB85b3D2yc8sfvZU521AAwdZafEz7mnzBBsz4wKY5fTtTQBm", // pubkey version / prvkey mismatch
+		"xprv9s21ZrQH143K24Mfq5zL5MhWK9hUhhGbd45hLXo2Pq2oqzMMo63oStZzFGTQQD3dC4H2D5GBj7vWvSQaaBv5cxi9gafk7NF3pnBju6dwKvH", // prvkey version / pubkey mismatch
+		"xpub661MyMwAqRbcEYS8w7XLSVeEsBXy79zSzH1J8vCdxAZningWLdN3zgtU6Txnt3siSujt9RCVYsx4qHZGc62TG4McvMGcAUjeuwZdduYEvFn", // invalid pubkey prefix 04
+		"xprv9s21ZrQH143K24Mfq5zL5MhWK9hUhhGbd45hLXo2Pq2oqzMMo63oStZzFGpWnsj83BHtEy5Zt8CcDr1UiRXuWCmTQLxEK9vbz5gPstX92JQ", // invalid prvkey prefix 04
+		"xpub661MyMwAqRbcEYS8w7XLSVeEsBXy79zSzH1J8vCdxAZningWLdN3zgtU6N8ZMMXctdiCjxTNq964yKkwrkBJJwpzZS4HS2fxvyYUA4q2Xe4", // invalid pubkey prefix 01
+		"xprv9s21ZrQH143K24Mfq5zL5MhWK9hUhhGbd45hLXo2Pq2oqzMMo63oStZzFAzHGBP2UuGCqWLTAPLcMtD9y5gkZ6Eq3Rjuahrv17fEQ3Qen6J", // invalid prvkey prefix 01
+		"xprv9s2SPatNQ9Vc6GTbVMFPFo7jsaZySyzk7L8n2uqKXJen3KUmvQNTuLh3fhZMBoG3G4ZW1N2kZuHEPY53qmbZzCHshoQnNf4GvELZfqTUrcv", // zero depth with non-zero parent fingerprint
+		"xpub661no6RGEX3uJkY4bNnPcw4URcQTrSibUZ4NqJEw5eBkv7ovTwgiT91XX27VbEXGENhYRCf7hyEbWrR3FewATdCEebj6znwMfQkhRYHRLpJ", // zero depth with non-zero parent fingerprint
+		"xprv9s21ZrQH4r4TsiLvyLXqM9P7k1K3EYhA1kkD6xuquB5i39AU8KF42acDyL3qsDbU9NmZn6MsGSUYZEsuoePmjzsB3eFKSUEh3Gu1N3cqVUN", // zero depth with non-zero index
+		"xpub661MyMwAuDcm6CRQ5N4qiHKrJ39Xe1R1NyfouMKTTWcguwVcfrZJaNvhpebzGerh7gucBvzEQWRugZDuDXjNDRmXzSZe4c7mnTK97pTvGS8", // zero depth with non-zero index
+		"DMwo58pR1QLEFihHiXPVykYB6fJmsTeHvyTp7hRThAtCX8CvYzgPcn8XnmdfHGMQzT7ayAmfo4z3gY5KfbrZWZ6St24UVf2Qgo6oujFktLHdHY4", // unknown extended key version
+		"DMwo58pR1QLEFihHiXPVykYB6fJmsTeHvyTp7hRThAtCX8CvYzgPcn8XnmdfHPmHJiEDXkTiJTVV9rHEBUem2mwVbbNfvT2MTcAqj3nesx8uBf9", // unknown extended key version
+		"xprv9s21ZrQH143K24Mfq5zL5MhWK9hUhhGbd45hLXo2Pq2oqzMMo63oStZzF93Y5wvzdUayhgkkFoicQZcP3y52uPPxFnfoLZB21Teqt1VvEHx", // private key 0 not in 1..n-1
+		"xprv9s21ZrQH143K24Mfq5zL5MhWK9hUhhGbd45hLXo2Pq2oqzMMo63oStZzFAzHGBP2UuGCqWLTAPLcMtD5SDKr24z3aiUvKr9bJpdrcLg1y3G", // private key n not in 1..n-1
+		"xpub661MyMwAqRbcEYS8w7XLSVeEsBXy79zSzH1J8vCdxAZningWLdN3zgtU6Q5JXayek4PRsn35jii4veMimro1xefsM58PgBMrvdYre8QyULY", // invalid pubkey 020000000000000000000000000000000000000000000000000000000000000007
+		"xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHL", // invalid checksum
+	}
+
+	for _, s := range invalid {
+		if _, err := ParseExtendedKey(s); err == nil {
+			t.Errorf("Expected an error for %v", s)
+		}
+	}
+}
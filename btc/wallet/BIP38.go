@@ -0,0 +1,381 @@
+package wallet
+
+import (
+	"bitcoin-go/ecc"
+	"bitcoin-go/utility"
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Passphrase protected private keys (BIP38). The encrypted key is a 58 character string starting
+// with "6P". The key is AES-256 encrypted with an scrypt hash of the passphrase, salted with a hash
+// of the key's (mainnet P2PKH) address, which also lets the passphrase be checked on decryption.
+//
+// With EC multiplication, the owner of the passphrase hands out an "intermediate code" (starting
+// with "passphrase"), from which someone else can create encrypted keys without learning the
+// passphrase or the private keys, e.g. a service printing paper wallets. Along with each key they
+// get a confirmation code ("cfrm38...") the owner can use to check the key's address.
+//
+// Passphrases are NFC normalized before hashing, as BIP38 requires, so the same text typed with
+// precomposed or combining characters decrypts the same key.
+
+const (
+	bip38Length             = 39
+	bip38IntermediateLength = 49
+	bip38ConfirmationLength = 51
+
+	bip38MaxLot      uint32 = 1<<20 - 1
+	bip38MaxSequence uint32 = 1<<12 - 1
+)
+
+var (
+	bip38NonECPrefix       = []byte{0x01, 0x42}
+	bip38ECPrefix          = []byte{0x01, 0x43}
+	bip38IntermediateMagic = []byte{0x2c, 0xe9, 0xb3, 0xe1, 0xff, 0x39, 0xe2} // Followed by 0x51 with a lot and sequence number, or 0x53 without
+	bip38ConfirmationMagic = []byte{0x64, 0x3b, 0xf6, 0xa8, 0x9a}
+)
+
+// Flag byte bits.
+const (
+	bip38FlagNonEC       = 0xc0
+	bip38FlagCompressed  = 0x20
+	bip38FlagLotSequence = 0x04
+)
+
+// Encrypts the private key with the passphrase. The compressed flag picks which of the key's two
+// addresses the encrypted key is tied to.
+func BIP38Encrypt(key ecc.PrivateKey, compressed bool, passphrase string) (string, error) {
+
+	if key.Secret.Sign() <= 0 || key.Secret.Cmp(ecc.N) >= 0 {
+		return "", errors.New("private key must be in the range [1, N-1]")
+	}
+
+	publicKey := key.PublicKey()
+	addressHash := bip38AddressHash(&publicKey, compressed)
+
+	derived, err := scrypt.Key([]byte(norm.NFC.String(passphrase)), addressHash, 16384, 8, 8, 64)
+	if err != nil {
+		return "", err
+	}
+
+	flag := byte(bip38FlagNonEC)
+	if compressed {
+		flag |= bip38FlagCompressed
+	}
+
+	secret := padTo32(key.Secret)
+	buffer := make([]byte, 0, bip38Length)
+	buffer = append(buffer, bip38NonECPrefix...)
+	buffer = append(buffer, flag)
+	buffer = append(buffer, addressHash...)
+	buffer = append(buffer, aesEncrypt(xorBytes(secret[:16], derived[:16]), derived[32:])...)
+	buffer = append(buffer, aesEncrypt(xorBytes(secret[16:], derived[16:32]), derived[32:])...)
+
+	return utility.EncodeBase58Checksum(buffer), nil
+}
+
+// Decrypts a BIP38 key (with or without EC multiplication), returning it along with whether its
+// address uses the compressed public key. A wrong passphrase is reported as an error.
+func BIP38Decrypt(encrypted string, passphrase string) (ecc.PrivateKey, bool, error) {
+
+	buffer, err := utility.DecodeBase58Checksum(encrypted)
+	if err != nil {
+		return ecc.PrivateKey{}, false, err
+	}
+	if len(buffer) != bip38Length {
+		return ecc.PrivateKey{}, false, fmt.Errorf("encrypted key must be %v bytes, not %v", bip38Length, len(buffer))
+	}
+
+	prefix, flag, addressHash := buffer[0:2], buffer[2], buffer[3:7]
+	compressed := flag&bip38FlagCompressed != 0
+
+	var secret *big.Int
+
+	switch {
+	case bytes.Equal(prefix, bip38NonECPrefix):
+		if flag&^bip38FlagCompressed != bip38FlagNonEC {
+			return ecc.PrivateKey{}, false, fmt.Errorf("invalid flag byte 0x%02x", flag)
+		}
+
+		derived, err := scrypt.Key([]byte(norm.NFC.String(passphrase)), addressHash, 16384, 8, 8, 64)
+		if err != nil {
+			return ecc.PrivateKey{}, false, err
+		}
+
+		decrypted := append(xorBytes(aesDecrypt(buffer[7:23], derived[32:]), derived[:16]), xorBytes(aesDecrypt(buffer[23:39], derived[32:]), derived[16:32])...)
+		secret = new(big.Int).SetBytes(decrypted)
+
+	case bytes.Equal(prefix, bip38ECPrefix):
+		if flag&^(bip38FlagCompressed|bip38FlagLotSequence) != 0 {
+			return ecc.PrivateKey{}, false, fmt.Errorf("invalid flag byte 0x%02x", flag)
+		}
+
+		ownerEntropy := buffer[7:15]
+		passFactor, err := bip38PassFactor(passphrase, ownerEntropy, flag&bip38FlagLotSequence != 0)
+		if err != nil {
+			return ecc.PrivateKey{}, false, err
+		}
+		passPoint := ecc.G.ScalarMultiply(passFactor)
+
+		derived, err := scrypt.Key(passPoint.ToSEC(true), append(append([]byte{}, addressHash...), ownerEntropy...), 1024, 1, 1, 64)
+		if err != nil {
+			return ecc.PrivateKey{}, false, err
+		}
+
+		// The second part holds the end of the first part and the end of seedb.
+		part2 := xorBytes(aesDecrypt(buffer[23:39], derived[32:]), derived[16:32])
+		part1 := append(append([]byte{}, buffer[15:23]...), part2[:8]...)
+		seedB := append(xorBytes(aesDecrypt(part1, derived[32:]), derived[:16]), part2[8:]...)
+
+		factorB := new(big.Int).SetBytes(utility.Hash256(seedB))
+		secret = ecc.NewScalar(passFactor).Mul(ecc.NewScalar(factorB)).BigInt()
+
+	default:
+		return ecc.PrivateKey{}, false, fmt.Errorf("unknown BIP38 prefix %x", prefix)
+	}
+
+	if secret.Sign() == 0 || secret.Cmp(ecc.N) >= 0 {
+		return ecc.PrivateKey{}, false, errors.New("wrong passphrase")
+	}
+
+	key := ecc.NewPrivateKey(secret)
+	publicKey := key.PublicKey()
+	if !bytes.Equal(bip38AddressHash(&publicKey, compressed), addressHash) {
+		return ecc.PrivateKey{}, false, errors.New("wrong passphrase")
+	}
+
+	return key, compressed, nil
+}
+
+// Creates an intermediate code for the passphrase, with a random salt.
+func NewBIP38IntermediateCode(passphrase string) (string, error) {
+	return newBIP38IntermediateCode(passphrase, utility.RandomData(8), false)
+}
+
+// Creates an intermediate code that includes a lot (up to 1048575) and sequence number (up to
+// 4095), which end up in every key and confirmation code made from it.
+func NewBIP38IntermediateCodeWithLot(passphrase string, lot uint32, sequence uint32) (string, error) {
+
+	if lot > bip38MaxLot {
+		return "", fmt.Errorf("lot must be at most %v", bip38MaxLot)
+	}
+	if sequence > bip38MaxSequence {
+		return "", fmt.Errorf("sequence must be at most %v", bip38MaxSequence)
+	}
+
+	ownerEntropy := binary.BigEndian.AppendUint32(utility.RandomData(4), lot<<12|sequence)
+	return newBIP38IntermediateCode(passphrase, ownerEntropy, true)
+}
+
+func newBIP38IntermediateCode(passphrase string, ownerEntropy []byte, lotSequence bool) (string, error) {
+
+	passFactor, err := bip38PassFactor(passphrase, ownerEntropy, lotSequence)
+	if err != nil {
+		return "", err
+	}
+	passPoint := ecc.G.ScalarMultiply(passFactor)
+
+	buffer := make([]byte, 0, bip38IntermediateLength)
+	buffer = append(buffer, bip38IntermediateMagic...)
+	if lotSequence {
+		buffer = append(buffer, 0x51)
+	} else {
+		buffer = append(buffer, 0x53)
+	}
+	buffer = append(buffer, ownerEntropy...)
+	buffer = append(buffer, passPoint.ToSEC(true)...)
+
+	return utility.EncodeBase58Checksum(buffer), nil
+}
+
+// The result of encrypting a new key from an intermediate code.
+type BIP38GeneratedKey struct {
+	EncryptedKey     string
+	ConfirmationCode string
+	Address          string
+}
+
+// Creates a new encrypted key from an intermediate code, without knowing the passphrase.
+func BIP38EncryptFromIntermediate(intermediate string, compressed bool) (*BIP38GeneratedKey, error) {
+	return bip38EncryptFromIntermediate(intermediate, compressed, utility.RandomData(24))
+}
+
+func bip38EncryptFromIntermediate(intermediate string, compressed bool, seedB []byte) (*BIP38GeneratedKey, error) {
+
+	buffer, err := utility.DecodeBase58Checksum(intermediate)
+	if err != nil {
+		return nil, err
+	}
+	if len(buffer) != bip38IntermediateLength || !bytes.Equal(buffer[:7], bip38IntermediateMagic) || (buffer[7] != 0x51 && buffer[7] != 0x53) {
+		return nil, errors.New("invalid intermediate code")
+	}
+
+	ownerEntropy := buffer[8:16]
+	passPoint, err := ecc.ParseSEC(buffer[16:])
+	if err != nil {
+		return nil, err
+	}
+
+	flag := byte(0)
+	if compressed {
+		flag |= bip38FlagCompressed
+	}
+	if buffer[7] == 0x51 {
+		flag |= bip38FlagLotSequence
+	}
+
+	factorB := new(big.Int).SetBytes(utility.Hash256(seedB))
+	if factorB.Sign() == 0 || factorB.Cmp(ecc.N) >= 0 {
+		return nil, errors.New("invalid seed")
+	}
+
+	publicKey := passPoint.ScalarMultiply(factorB)
+	addressHash := bip38AddressHash(&publicKey, compressed)
+
+	derived, err := scrypt.Key(passPoint.ToSEC(true), append(append([]byte{}, addressHash...), ownerEntropy...), 1024, 1, 1, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	part1 := aesEncrypt(xorBytes(seedB[:16], derived[:16]), derived[32:])
+	part2 := aesEncrypt(xorBytes(append(append([]byte{}, part1[8:]...), seedB[16:]...), derived[16:32]), derived[32:])
+
+	encrypted := make([]byte, 0, bip38Length)
+	encrypted = append(encrypted, bip38ECPrefix...)
+	encrypted = append(encrypted, flag)
+	encrypted = append(encrypted, addressHash...)
+	encrypted = append(encrypted, ownerEntropy...)
+	encrypted = append(encrypted, part1[:8]...)
+	encrypted = append(encrypted, part2...)
+
+	// The confirmation code holds factorb * G, encrypted the same way, so the passphrase owner can
+	// check which address the key belongs to.
+	pointB := ecc.G.ScalarMultiply(factorB)
+	pointBBytes := pointB.ToSEC(true)
+
+	confirmation := make([]byte, 0, bip38ConfirmationLength)
+	confirmation = append(confirmation, bip38ConfirmationMagic...)
+	confirmation = append(confirmation, flag)
+	confirmation = append(confirmation, addressHash...)
+	confirmation = append(confirmation, ownerEntropy...)
+	confirmation = append(confirmation, pointBBytes[0]^(derived[63]&1))
+	confirmation = append(confirmation, aesEncrypt(xorBytes(pointBBytes[1:17], derived[:16]), derived[32:])...)
+	confirmation = append(confirmation, aesEncrypt(xorBytes(pointBBytes[17:], derived[16:32]), derived[32:])...)
+
+	return &BIP38GeneratedKey{
+		EncryptedKey:     utility.EncodeBase58Checksum(encrypted),
+		ConfirmationCode: utility.EncodeBase58Checksum(confirmation),
+		Address:          publicKey.Address(compressed, false),
+	}, nil
+}
+
+// Checks a confirmation code with the passphrase, returning the address of the key it was made
+// for, and its lot and sequence numbers if the intermediate code had them.
+func VerifyBIP38Confirmation(confirmation string, passphrase string) (address string, lot uint32, sequence uint32, err error) {
+
+	buffer, err := utility.DecodeBase58Checksum(confirmation)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if len(buffer) != bip38ConfirmationLength || !bytes.Equal(buffer[:5], bip38ConfirmationMagic) {
+		return "", 0, 0, errors.New("invalid confirmation code")
+	}
+
+	flag, addressHash, ownerEntropy := buffer[5], buffer[6:10], buffer[10:18]
+	if flag&^(bip38FlagCompressed|bip38FlagLotSequence) != 0 {
+		return "", 0, 0, fmt.Errorf("invalid flag byte 0x%02x", flag)
+	}
+	compressed := flag&bip38FlagCompressed != 0
+	lotSequence := flag&bip38FlagLotSequence != 0
+
+	passFactor, err := bip38PassFactor(passphrase, ownerEntropy, lotSequence)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	passPoint := ecc.G.ScalarMultiply(passFactor)
+
+	derived, err := scrypt.Key(passPoint.ToSEC(true), append(append([]byte{}, addressHash...), ownerEntropy...), 1024, 1, 1, 64)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	pointBBytes := []byte{buffer[18] ^ (derived[63] & 1)}
+	pointBBytes = append(pointBBytes, xorBytes(aesDecrypt(buffer[19:35], derived[32:]), derived[:16])...)
+	pointBBytes = append(pointBBytes, xorBytes(aesDecrypt(buffer[35:51], derived[32:]), derived[16:32])...)
+
+	pointB, err := ecc.ParseSEC(pointBBytes)
+	if err != nil {
+		return "", 0, 0, errors.New("wrong passphrase")
+	}
+
+	publicKey := pointB.ScalarMultiply(passFactor)
+	if !bytes.Equal(bip38AddressHash(&publicKey, compressed), addressHash) {
+		return "", 0, 0, errors.New("wrong passphrase")
+	}
+
+	if lotSequence {
+		lotSequenceNumber := binary.BigEndian.Uint32(ownerEntropy[4:])
+		lot, sequence = lotSequenceNumber>>12, lotSequenceNumber&bip38MaxSequence
+	}
+
+	return publicKey.Address(compressed, false), lot, sequence, nil
+}
+
+// The passfactor is the private key belonging to the intermediate code's passpoint. With a lot and
+// sequence number only the first 4 bytes of the owner entropy are the salt.
+func bip38PassFactor(passphrase string, ownerEntropy []byte, lotSequence bool) (*big.Int, error) {
+
+	salt := ownerEntropy
+	if lotSequence {
+		salt = ownerEntropy[:4]
+	}
+
+	preFactor, err := scrypt.Key([]byte(norm.NFC.String(passphrase)), salt, 16384, 8, 8, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	passFactor := preFactor
+	if lotSequence {
+		passFactor = utility.Hash256(append(preFactor, ownerEntropy...))
+	}
+
+	factor := new(big.Int).SetBytes(passFactor)
+	if factor.Sign() == 0 || factor.Cmp(ecc.N) >= 0 {
+		return nil, errors.New("passphrase produces an invalid passfactor")
+	}
+	return factor, nil
+}
+
+// The first 4 bytes of the double SHA256 of the key's address string.
+func bip38AddressHash(publicKey *ecc.Point, compressed bool) []byte {
+	return utility.Hash256([]byte(publicKey.Address(compressed, false)))[:4]
+}
+
+// AES-256 of a single block, which is all BIP38 needs (effectively ECB mode).
+func aesEncrypt(block []byte, key []byte) []byte {
+	cipher, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	result := make([]byte, aes.BlockSize)
+	cipher.Encrypt(result, block)
+	return result
+}
+
+func aesDecrypt(block []byte, key []byte) []byte {
+	cipher, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	result := make([]byte, aes.BlockSize)
+	cipher.Decrypt(result, block)
+	return result
+}
@@ -0,0 +1,205 @@
+package wallet
+
+import (
+	"bitcoin-go/ecc"
+	"bitcoin-go/utility"
+	"math/big"
+	"testing"
+)
+
+// Test vectors from BIP38. The third passphrase is given as in the BIP, before NFC normalization
+// turns "\u03D2\u0301" into "\u03D3".
+var bip38NonECTestVectors = []struct {
+	passphrase string
+	encrypted  string
+	wif        string
+}{
+	{"TestingOneTwoThree", "6PRVWUbkzzsbcVac2qwfssoUJAN1Xhrg6bNk8J7Nzm5H7kxEbn2Nh2ZoGg", "5KN7MzqK5wt2TP1fQCYyHBtDrXdJuXbUzm4A9rKAteGu3Qi5CVR"},
+	{"Satoshi", "6PRNFFkZc2NZ6dJqFfhRoFNMR9Lnyj7dYGrzdgXXVMXcxoKTePPX1dWByq", "5HtasZ6ofTHP6HCwTqTkLDuLQisYPah7aUnSKfC7h4hMUVw2gi5"},
+	{"\u03D2\u0301\u0000\U00010400\U0001F4A9", "6PRW5o9FLp4gJDDVqJQKJFTpMvdsSGJxMYHtHaQBF3ooa8mwD69bapcDQn", "5Jajm8eQ22H3pGWLEVCXyvND8dQZhiQhoLJNKjYXk9roUFTMSZ4"},
+	{"TestingOneTwoThree", "6PYNKZ1EAgYgmQfmNVamxyXVWHzK5s6DGhwP4J5o44cvXdoY7sRzhtpUeo", "L44B5gGEpqEDRS9vVPz7QT35jcBG2r3CZwSwQ4fCewXAhAhqGVpP"},
+	{"Satoshi", "6PYLtMnXvfG3oJde97zRyLYFZCYizPU5T3LwgdYJz1fRhh16bU7u6PPmY7", "KwYgW8gcxj1JWJXhPSu4Fqwzfhp5Yfi42mdYmMa4XqK7NJxXUSK7"},
+}
+
+var bip38ECTestVectors = []struct {
+	passphrase   string
+	intermediate string
+	encrypted    string
+	confirmation string
+	address      string
+	wif          string
+	lot          uint32
+	sequence     uint32
+}{
+	{"TestingOneTwoThree", "passphrasepxFy57B9v8HtUsszJYKReoNDV6VHjUSGt8EVJmux9n1J3Ltf1gRxyDGXqnf9qm", "6PfQu77ygVyJLZjfvMLyhLMQbYnu5uguoJJ4kMCLqWwPEdfpwANVS76gTX", "", "1PE6TQi6HTVNz5DLwB1LcpMBALubfuN2z2", "5K4caxezwjGCGfnoPTZ8tMcJBLB7Jvyjv4xxeacadhq8nLisLR2", 0, 0},
+	{"Satoshi", "passphraseoRDGAXTWzbp72eVbtUDdn1rwpgPUGjNZEc6CGBo8i5EC1FPW8wcnLdq4ThKzAS", "6PfLGnQs6VZnrNpmVKfjotbnQuaJK4KZoPFrAjx1JMJUa1Ft8gnf5WxfKd", "", "1CqzrtZC6mXSAhoxtFwVjz8LtwLJjDYU3V", "5KJ51SgxWaAYR13zd9ReMhJpwrcX47xTJh2D3fGPG9CM8vkv5sH", 0, 0},
+	{"MOLON LABE", "passphraseaB8feaLQDENqCgr4gKZpmf4VoaT6qdjJNJiv7fsKvjqavcJxvuR1hy25aTu5sX", "6PgNBNNzDkKdhkT6uJntUXwwzQV8Rr2tZcbkDcuC9DZRsS6AtHts4Ypo1j", "cfrm38V8aXBn7JWA1ESmFMUn6erxeBGZGAxJPY4e36S9QWkzZKtaVqLNMgnifETYw7BPwWC9aPD", "1Jscj8ALrYu2y9TD8NrpvDBugPedmbj4Yh", "5JLdxTtcTHcfYcmJsNVy1v2PMDx432JPoYcBTVVRHpPaxUrdtf8", 263183, 1},
+	{"ΜΟΛΩΝ ΛΑΒΕ", "passphrased3z9rQJHSyBkNBwTRPkUGNVEVrUAcfAXDyRU1V28ie6hNFbqDwbFBvsTK7yWVK", "6PgGWtx25kUg8QWvwuJAgorN6k9FbE25rv5dMRwu5SKMnfpfVe5mar2ngH", "cfrm38V8G4qq2ywYEFfWLD5Cc6msj9UwsG2Mj4Z6QdGJAFQpdatZLavkgRd1i4iBMdRngDqDs51", "1Lurmih3KruL4xDB5FmHof38yawNtP9oGf", "5KMKKuUmAkiNbA3DazMQiLfDq47qs8MAEThm4yL8R2PhV1ov33D", 806938, 1},
+}
+
+func TestBIP38NonEC(t *testing.T) {
+
+	for _, vector := range bip38NonECTestVectors {
+		key, compressed, _, err := ecc.ParseWIF(vector.wif)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		encrypted, err := BIP38Encrypt(key, compressed, vector.passphrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if encrypted != vector.encrypted {
+			t.Errorf("Expected %v, got %v", vector.encrypted, encrypted)
+		}
+
+		decrypted, decryptedCompressed, err := BIP38Decrypt(vector.encrypted, vector.passphrase)
+		if err != nil {
+			t.Errorf("%v: %v", vector.encrypted, err)
+			continue
+		}
+		if wif := decrypted.WIF(decryptedCompressed, false); wif != vector.wif {
+			t.Errorf("%v: expected %v, got %v", vector.encrypted, vector.wif, wif)
+		}
+	}
+
+	if _, _, err := BIP38Decrypt(bip38NonECTestVectors[0].encrypted, "TestingOneTwoThre"); err == nil {
+		t.Error("Expected an error for the wrong passphrase")
+	}
+}
+
+func TestBIP38ECMultiply(t *testing.T) {
+
+	for _, vector := range bip38ECTestVectors {
+
+		// The intermediate code is determined by the passphrase and its owner entropy.
+		buffer, err := utility.DecodeBase58Checksum(vector.intermediate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		intermediate, err := newBIP38IntermediateCode(vector.passphrase, buffer[8:16], vector.lot != 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if intermediate != vector.intermediate {
+			t.Errorf("Expected %v, got %v", vector.intermediate, intermediate)
+		}
+
+		key, compressed, err := BIP38Decrypt(vector.encrypted, vector.passphrase)
+		if err != nil {
+			t.Errorf("%v: %v", vector.encrypted, err)
+			continue
+		}
+		if wif := key.WIF(compressed, false); wif != vector.wif {
+			t.Errorf("%v: expected %v, got %v", vector.encrypted, vector.wif, wif)
+		}
+		publicKey := key.PublicKey()
+		if address := publicKey.Address(compressed, false); address != vector.address {
+			t.Errorf("%v: expected address %v, got %v", vector.encrypted, vector.address, address)
+		}
+
+		if vector.confirmation != "" {
+			address, lot, sequence, err := VerifyBIP38Confirmation(vector.confirmation, vector.passphrase)
+			if err != nil {
+				t.Errorf("%v: %v", vector.confirmation, err)
+			} else if address != vector.address || lot != vector.lot || sequence != vector.sequence {
+				t.Errorf("%v: expected %v %v/%v, got %v %v/%v", vector.confirmation, vector.address, vector.lot, vector.sequence, address, lot, sequence)
+			}
+
+			if _, _, _, err := VerifyBIP38Confirmation(vector.confirmation, "wrong"); err == nil {
+				t.Errorf("%v: expected an error for the wrong passphrase", vector.confirmation)
+			}
+		}
+
+		if _, _, err := BIP38Decrypt(vector.encrypted, "wrong"); err == nil {
+			t.Errorf("%v: expected an error for the wrong passphrase", vector.encrypted)
+		}
+	}
+
+	// Intermediate codes are made from the NFC normalized passphrase too.
+	entropy := make([]byte, 8)
+	composed, _ := newBIP38IntermediateCode("\u03D3", entropy, false)
+	decomposed, _ := newBIP38IntermediateCode("\u03D2\u0301", entropy, false)
+	if composed != decomposed {
+		t.Errorf("Intermediate codes differ for the same passphrase: %v and %v", composed, decomposed)
+	}
+}
+
+func TestBIP38EncryptFromIntermediate(t *testing.T) {
+
+	testCases := []struct {
+		lot        bool
+		compressed bool
+	}{
+		{false, false},
+		{false, true},
+		{true, false},
+		{true, true},
+	}
+
+	for _, testCase := range testCases {
+		var intermediate string
+		var err error
+		if testCase.lot {
+			intermediate, err = NewBIP38IntermediateCodeWithLot("correct horse", 1048575, 4095)
+		} else {
+			intermediate, err = NewBIP38IntermediateCode("correct horse")
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if intermediate[:10] != "passphrase" {
+			t.Errorf("Intermediate code %v should start with \"passphrase\"", intermediate)
+		}
+
+		generated, err := BIP38EncryptFromIntermediate(intermediate, testCase.compressed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if generated.EncryptedKey[:2] != "6P" || generated.ConfirmationCode[:6] != "cfrm38" {
+			t.Errorf("Unexpected prefixes: %v, %v", generated.EncryptedKey, generated.ConfirmationCode)
+		}
+
+		key, compressed, err := BIP38Decrypt(generated.EncryptedKey, "correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicKey := key.PublicKey()
+		if compressed != testCase.compressed || publicKey.Address(compressed, false) != generated.Address {
+			t.Errorf("Decrypted key doesn't match the generated address %v", generated.Address)
+		}
+
+		address, lot, sequence, err := VerifyBIP38Confirmation(generated.ConfirmationCode, "correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if address != generated.Address {
+			t.Errorf("Confirmation gave %v, expected %v", address, generated.Address)
+		}
+		if testCase.lot && (lot != 1048575 || sequence != 4095) {
+			t.Errorf("Expected lot 1048575 and sequence 4095, got %v and %v", lot, sequence)
+		}
+	}
+
+	if _, err := NewBIP38IntermediateCodeWithLot("x", 1048576, 0); err == nil {
+		t.Error("Expected an error for a lot number out of range")
+	}
+	if _, err := NewBIP38IntermediateCodeWithLot("x", 0, 4096); err == nil {
+		t.Error("Expected an error for a sequence number out of range")
+	}
+	if _, err := BIP38EncryptFromIntermediate(bip38NonECTestVectors[0].encrypted, false); err == nil {
+		t.Error("Expected an error for an invalid intermediate code")
+	}
+}
+
+func TestBIP38Invalid(t *testing.T) {
+
+	if _, err := BIP38Encrypt(ecc.NewPrivateKey(big.NewInt(0)), true, "x"); err == nil {
+		t.Error("Expected an error encrypting a zero key")
+	}
+
+	// A valid Base58Check string of the wrong length.
+	if _, _, err := BIP38Decrypt("5KN7MzqK5wt2TP1fQCYyHBtDrXdJuXbUzm4A9rKAteGu3Qi5CVR", "x"); err == nil {
+		t.Error("Expected an error decrypting a WIF")
+	}
+}
@@ -0,0 +1,196 @@
+package wallet
+
+import (
+	"bitcoin-go/utility"
+	"crypto/sha512"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Mnemonic phrases (BIP39). The entropy plus a checksum (the first ENT/32 bits of its SHA256) is
+// split into 11 bit groups, and each group picks a word from a list of 2048. The seed is
+// PBKDF2-HMAC-SHA512 of the phrase, salted with "mnemonic" + passphrase, which can then be used
+// to create a BIP32 master key.
+//
+// The phrase and passphrase are NFKD normalized before hashing, as BIP39 requires, and words are
+// looked up by their NFKD form, so composed and decomposed input (e.g. Japanese or Spanish
+// phrases) give the same entropy and seed.
+
+//go:embed wordlists/english.txt
+var englishWords string
+
+// A list of 2048 distinct words, and the separator used to join them.
+type Wordlist struct {
+	words     []string
+	index     map[string]int
+	separator string
+}
+
+var EnglishWordlist *Wordlist
+
+func init() {
+	var err error
+	EnglishWordlist, err = NewWordlist(strings.Fields(englishWords), " ")
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Creates a wordlist, e.g. one of the other languages from the BIP39 repository. The words
+// have to be in the order of the official list. Japanese uses an ideographic space ("　")
+// as the separator, everything else a regular space.
+func NewWordlist(words []string, separator string) (*Wordlist, error) {
+
+	if len(words) != 2048 {
+		return nil, fmt.Errorf("wordlist must have 2048 words, not %v", len(words))
+	}
+	if separator == "" {
+		return nil, errors.New("wordlist separator can't be empty")
+	}
+
+	index := make(map[string]int, len(words))
+	for i, word := range words {
+		if word == "" {
+			return nil, errors.New("wordlist contains an empty word")
+		}
+		normalized := norm.NFKD.String(word)
+		if _, ok := index[normalized]; ok {
+			return nil, fmt.Errorf("wordlist contains %q more than once", word)
+		}
+		index[normalized] = i
+	}
+
+	return &Wordlist{words: words, index: index, separator: separator}, nil
+}
+
+func (w *Wordlist) Word(i int) string {
+	return w.words[i]
+}
+
+// The position of the word in the list, or -1 if it isn't in it.
+func (w *Wordlist) Index(word string) int {
+	if i, ok := w.index[norm.NFKD.String(word)]; ok {
+		return i
+	}
+	return -1
+}
+
+// Returns size bits (128, 160, 192, 224 or 256) of random entropy for a new mnemonic.
+func NewEntropy(size int) ([]byte, error) {
+	if err := checkEntropySize(size); err != nil {
+		return nil, err
+	}
+	return utility.RandomData(size / 8), nil
+}
+
+func checkEntropySize(size int) error {
+	if size < 128 || size > 256 || size%32 != 0 {
+		return fmt.Errorf("entropy must be 128, 160, 192, 224 or 256 bits, not %v", size)
+	}
+	return nil
+}
+
+// Converts the entropy into a mnemonic phrase (12 to 24 words). A nil wordlist means English.
+func NewMnemonic(entropy []byte, wordlist *Wordlist) (string, error) {
+
+	if wordlist == nil {
+		wordlist = EnglishWordlist
+	}
+
+	size := len(entropy) * 8
+	if err := checkEntropySize(size); err != nil {
+		return "", err
+	}
+
+	// Append the checksum; it's at most 8 bits so its first byte is enough.
+	checksumBits := size / 32
+	data := append(append([]byte{}, entropy...), utility.Sha256(entropy)[0])
+
+	wordCount := (size + checksumBits) / 11
+	words := make([]string, wordCount)
+	for i := range words {
+		words[i] = wordlist.words[readBits(data, i*11, 11)]
+	}
+
+	return strings.Join(words, wordlist.separator), nil
+}
+
+// Recovers the entropy from a mnemonic phrase, checking the words and the checksum.
+func MnemonicToEntropy(mnemonic string, wordlist *Wordlist) ([]byte, error) {
+
+	if wordlist == nil {
+		wordlist = EnglishWordlist
+	}
+
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, fmt.Errorf("mnemonic must have 12, 15, 18, 21 or 24 words, not %v", len(words))
+	}
+
+	totalBits := len(words) * 11
+	checksumBits := totalBits / 33
+	entropyBits := totalBits - checksumBits
+
+	data := make([]byte, (totalBits+7)/8)
+	for i, word := range words {
+		index := wordlist.Index(word)
+		if index < 0 {
+			return nil, fmt.Errorf("%q is not in the wordlist", word)
+		}
+		writeBits(data, i*11, 11, index)
+	}
+
+	entropy := data[:entropyBits/8]
+	checksum := readBits(data, entropyBits, checksumBits)
+	expected := int(utility.Sha256(entropy)[0]) >> (8 - checksumBits)
+	if checksum != expected {
+		return nil, errors.New("invalid mnemonic checksum")
+	}
+
+	return entropy, nil
+}
+
+func IsMnemonicValid(mnemonic string, wordlist *Wordlist) bool {
+	_, err := MnemonicToEntropy(mnemonic, wordlist)
+	return err == nil
+}
+
+// Derives the 64 byte seed from the mnemonic and an optional passphrase. The mnemonic isn't
+// checked, use MnemonicToEntropy or NewMasterKeyFromMnemonic for that.
+func MnemonicToSeed(mnemonic string, passphrase string) []byte {
+	normalized := strings.Join(strings.Fields(norm.NFKD.String(mnemonic)), " ")
+	salt := norm.NFKD.String("mnemonic" + passphrase)
+	return pbkdf2.Key([]byte(normalized), []byte(salt), 2048, 64, sha512.New)
+}
+
+// Validates the mnemonic and creates the BIP32 master key for it.
+func NewMasterKeyFromMnemonic(mnemonic string, passphrase string, wordlist *Wordlist, testnet bool) (*ExtendedKey, error) {
+	if _, err := MnemonicToEntropy(mnemonic, wordlist); err != nil {
+		return nil, err
+	}
+	return NewMasterKey(MnemonicToSeed(mnemonic, passphrase), testnet)
+}
+
+// Reads count bits starting at bit offset (most significant bit first).
+func readBits(data []byte, offset int, count int) int {
+	value := 0
+	for i := offset; i < offset+count; i++ {
+		bit := (data[i/8] >> (7 - i%8)) & 1
+		value = value<<1 | int(bit)
+	}
+	return value
+}
+
+func writeBits(data []byte, offset int, count int, value int) {
+	for i := 0; i < count; i++ {
+		if (value>>(count-1-i))&1 == 1 {
+			pos := offset + i
+			data[pos/8] |= 1 << (7 - pos%8)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// The Trezor test vectors from the BIP39 repository, all with the passphrase "TREZOR".
+var bip39TestVectors = []struct {
+	entropy  string
+	mnemonic string
+	seed     string
+}{
+	{"00000000000000000000000000000000", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04"},
+	{"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f", "legal winner thank year wave sausage worth useful legal winner thank yellow", "2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607"},
+	{"80808080808080808080808080808080", "letter advice cage absurd amount doctor acoustic avoid letter advice cage above", "d71de856f81a8acc65e6fc851a38d4d7ec216fd0796d0a6827a3ad6ed5511a30fa280f12eb2e47ed2ac03b5c462a0358d18d69fe4f985ec81778c1b370b652a8"},
+	{"ffffffffffffffffffffffffffffffff", "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong", "ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069"},
+	{"000000000000000000000000000000000000000000000000", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon agent", "035895f2f481b1b0f01fcf8c289c794660b289981a78f8106447707fdd9666ca06da5a9a565181599b79f53b844d8a71dd9f439c52a3d7b3e8a79c906ac845fa"},
+	{"808080808080808080808080808080808080808080808080", "letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic avoid letter always", "107d7c02a5aa6f38c58083ff74f04c607c2d2c0ecc55501dadd72d025b751bc27fe913ffb796f841c49b1d33b610cf0e91d3aa239027f5e99fe4ce9e5088cd65"},
+	{"0000000000000000000000000000000000000000000000000000000000000000", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art", "bda85446c68413707090a52022edd26a1c9462295029f2e60cd7c4f2bbd3097170af7a4d73245cafa9c3cca8d561a7c3de6f5d4a10be8ed2a5e608d68f92fcc8"},
+	{"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote", "dd48c104698c30cfe2b6142103248622fb7bb0ff692eebb00089b32d22484e1613912f0a5b694407be899ffd31ed3992c456cdf60f5d4564b8ba3f05a69890ad"},
+	{"77c2b00716cec7213839159e404db50d", "jelly better achieve collect unaware mountain thought cargo oxygen act hood bridge", "b5b6d0127db1a9d2226af0c3346031d77af31e918dba64287a1b44b8ebf63cdd52676f672a290aae502472cf2d602c051f3e6f18055e84e4c43897fc4e51a6ff"},
+	{"b63a9c59a6e641f288ebc103017f1da9f8290b3da6bdef7b", "renew stay biology evidence goat welcome casual join adapt armor shuffle fault little machine walk stumble urge swap", "9248d83e06f4cd98debf5b6f010542760df925ce46cf38a1bdb4e4de7d21f5c39366941c69e1bdbf2966e0f6e6dbece898a0e2f0a4c2b3e640953dfe8b7bbdc5"},
+	{"3e141609b97933b66a060dcddc71fad1d91677db872031e85f4c015c5e7e8982", "dignity pass list indicate nasty swamp pool script soccer toe leaf photo multiply desk host tomato cradle drill spread actor shine dismiss champion exotic", "ff7f3184df8696d8bef94b6c03114dbee0ef89ff938712301d27ed8336ca89ef9635da20af07d4175f2bf5f3de130f39c9d9e8dd0472489c19b1a020a940da67"},
+}
+
+func TestBIP39Vectors(t *testing.T) {
+
+	for _, vector := range bip39TestVectors {
+		entropy, _ := hex.DecodeString(vector.entropy)
+
+		mnemonic, err := NewMnemonic(entropy, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mnemonic != vector.mnemonic {
+			t.Errorf("Expected %q, got %q", vector.mnemonic, mnemonic)
+		}
+
+		decoded, err := MnemonicToEntropy(vector.mnemonic, nil)
+		if err != nil {
+			t.Errorf("%q: %v", vector.mnemonic, err)
+		} else if !bytes.Equal(decoded, entropy) {
+			t.Errorf("%q: expected entropy %x, got %x", vector.mnemonic, entropy, decoded)
+		}
+
+		if seed := hex.EncodeToString(MnemonicToSeed(vector.mnemonic, "TREZOR")); seed != vector.seed {
+			t.Errorf("%q: expected seed %v, got %v", vector.mnemonic, vector.seed, seed)
+		}
+	}
+}
+
+func TestBIP39Normalization(t *testing.T) {
+	vector := bip39TestVectors[0]
+
+	// NFKD maps the fullwidth letters to ASCII, so this is the "TREZOR" passphrase.
+	if seed := hex.EncodeToString(MnemonicToSeed(vector.mnemonic, "ＴＲＥＺＯＲ")); seed != vector.seed {
+		t.Errorf("Fullwidth passphrase: expected seed %v, got %v", vector.seed, seed)
+	}
+
+	// Composed and decomposed forms of the same passphrase give the same seed.
+	composed := MnemonicToSeed(vector.mnemonic, "caf\u00e9")
+	if !bytes.Equal(composed, MnemonicToSeed(vector.mnemonic, "cafe\u0301")) {
+		t.Error("Composed and decomposed passphrases should give the same seed")
+	}
+	if bytes.Equal(composed, MnemonicToSeed(vector.mnemonic, "cafe")) {
+		t.Error("Passphrase accents shouldn't be dropped")
+	}
+
+	// A wordlist with composed words accepts decomposed phrases, and both give the same seed.
+	words := make([]string, 2048)
+	for i := range words {
+		words[i] = "\u00e9" + EnglishWordlist.Word(i)
+	}
+	wordlist, err := NewWordlist(words, " ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entropy, _ := hex.DecodeString(vector.entropy)
+	mnemonic, err := NewMnemonic(entropy, wordlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decomposed := strings.ReplaceAll(mnemonic, "\u00e9", "e\u0301")
+
+	decoded, err := MnemonicToEntropy(decomposed, wordlist)
+	if err != nil || !bytes.Equal(decoded, entropy) {
+		t.Errorf("Decomposed mnemonic failed to decode: %v", err)
+	}
+	if !bytes.Equal(MnemonicToSeed(mnemonic, ""), MnemonicToSeed(decomposed, "")) {
+		t.Error("Composed and decomposed mnemonics should give the same seed")
+	}
+
+	// Words that only differ by normalization are duplicates.
+	words[1] = "e\u0301" + EnglishWordlist.Word(0)
+	if _, err := NewWordlist(words, " "); err == nil {
+		t.Error("Expected an error for words with the same normalized form")
+	}
+}
+
+func TestBIP39MasterKey(t *testing.T) {
+
+	// The BIP32 root key from the first Trezor vector.
+	master, err := NewMasterKeyFromMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "TREZOR", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "xprv9s21ZrQH143K3h3fDYiay8mocZ3afhfULfb5GX8kCBdno77K4HiA15Tg23wpbeF1pLfs1c5SPmYHrEpTuuRhxMwvKDwqdKiGJS9XFKzUsAF"
+	if master.String() != expected {
+		t.Errorf("Expected %v, got %v", expected, master.String())
+	}
+
+	if _, err := NewMasterKeyFromMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon", "", nil, false); err == nil {
+		t.Error("Expected an error for a mnemonic with a bad checksum")
+	}
+}
+
+func TestBIP39Invalid(t *testing.T) {
+
+	invalid := []string{
+		"",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon",
+		"legal winner thank year wave sausage worth useful legal winner thank yellow yellow",
+		"letter advice cage absurd amount doctor acoustic avoid letter advice caged above",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo, wrong",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon",
+		"legal winner thank year wave sausage worth useful legal winner thank year wave sausage worth useful legal will will will",
+		"letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic avoid letter always.",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo",
+	}
+
+	for _, mnemonic := range invalid {
+		if IsMnemonicValid(mnemonic, nil) {
+			t.Errorf("Expected %q to be invalid", mnemonic)
+		}
+	}
+
+	for _, size := range []int{0, 64, 120, 136, 288} {
+		if _, err := NewEntropy(size); err == nil {
+			t.Errorf("Expected an error for %v bits of entropy", size)
+		}
+		if _, err := NewMnemonic(make([]byte, size/8), nil); err == nil {
+			t.Errorf("Expected an error for %v bits of entropy", size)
+		}
+	}
+}
+
+func TestBIP39RoundTrip(t *testing.T) {
+
+	for _, size := range []int{128, 160, 192, 224, 256} {
+		entropy, err := NewEntropy(size)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mnemonic, err := NewMnemonic(entropy, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if words := len(strings.Fields(mnemonic)); words != size/32*3 {
+			t.Errorf("Expected %v words, got %v", size/32*3, words)
+		}
+
+		decoded, err := MnemonicToEntropy(mnemonic, nil)
+		if err != nil || !bytes.Equal(decoded, entropy) {
+			t.Errorf("Round trip of %x failed: %v", entropy, err)
+		}
+	}
+}
+
+func TestBIP39CustomWordlist(t *testing.T) {
+
+	// A made up list using an ideographic space as the separator, like the Japanese list.
+	words := make([]string, 2048)
+	for i := range words {
+		words[i] = "w" + EnglishWordlist.Word(i)
+	}
+
+	wordlist, err := NewWordlist(words, "　")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entropy, _ := hex.DecodeString("77c2b00716cec7213839159e404db50d")
+	mnemonic, err := NewMnemonic(entropy, wordlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(mnemonic, "wjelly　wbetter　") {
+		t.Errorf("Unexpected mnemonic %q", mnemonic)
+	}
+
+	decoded, err := MnemonicToEntropy(mnemonic, wordlist)
+	if err != nil || !bytes.Equal(decoded, entropy) {
+		t.Errorf("Round trip with a custom wordlist failed: %v", err)
+	}
+	if IsMnemonicValid(mnemonic, nil) {
+		t.Error("Mnemonic shouldn't be valid with the English list")
+	}
+
+	// NFKD turns the ideographic space into a regular space, so it doesn't affect the seed.
+	spaced := strings.ReplaceAll(mnemonic, "　", " ")
+	if !bytes.Equal(MnemonicToSeed(mnemonic, ""), MnemonicToSeed(spaced, "")) {
+		t.Error("Separator should not affect the seed")
+	}
+
+	if _, err := NewWordlist(words[:2047], " "); err == nil {
+		t.Error("Expected an error for a short wordlist")
+	}
+	words[1] = words[0]
+	if _, err := NewWordlist(words, " "); err == nil {
+		t.Error("Expected an error for duplicate words")
+	}
+}
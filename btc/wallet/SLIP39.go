@@ -0,0 +1,560 @@
+package wallet
+
+import (
+	"bitcoin-go/utility"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Shamir's secret sharing for mnemonic codes (SLIP-39). The master secret is encrypted with the
+// passphrase and split into groups, any GroupThreshold of which can recover it. Each group is in
+// turn split into member shares, any MemberThreshold of which can recover the group's share. Every
+// share is a mnemonic of 20 or more words from a list of 1024, protected by an RS1024 checksum.
+//
+// The splitting is done with polynomials over GF(256). Besides the secret (at x = 255) the
+// polynomial goes through a digest of it (at x = 254), so recovering with wrong or mismatched
+// shares is detected.
+
+//go:embed wordlists/slip39.txt
+var slip39Words string
+
+var slip39Wordlist []string
+var slip39WordIndex map[string]int
+
+const (
+	slip39RadixBits          = 10
+	slip39IdBits             = 15
+	slip39IterationExpBits   = 4
+	slip39ChecksumWords      = 3
+	slip39DigestLength       = 4
+	slip39MetadataWords      = 2 + 2 + slip39ChecksumWords // id and exponent, share parameters, checksum
+	slip39MinStrength        = 128
+	slip39MinMnemonicWords   = slip39MetadataWords + (slip39MinStrength+slip39RadixBits-1)/slip39RadixBits
+	slip39MaxShareCount      = 16
+	slip39BaseIterationCount = 10000
+	slip39RoundCount         = 4
+	slip39SecretIndex        = 255
+	slip39DigestIndex        = 254
+)
+
+// GF(256) with the Rijndael polynomial x^8 + x^4 + x^3 + x + 1, using 3 as the generator.
+var gf256Exp [255]byte
+var gf256Log [256]byte
+
+func init() {
+	slip39Wordlist = strings.Fields(slip39Words)
+	if len(slip39Wordlist) != 1024 {
+		panic(fmt.Sprintf("SLIP-39 wordlist has %v words", len(slip39Wordlist)))
+	}
+	slip39WordIndex = make(map[string]int, len(slip39Wordlist))
+	for i, word := range slip39Wordlist {
+		slip39WordIndex[word] = i
+	}
+
+	poly := 1
+	for i := 0; i < 255; i++ {
+		gf256Exp[i] = byte(poly)
+		gf256Log[poly] = byte(i)
+		poly = (poly << 1) ^ poly
+		if poly&0x100 != 0 {
+			poly ^= 0x11b
+		}
+	}
+}
+
+// The number of shares a group is split into, and how many of them are needed to recover it.
+type SLIP39Group struct {
+	MemberThreshold int
+	MemberCount     int
+}
+
+// A single decoded share.
+type SLIP39Share struct {
+	Identifier        uint16 // Random, the same for all shares of a secret
+	Extendable        bool   // If set, the identifier isn't part of the encryption salt
+	IterationExponent byte   // The PBKDF2 iterations are 10000 << IterationExponent
+	GroupIndex        byte
+	GroupThreshold    byte
+	GroupCount        byte
+	MemberIndex       byte
+	MemberThreshold   byte
+	Value             []byte
+}
+
+// Splits the master secret (at least 16 bytes, and an even number of them) into mnemonic shares,
+// returned per group. The passphrase must be printable ASCII. Each increment of the iteration
+// exponent doubles the time needed to encrypt (and brute force) the secret.
+func SplitMasterSecret(masterSecret []byte, passphrase string, groupThreshold int, groups []SLIP39Group, extendable bool, iterationExponent int) ([][]string, error) {
+
+	if len(masterSecret)*8 < slip39MinStrength {
+		return nil, fmt.Errorf("master secret must be at least %v bits", slip39MinStrength)
+	}
+	if len(masterSecret)%2 != 0 {
+		return nil, errors.New("master secret must have an even number of bytes")
+	}
+	if err := checkSLIP39Passphrase(passphrase); err != nil {
+		return nil, err
+	}
+	if iterationExponent < 0 || iterationExponent >= 1<<slip39IterationExpBits {
+		return nil, fmt.Errorf("iteration exponent must be between 0 and %v", 1<<slip39IterationExpBits-1)
+	}
+	if groupThreshold > len(groups) {
+		return nil, fmt.Errorf("group threshold %v exceeds the number of groups (%v)", groupThreshold, len(groups))
+	}
+	for _, group := range groups {
+		if group.MemberThreshold == 1 && group.MemberCount > 1 {
+			return nil, errors.New("a member threshold of 1 with multiple shares isn't allowed, use 1-of-1 instead")
+		}
+	}
+
+	random := utility.RandomData(2)
+	identifier := (uint16(random[0])<<8 | uint16(random[1])) & (1<<slip39IdBits - 1)
+
+	encrypted := slip39Encrypt(masterSecret, passphrase, byte(iterationExponent), identifier, extendable)
+
+	groupShares, err := splitSecret(groupThreshold, len(groups), encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	mnemonics := make([][]string, len(groups))
+	for i, group := range groups {
+
+		memberShares, err := splitSecret(group.MemberThreshold, group.MemberCount, groupShares[i])
+		if err != nil {
+			return nil, err
+		}
+
+		mnemonics[i] = make([]string, len(memberShares))
+		for j, value := range memberShares {
+			share := SLIP39Share{
+				Identifier:        identifier,
+				Extendable:        extendable,
+				IterationExponent: byte(iterationExponent),
+				GroupIndex:        byte(i),
+				GroupThreshold:    byte(groupThreshold),
+				GroupCount:        byte(len(groups)),
+				MemberIndex:       byte(j),
+				MemberThreshold:   byte(group.MemberThreshold),
+				Value:             value,
+			}
+			mnemonics[i][j] = share.Mnemonic()
+		}
+	}
+
+	return mnemonics, nil
+}
+
+// Recovers the master secret from enough shares of enough groups. Extra groups or shares are an
+// error, as the digest can only be checked with exactly the threshold number of them.
+func CombineMnemonics(mnemonics []string, passphrase string) ([]byte, error) {
+
+	if len(mnemonics) == 0 {
+		return nil, errors.New("no mnemonics given")
+	}
+	if err := checkSLIP39Passphrase(passphrase); err != nil {
+		return nil, err
+	}
+
+	shares := make([]*SLIP39Share, 0, len(mnemonics))
+	for _, mnemonic := range mnemonics {
+		share, err := ParseSLIP39Share(mnemonic)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+
+	first := shares[0]
+	groups := make(map[byte][]*SLIP39Share)
+	var groupOrder []byte
+
+	for _, share := range shares {
+		if share.Identifier != first.Identifier || share.Extendable != first.Extendable || share.IterationExponent != first.IterationExponent {
+			return nil, errors.New("all mnemonics must begin with the same 2 words")
+		}
+		if share.GroupThreshold != first.GroupThreshold || share.GroupCount != first.GroupCount {
+			return nil, errors.New("all mnemonics must have the same group threshold and group count")
+		}
+
+		group, ok := groups[share.GroupIndex]
+		if !ok {
+			groupOrder = append(groupOrder, share.GroupIndex)
+		}
+
+		// The same mnemonic given twice only counts once.
+		duplicate := false
+		for _, other := range group {
+			if other.MemberThreshold != share.MemberThreshold {
+				return nil, errors.New("all mnemonics in a group must have the same member threshold")
+			}
+			if other.MemberIndex == share.MemberIndex && bytes.Equal(other.Value, share.Value) {
+				duplicate = true
+			}
+		}
+		if !duplicate {
+			groups[share.GroupIndex] = append(group, share)
+		}
+	}
+
+	if len(groups) != int(first.GroupThreshold) {
+		return nil, fmt.Errorf("wrong number of mnemonic groups, expected %v but got %v", first.GroupThreshold, len(groups))
+	}
+
+	groupShares := make([]gf256Share, 0, len(groups))
+	for _, groupIndex := range groupOrder {
+		group := groups[groupIndex]
+
+		threshold := int(group[0].MemberThreshold)
+		if len(group) != threshold {
+			return nil, fmt.Errorf("wrong number of mnemonics in group %v, expected %v but got %v", groupIndex, threshold, len(group))
+		}
+
+		memberShares := make([]gf256Share, len(group))
+		for i, share := range group {
+			memberShares[i] = gf256Share{x: share.MemberIndex, value: share.Value}
+		}
+
+		secret, err := recoverSecret(threshold, memberShares)
+		if err != nil {
+			return nil, err
+		}
+		groupShares = append(groupShares, gf256Share{x: groupIndex, value: secret})
+	}
+
+	encrypted, err := recoverSecret(int(first.GroupThreshold), groupShares)
+	if err != nil {
+		return nil, err
+	}
+
+	return slip39Decrypt(encrypted, passphrase, first.IterationExponent, first.Identifier, first.Extendable), nil
+}
+
+// Decodes a share mnemonic, checking the words, checksum and padding.
+func ParseSLIP39Share(mnemonic string) (*SLIP39Share, error) {
+
+	words := strings.Fields(strings.ToLower(mnemonic))
+	if len(words) < slip39MinMnemonicWords {
+		return nil, fmt.Errorf("mnemonic must have at least %v words, not %v", slip39MinMnemonicWords, len(words))
+	}
+
+	indices := make([]int, len(words))
+	for i, word := range words {
+		index, ok := slip39WordIndex[word]
+		if !ok {
+			return nil, fmt.Errorf("%q is not in the wordlist", word)
+		}
+		indices[i] = index
+	}
+
+	// The value is a multiple of 16 bits, with up to 8 bits of zero padding in front of it.
+	paddingBits := (slip39RadixBits * (len(indices) - slip39MetadataWords)) % 16
+	if paddingBits > 8 {
+		return nil, errors.New("invalid mnemonic length")
+	}
+
+	idExp := indices[0]<<slip39RadixBits | indices[1]
+	share := &SLIP39Share{
+		Identifier:        uint16(idExp >> (slip39IterationExpBits + 1)),
+		Extendable:        (idExp>>slip39IterationExpBits)&1 == 1,
+		IterationExponent: byte(idExp & (1<<slip39IterationExpBits - 1)),
+	}
+
+	if !rs1024Verify(indices, share.Extendable) {
+		return nil, errors.New("invalid mnemonic checksum")
+	}
+
+	// 5 nibbles: group index, group threshold, group count, member index, member threshold.
+	params := indices[2]<<slip39RadixBits | indices[3]
+	share.GroupIndex = byte(params >> 16 & 0xf)
+	share.GroupThreshold = byte(params>>12&0xf) + 1
+	share.GroupCount = byte(params>>8&0xf) + 1
+	share.MemberIndex = byte(params >> 4 & 0xf)
+	share.MemberThreshold = byte(params&0xf) + 1
+
+	if share.GroupCount < share.GroupThreshold {
+		return nil, errors.New("group threshold can't be greater than the group count")
+	}
+
+	valueIndices := indices[4 : len(indices)-slip39ChecksumWords]
+	value := new(big.Int)
+	for _, index := range valueIndices {
+		value.Lsh(value, slip39RadixBits)
+		value.Or(value, big.NewInt(int64(index)))
+	}
+
+	valueLength := (slip39RadixBits*len(valueIndices) - paddingBits) / 8
+	if value.BitLen() > valueLength*8 {
+		return nil, errors.New("invalid mnemonic padding")
+	}
+	share.Value = value.FillBytes(make([]byte, valueLength))
+
+	return share, nil
+}
+
+// Encodes the share as a mnemonic.
+func (s *SLIP39Share) Mnemonic() string {
+
+	extendable := 0
+	if s.Extendable {
+		extendable = 1
+	}
+	idExp := int(s.Identifier)<<(slip39IterationExpBits+1) | extendable<<slip39IterationExpBits | int(s.IterationExponent)
+	params := int(s.GroupIndex)<<16 | int(s.GroupThreshold-1)<<12 | int(s.GroupCount-1)<<8 | int(s.MemberIndex)<<4 | int(s.MemberThreshold-1)
+
+	indices := []int{idExp >> slip39RadixBits, idExp & 0x3ff, params >> slip39RadixBits, params & 0x3ff}
+
+	// The value, left padded with zeros to a multiple of 10 bits.
+	valueWords := (len(s.Value)*8 + slip39RadixBits - 1) / slip39RadixBits
+	value := new(big.Int).SetBytes(s.Value)
+	for i := valueWords - 1; i >= 0; i-- {
+		word := new(big.Int).Rsh(value, uint(i*slip39RadixBits))
+		indices = append(indices, int(word.Int64()&0x3ff))
+	}
+
+	indices = append(indices, rs1024Checksum(indices, s.Extendable)...)
+
+	words := make([]string, len(indices))
+	for i, index := range indices {
+		words[i] = slip39Wordlist[index]
+	}
+	return strings.Join(words, " ")
+}
+
+func checkSLIP39Passphrase(passphrase string) error {
+	for _, c := range passphrase {
+		if c < 32 || c > 126 {
+			return errors.New("passphrase must only contain printable ASCII characters")
+		}
+	}
+	return nil
+}
+
+// The master secret is encrypted with a 4 round Feistel network, using PBKDF2-HMAC-SHA256 of the
+// passphrase as the round function.
+func slip39Encrypt(masterSecret []byte, passphrase string, iterationExponent byte, identifier uint16, extendable bool) []byte {
+	half := len(masterSecret) / 2
+	l := append([]byte{}, masterSecret[:half]...)
+	r := append([]byte{}, masterSecret[half:]...)
+	salt := slip39Salt(identifier, extendable)
+
+	for i := 0; i < slip39RoundCount; i++ {
+		f := slip39RoundFunction(byte(i), passphrase, iterationExponent, salt, r)
+		l, r = r, xorBytes(l, f)
+	}
+	return append(r, l...)
+}
+
+func slip39Decrypt(encrypted []byte, passphrase string, iterationExponent byte, identifier uint16, extendable bool) []byte {
+	half := len(encrypted) / 2
+	l := append([]byte{}, encrypted[:half]...)
+	r := append([]byte{}, encrypted[half:]...)
+	salt := slip39Salt(identifier, extendable)
+
+	for i := slip39RoundCount - 1; i >= 0; i-- {
+		f := slip39RoundFunction(byte(i), passphrase, iterationExponent, salt, r)
+		l, r = r, xorBytes(l, f)
+	}
+	return append(r, l...)
+}
+
+func slip39RoundFunction(round byte, passphrase string, iterationExponent byte, salt []byte, r []byte) []byte {
+	password := append([]byte{round}, passphrase...)
+	iterations := (slip39BaseIterationCount << iterationExponent) / slip39RoundCount
+	return pbkdf2.Key(password, append(append([]byte{}, salt...), r...), iterations, len(r), sha256.New)
+}
+
+// Extendable backups leave the identifier out of the salt, so new shares with a different
+// identifier can be made for the same encrypted secret.
+func slip39Salt(identifier uint16, extendable bool) []byte {
+	if extendable {
+		return nil
+	}
+	return append([]byte("shamir"), byte(identifier>>8), byte(identifier))
+}
+
+func xorBytes(a []byte, b []byte) []byte {
+	result := make([]byte, len(a))
+	for i := range a {
+		result[i] = a[i] ^ b[i]
+	}
+	return result
+}
+
+// RS1024, a Reed-Solomon code over GF(1024) that detects any error in up to 3 words.
+func rs1024Polymod(values []int) int {
+	gen := [10]int{0xe0e040, 0x1c1c080, 0x3838100, 0x7070200, 0xe0e0009, 0x1c0c2412, 0x38086c24, 0x3090fc48, 0x21b1f890, 0x3f3f120}
+	chk := 1
+	for _, v := range values {
+		b := chk >> 20
+		chk = (chk&0xfffff)<<10 ^ v
+		for i := 0; i < 10; i++ {
+			if (b>>i)&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+func rs1024CustomizationString(extendable bool) []int {
+	s := "shamir"
+	if extendable {
+		s = "shamir_extendable"
+	}
+	values := make([]int, len(s))
+	for i := range s {
+		values[i] = int(s[i])
+	}
+	return values
+}
+
+func rs1024Checksum(data []int, extendable bool) []int {
+	values := append(rs1024CustomizationString(extendable), data...)
+	polymod := rs1024Polymod(append(values, 0, 0, 0)) ^ 1
+
+	checksum := make([]int, slip39ChecksumWords)
+	for i := range checksum {
+		checksum[i] = (polymod >> (10 * (slip39ChecksumWords - 1 - i))) & 0x3ff
+	}
+	return checksum
+}
+
+func rs1024Verify(data []int, extendable bool) bool {
+	return rs1024Polymod(append(rs1024CustomizationString(extendable), data...)) == 1
+}
+
+// A point on the sharing polynomial; every byte of the value is a separate polynomial.
+type gf256Share struct {
+	x     byte
+	value []byte
+}
+
+// Splits the secret into count shares, any threshold of which recover it.
+func splitSecret(threshold int, count int, secret []byte) ([][]byte, error) {
+
+	if threshold < 1 {
+		return nil, errors.New("threshold must be at least 1")
+	}
+	if threshold > count {
+		return nil, fmt.Errorf("threshold %v exceeds the share count %v", threshold, count)
+	}
+	if count > slip39MaxShareCount {
+		return nil, fmt.Errorf("share count can't exceed %v", slip39MaxShareCount)
+	}
+
+	shares := make([][]byte, count)
+
+	if threshold == 1 {
+		for i := range shares {
+			shares[i] = secret
+		}
+		return shares, nil
+	}
+
+	// threshold - 2 random shares, plus the digest and the secret, define the polynomial.
+	randomCount := threshold - 2
+	base := make([]gf256Share, 0, threshold)
+	for i := 0; i < randomCount; i++ {
+		shares[i] = utility.RandomData(len(secret))
+		base = append(base, gf256Share{x: byte(i), value: shares[i]})
+	}
+
+	randomPart := utility.RandomData(len(secret) - slip39DigestLength)
+	digest := append(slip39Digest(randomPart, secret), randomPart...)
+	base = append(base, gf256Share{x: slip39DigestIndex, value: digest}, gf256Share{x: slip39SecretIndex, value: secret})
+
+	for i := randomCount; i < count; i++ {
+		value, err := interpolate(base, byte(i))
+		if err != nil {
+			return nil, err
+		}
+		shares[i] = value
+	}
+
+	return shares, nil
+}
+
+func recoverSecret(threshold int, shares []gf256Share) ([]byte, error) {
+
+	if threshold == 1 {
+		return shares[0].value, nil
+	}
+
+	secret, err := interpolate(shares, slip39SecretIndex)
+	if err != nil {
+		return nil, err
+	}
+	digestShare, err := interpolate(shares, slip39DigestIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hmac.Equal(digestShare[:slip39DigestLength], slip39Digest(digestShare[slip39DigestLength:], secret)) {
+		return nil, errors.New("invalid digest of the shared secret")
+	}
+
+	return secret, nil
+}
+
+func slip39Digest(randomPart []byte, secret []byte) []byte {
+	mac := hmac.New(sha256.New, randomPart)
+	mac.Write(secret)
+	return mac.Sum(nil)[:slip39DigestLength]
+}
+
+// Evaluates the polynomial through the shares at x, using Lagrange interpolation in GF(256).
+func interpolate(shares []gf256Share, x byte) ([]byte, error) {
+
+	length := len(shares[0].value)
+	seen := make(map[byte]bool, len(shares))
+	for _, share := range shares {
+		if seen[share.x] {
+			return nil, errors.New("share indices must be unique")
+		}
+		seen[share.x] = true
+		if len(share.value) != length {
+			return nil, errors.New("all share values must have the same length")
+		}
+	}
+
+	for _, share := range shares {
+		if share.x == x {
+			return share.value, nil
+		}
+	}
+
+	// Working with logarithms, the basis polynomial for share i at x is
+	// prod(x - x_j) / ((x - x_i) * prod(x_i - x_j)) with j != i; subtraction is XOR.
+	logProduct := 0
+	for _, share := range shares {
+		logProduct += int(gf256Log[share.x^x])
+	}
+
+	result := make([]byte, length)
+	for _, share := range shares {
+		logBasis := logProduct - int(gf256Log[share.x^x])
+		for _, other := range shares {
+			if other.x != share.x {
+				logBasis -= int(gf256Log[share.x^other.x])
+			}
+		}
+		logBasis = ((logBasis % 255) + 255) % 255
+
+		for i, v := range share.value {
+			if v != 0 {
+				result[i] ^= gf256Exp[(int(gf256Log[v])+logBasis)%255]
+			}
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,227 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+// The errors expected for the invalid vectors, matched against their descriptions.
+var slip39VectorErrors = []struct {
+	description string
+	err         string
+}{
+	{"invalid checksum", "invalid mnemonic checksum"},
+	{"invalid padding", "invalid mnemonic padding"},
+	{"Basic sharing 2-of-3", "wrong number of mnemonics in group"},
+	{"different identifiers", "must begin with the same 2 words"},
+	{"different iteration exponents", "must begin with the same 2 words"}, // The exponent is part of the first 2 words
+	{"mismatching group thresholds", "same group threshold and group count"},
+	{"mismatching group counts", "same group threshold and group count"},
+	{"greater group threshold than group counts", "group threshold can't be greater than the group count"},
+	{"duplicate member indices", "share indices must be unique"},
+	{"mismatching member thresholds", "same member threshold"},
+	{"invalid digest", "invalid digest of the shared secret"},
+	{"Insufficient number of groups", "wrong number of mnemonic groups"},
+	{"insufficient number of members", "wrong number of mnemonics in group"},
+	{"insufficient length", "mnemonic must have at least"},
+	{"invalid master secret length", "invalid mnemonic length"},
+}
+
+type slip39Vector struct {
+	description string
+	mnemonics   []string
+	secret      string
+	xprv        string
+}
+
+// The vectors are arrays rather than objects.
+func (v *slip39Vector) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &[]interface{}{&v.description, &v.mnemonics, &v.secret, &v.xprv})
+}
+
+// The vectors.json from the SLIP-39 reference implementation (python-shamir-mnemonic), all with the
+// passphrase "TREZOR". Each vector is a description, the mnemonics, the master secret and the BIP32
+// master key; an empty secret means the mnemonics must be rejected.
+func TestSLIP39Vectors(t *testing.T) {
+
+	data, err := os.ReadFile("testdata/slip39_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors []slip39Vector
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 45 {
+		t.Fatalf("Expected 45 vectors, got %v", len(vectors))
+	}
+
+	for _, vector := range vectors {
+		secret, err := CombineMnemonics(vector.mnemonics, "TREZOR")
+
+		if vector.secret == "" {
+			expected := ""
+			for _, e := range slip39VectorErrors {
+				if strings.Contains(vector.description, e.description) {
+					expected = e.err
+					break
+				}
+			}
+			if expected == "" {
+				t.Fatalf("%v: no expected error", vector.description)
+			}
+
+			if err == nil {
+				t.Errorf("%v: expected an error, got %x", vector.description, secret)
+			} else if !strings.Contains(err.Error(), expected) {
+				t.Errorf("%v: expected %q, got %q", vector.description, expected, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v: %v", vector.description, err)
+			continue
+		}
+		if hex.EncodeToString(secret) != vector.secret {
+			t.Errorf("%v: expected %v, got %x", vector.description, vector.secret, secret)
+		}
+
+		master, err := NewMasterKey(secret, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if master.String() != vector.xprv {
+			t.Errorf("%v: expected %v, got %v", vector.description, vector.xprv, master.String())
+		}
+
+		// Decoding and encoding each share should give back the same mnemonic.
+		for _, mnemonic := range vector.mnemonics {
+			share, err := ParseSLIP39Share(mnemonic)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if share.Mnemonic() != mnemonic {
+				t.Errorf("%v: expected %q, got %q", vector.description, mnemonic, share.Mnemonic())
+			}
+		}
+	}
+}
+
+func TestSLIP39SplitCombine(t *testing.T) {
+
+	masterSecret, _ := hex.DecodeString("0c94af0ba6b1e7a9ea7ae0e4b86b3d8f")
+
+	// 2 of 3 groups: a 1-of-1, a 2-of-3 and a 3-of-5.
+	groups := []SLIP39Group{{1, 1}, {2, 3}, {3, 5}}
+	mnemonics, err := SplitMasterSecret(masterSecret, "TREZOR", 2, groups, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, group := range groups {
+		if len(mnemonics[i]) != group.MemberCount {
+			t.Fatalf("Group %v has %v shares, expected %v", i, len(mnemonics[i]), group.MemberCount)
+		}
+	}
+
+	testCases := []struct {
+		mnemonics []string
+		valid     bool
+	}{
+		{[]string{mnemonics[0][0], mnemonics[1][0], mnemonics[1][2]}, true},
+		{[]string{mnemonics[2][4], mnemonics[1][1], mnemonics[2][0], mnemonics[1][0], mnemonics[2][2]}, true},
+		{[]string{mnemonics[0][0], mnemonics[2][1], mnemonics[2][2], mnemonics[2][3]}, true},
+		{[]string{mnemonics[0][0], mnemonics[1][0], mnemonics[1][0]}, false},                  // The same share twice
+		{[]string{mnemonics[0][0], mnemonics[1][0]}, false},                                   // Not enough members
+		{[]string{mnemonics[1][0], mnemonics[1][1]}, false},                                   // Not enough groups
+		{[]string{mnemonics[0][0], mnemonics[1][0], mnemonics[1][1], mnemonics[1][2]}, false}, // Too many members
+		{[]string{mnemonics[0][0], mnemonics[1][0], mnemonics[1][1], mnemonics[2][0]}, false}, // Too many groups
+	}
+
+	for i, testCase := range testCases {
+		secret, err := CombineMnemonics(testCase.mnemonics, "TREZOR")
+		if testCase.valid {
+			if err != nil {
+				t.Errorf("Case %v: %v", i, err)
+			} else if !bytes.Equal(secret, masterSecret) {
+				t.Errorf("Case %v: expected %x, got %x", i, masterSecret, secret)
+			}
+		} else if err == nil {
+			t.Errorf("Case %v: expected an error", i)
+		}
+	}
+
+	// The passphrase isn't checked, a wrong one gives a different secret.
+	secret, err := CombineMnemonics([]string{mnemonics[0][0], mnemonics[1][0], mnemonics[1][1]}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(secret, masterSecret) {
+		t.Error("A different passphrase should give a different secret")
+	}
+}
+
+func TestSLIP39Extendable(t *testing.T) {
+
+	masterSecret, _ := hex.DecodeString("a7e96f5d9bc4a3b2cf8b4a0e2e1bd0c86b9a6d2f0b9a7c4e33c1e4fa0b1c2d3e")
+
+	// Splitting twice gives different identifiers, which don't matter for extendable backups.
+	first, err := SplitMasterSecret(masterSecret, "", 1, []SLIP39Group{{2, 3}}, true, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pair := range [][]string{{first[0][0], first[0][1]}, {first[0][2], first[0][0]}} {
+		secret, err := CombineMnemonics(pair, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(secret, masterSecret) {
+			t.Errorf("Expected %x, got %x", masterSecret, secret)
+		}
+	}
+
+	share, err := ParseSLIP39Share(first[0][0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !share.Extendable || share.IterationExponent != 1 || share.MemberThreshold != 2 || share.GroupCount != 1 {
+		t.Errorf("Unexpected share parameters %+v", share)
+	}
+	if len(strings.Fields(first[0][0])) != 33 {
+		t.Errorf("A 256 bit secret should give 33 words, got %v", len(strings.Fields(first[0][0])))
+	}
+}
+
+func TestSLIP39SplitInvalid(t *testing.T) {
+
+	secret := make([]byte, 16)
+
+	testCases := []struct {
+		secret         []byte
+		passphrase     string
+		groupThreshold int
+		groups         []SLIP39Group
+	}{
+		{make([]byte, 14), "", 1, []SLIP39Group{{1, 1}}}, // Too short
+		{make([]byte, 17), "", 1, []SLIP39Group{{1, 1}}}, // Odd length
+		{secret, "pässword", 1, []SLIP39Group{{1, 1}}},   // Not ASCII
+		{secret, "", 2, []SLIP39Group{{1, 1}}},           // More groups needed than exist
+		{secret, "", 1, []SLIP39Group{{3, 2}}},           // Member threshold above the count
+		{secret, "", 1, []SLIP39Group{{1, 3}}},           // Multiple 1-of-n shares
+		{secret, "", 1, []SLIP39Group{{2, 17}}},          // Too many shares
+		{secret, "", 0, []SLIP39Group{{1, 1}}},           // Zero threshold
+		{secret, "", 1, []SLIP39Group{{1, 1}, {0, 2}}},   // Zero member threshold
+	}
+
+	for i, testCase := range testCases {
+		if _, err := SplitMasterSecret(testCase.secret, testCase.passphrase, testCase.groupThreshold, testCase.groups, false, 0); err == nil {
+			t.Errorf("Case %v: expected an error", i)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+package ecc
+
+import (
+	"bitcoin-go/utility"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Adaptor signatures. A pre-signature is locked to an adaptor point T = t*G: anyone can check that
+// it is valid, but only someone who knows t can turn it into a real signature, and once that real
+// signature is published the pre-signature holder learns t. This is what makes atomic swaps and
+// other scriptless scripts work.
+
+// A Schnorr pre-signature. R is the final nonce point (k*G + T); its parity decides how the secret
+// gets combined with S.
+type SchnorrAdaptorSignature struct {
+	R Point
+	S *big.Int
+}
+
+// The sizes of serialized pre-signatures: R (compressed, since its parity matters) and s for
+// Schnorr, R, R', s and the two DLEQ proof values for ECDSA.
+const (
+	SchnorrAdaptorSignatureSize = 33 + 32
+	ECDSAAdaptorSignatureSize   = 33 + 33 + 32 + 32 + 32
+)
+
+// Parses a pre-signature serialized with Serialize, so it can be sent to the other party.
+func ParseSchnorrAdaptorSignature(buffer []byte) (SchnorrAdaptorSignature, error) {
+	if len(buffer) != SchnorrAdaptorSignatureSize {
+		return SchnorrAdaptorSignature{}, fmt.Errorf("schnorr pre-signature must be %v bytes", SchnorrAdaptorSignatureSize)
+	}
+
+	R, err := parseCompressedPoint(buffer[:33])
+	if err != nil {
+		return SchnorrAdaptorSignature{}, err
+	}
+	s, err := parseScalarBytes(buffer[33:])
+	if err != nil {
+		return SchnorrAdaptorSignature{}, err
+	}
+
+	return SchnorrAdaptorSignature{R: R, S: s}, nil
+}
+
+func (sig *SchnorrAdaptorSignature) Serialize() []byte {
+	buffer := make([]byte, SchnorrAdaptorSignatureSize)
+	copy(buffer, sig.R.ToSEC(true))
+	fillBufferWithIntBytes(buffer[33:], sig.S, false)
+	return buffer
+}
+
+// Creates a pre-signature of msg locked to the adaptor point.
+func (key *PrivateKey) SchnorrAdaptorSign(msg []byte, adaptor *Point, auxRand []byte) (SchnorrAdaptorSignature, error) {
+
+	if key.Secret.Sign() <= 0 || key.Secret.Cmp(N) >= 0 {
+		return SchnorrAdaptorSignature{}, errors.New("secret must be in the range [1, N-1]")
+	}
+	if adaptor == nil || adaptor.x == nil {
+		return SchnorrAdaptorSignature{}, errors.New("adaptor point is the point at infinity")
+	}
+
+	if auxRand == nil {
+		auxRand = utility.RandomData(32)
+	} else if len(auxRand) != 32 {
+		return SchnorrAdaptorSignature{}, errors.New("aux randomness must be 32 bytes")
+	}
+
+	d := NewScalar(key.Secret)
+	pub := G.ScalarMultiplyConstantTime(d)
+	if !pub.hasEvenY() {
+		d = d.Negate()
+	}
+	pubBytes := pub.ToXOnly()
+
+	// Same construction as BIP340, but with its own tag and the adaptor point committed to so a
+	// pre-signature never shares a nonce with a regular signature.
+	t := utility.TaggedHash("BIP0340/aux", auxRand)
+	dBytes := d.Bytes()
+	for i := range t {
+		t[i] ^= dBytes[i]
+	}
+
+	k := NewScalarFromBytes(utility.TaggedHash("SchnorrAdaptor/nonce", t, pubBytes, adaptor.ToSEC(true), msg))
+	if k.IsZero() {
+		return SchnorrAdaptorSignature{}, errors.New("derived nonce is zero")
+	}
+
+	kG := G.ScalarMultiplyConstantTime(k)
+	R := kG.Add(adaptor)
+	if R.x == nil {
+		return SchnorrAdaptorSignature{}, errors.New("nonce point is the point at infinity")
+	}
+
+	// The completed signature will use the even y version of R, so flip k to match.
+	if !R.hasEvenY() {
+		k = k.Negate()
+	}
+
+	e := NewScalar(schnorrChallenge(R.ToXOnly(), pubBytes, msg))
+	s := k.Add(e.Mul(d)).BigInt()
+
+	return SchnorrAdaptorSignature{R: R, S: s}, nil
+}
+
+// Checks that the pre-signature will turn into a valid signature of msg once the adaptor secret is added.
+func (p *Point) VerifySchnorrAdaptor(msg []byte, adaptor *Point, sig SchnorrAdaptorSignature) bool {
+
+	if p.x == nil || adaptor == nil || adaptor.x == nil || sig.R.x == nil || sig.S == nil || sig.S.Cmp(N) >= 0 {
+		return false
+	}
+
+	pub, err := liftX(p.x)
+	if err != nil {
+		return false
+	}
+
+	// R' = R - T, negated if R has an odd y.
+	negT := adaptor.negate()
+	expected := sig.R.Add(&negT)
+	if !sig.R.hasEvenY() {
+		expected = expected.negate()
+	}
+
+	// s'*G == R' + e*P
+	e := schnorrChallenge(sig.R.ToXOnly(), pub.ToXOnly(), msg)
+	sG := G.ScalarMultiply(sig.S)
+	eP := pub.ScalarMultiply(e)
+	negEP := eP.negate()
+	actual := sG.Add(&negEP)
+
+	return actual.Equals(&expected)
+}
+
+// Completes the pre-signature with the adaptor secret.
+func (sig *SchnorrAdaptorSignature) Complete(secret *big.Int) SchnorrSignature {
+	t := NewScalar(secret)
+	if !sig.R.hasEvenY() {
+		t = t.Negate()
+	}
+
+	s := NewScalar(sig.S).Add(t)
+	return SchnorrSignature{R: sig.R.x, S: s.BigInt()}
+}
+
+// Recovers the adaptor secret from the pre-signature and the completed signature.
+func (sig *SchnorrAdaptorSignature) ExtractSecret(final SchnorrSignature, adaptor *Point) (*big.Int, error) {
+	if final.R == nil || final.S == nil || final.R.Cmp(sig.R.x) != 0 {
+		return nil, errors.New("signature doesn't belong to this pre-signature")
+	}
+
+	t := NewScalar(final.S).Sub(NewScalar(sig.S))
+	if !sig.R.hasEvenY() {
+		t = t.Negate()
+	}
+
+	secret := t.BigInt()
+	tG := G.ScalarMultiplyConstantTime(t)
+	if !tG.Equals(adaptor) {
+		return nil, errors.New("extracted secret doesn't match the adaptor point")
+	}
+	return secret, nil
+}
+
+// An ECDSA pre-signature (one-time verifiably encrypted signature). With nonce k:
+//
+//	R' = k*G, R = k*T, s' = k^-1 * (z + r*d) where r = R.x mod N
+//
+// Completing it divides s' by t, which makes R = (k*t)*G the real nonce point. The DLEQ proof
+// shows that R and R' use the same k, without it the signer could cheat.
+type ECDSAAdaptorSignature struct {
+	R      Point
+	RPrime Point
+	S      *big.Int
+	proofE *big.Int
+	proofS *big.Int
+}
+
+// Parses a pre-signature serialized with Serialize, DLEQ proof included so VerifyECDSAAdaptor can
+// check it.
+func ParseECDSAAdaptorSignature(buffer []byte) (ECDSAAdaptorSignature, error) {
+	if len(buffer) != ECDSAAdaptorSignatureSize {
+		return ECDSAAdaptorSignature{}, fmt.Errorf("ECDSA pre-signature must be %v bytes", ECDSAAdaptorSignatureSize)
+	}
+
+	R, err := parseCompressedPoint(buffer[:33])
+	if err != nil {
+		return ECDSAAdaptorSignature{}, err
+	}
+	RPrime, err := parseCompressedPoint(buffer[33:66])
+	if err != nil {
+		return ECDSAAdaptorSignature{}, err
+	}
+
+	scalars := make([]*big.Int, 3)
+	for i := range scalars {
+		start := 66 + i*32
+		if scalars[i], err = parseScalarBytes(buffer[start : start+32]); err != nil {
+			return ECDSAAdaptorSignature{}, err
+		}
+	}
+
+	return ECDSAAdaptorSignature{R: R, RPrime: RPrime, S: scalars[0], proofE: scalars[1], proofS: scalars[2]}, nil
+}
+
+func (sig *ECDSAAdaptorSignature) Serialize() []byte {
+	buffer := make([]byte, ECDSAAdaptorSignatureSize)
+	copy(buffer, sig.R.ToSEC(true))
+	copy(buffer[33:], sig.RPrime.ToSEC(true))
+	fillBufferWithIntBytes(buffer[66:98], sig.S, false)
+	fillBufferWithIntBytes(buffer[98:130], sig.proofE, false)
+	fillBufferWithIntBytes(buffer[130:], sig.proofS, false)
+	return buffer
+}
+
+// Creates a pre-signature of the hash locked to the adaptor point.
+func (key *PrivateKey) ECDSAAdaptorSign(hash *big.Int, adaptor *Point) (ECDSAAdaptorSignature, error) {
+
+	if key.Secret.Sign() <= 0 || key.Secret.Cmp(N) >= 0 {
+		return ECDSAAdaptorSignature{}, errors.New("secret must be in the range [1, N-1]")
+	}
+	if adaptor == nil || adaptor.x == nil {
+		return ECDSAAdaptorSignature{}, errors.New("adaptor point is the point at infinity")
+	}
+
+	// RFC 6979 with the adaptor point as extra data, so the nonce differs from a plain signature.
+	k := NewScalar(deterministicK(key.Secret, hash, adaptor.ToSEC(true)))
+
+	RPrime := G.ScalarMultiplyConstantTime(k)
+	R := adaptor.ScalarMultiplyConstantTime(k)
+	r := new(big.Int).Mod(R.x, N)
+	if r.Sign() == 0 {
+		return ECDSAAdaptorSignature{}, errors.New("nonce produced r = 0")
+	}
+
+	d := NewScalar(key.Secret)
+	s := NewScalar(r).Mul(d).Add(NewScalar(hash)).Mul(k.Inverse())
+	if s.IsZero() {
+		return ECDSAAdaptorSignature{}, errors.New("nonce produced s = 0")
+	}
+
+	proofE, proofS, err := dleqProve(k, adaptor, &RPrime, &R)
+	if err != nil {
+		return ECDSAAdaptorSignature{}, err
+	}
+
+	return ECDSAAdaptorSignature{R: R, RPrime: RPrime, S: s.BigInt(), proofE: proofE, proofS: proofS}, nil
+}
+
+// Checks that the pre-signature will turn into a valid signature of the hash once the adaptor secret is applied.
+func (p *Point) VerifyECDSAAdaptor(hash *big.Int, adaptor *Point, sig ECDSAAdaptorSignature) bool {
+
+	if p.x == nil || adaptor == nil || adaptor.x == nil || sig.R.x == nil || sig.RPrime.x == nil {
+		return false
+	}
+	if sig.S == nil || sig.S.Sign() <= 0 || sig.S.Cmp(N) >= 0 {
+		return false
+	}
+
+	if !dleqVerify(adaptor, &sig.RPrime, &sig.R, sig.proofE, sig.proofS) {
+		return false
+	}
+
+	r := new(big.Int).Mod(sig.R.x, N)
+	if r.Sign() == 0 {
+		return false
+	}
+
+	// R' == (z/s')*G + (r/s')*P
+	sInv := new(big.Int).ModInverse(sig.S, N)
+	u1 := generatorMultiply(ModMulPrime(hash, sInv, N))
+	u2 := wnafMultiply(p, ModMulPrime(r, sInv, N))
+	total := u1.add(&u2, Secp256k1)
+	if total.isInfinity() {
+		return false
+	}
+
+	affine := total.toAffine(Secp256k1)
+	return affine.Equals(&sig.RPrime)
+}
+
+// Completes the pre-signature with the adaptor secret, giving a regular (low-s) ECDSA signature.
+func (sig *ECDSAAdaptorSignature) Complete(secret *big.Int) Signature {
+	s := NewScalar(sig.S).Mul(NewScalar(secret).Inverse()).BigInt()
+
+	half_n := new(big.Int).Rsh(N, 1)
+	if s.Cmp(half_n) > 0 {
+		s.Sub(N, s)
+	}
+
+	return Signature{R: new(big.Int).Mod(sig.R.x, N), S: s}
+}
+
+// Recovers the adaptor secret from the pre-signature and the completed signature.
+func (sig *ECDSAAdaptorSignature) ExtractSecret(final Signature, adaptor *Point) (*big.Int, error) {
+	if final.R == nil || final.S == nil || final.S.Sign() == 0 || final.R.Cmp(new(big.Int).Mod(sig.R.x, N)) != 0 {
+		return nil, errors.New("signature doesn't belong to this pre-signature")
+	}
+
+	// t = s' / s, up to the sign flip from low-s normalization.
+	t := NewScalar(sig.S).Mul(NewScalar(final.S).Inverse())
+	for _, candidate := range []Scalar{t, t.Negate()} {
+		tG := G.ScalarMultiplyConstantTime(candidate)
+		if tG.Equals(adaptor) {
+			return candidate.BigInt(), nil
+		}
+	}
+
+	return nil, errors.New("extracted secret doesn't match the adaptor point")
+}
+
+// Chaum-Pedersen proof that log_G(A) == log_Y(B) (= k), made non-interactive with a tagged hash.
+func dleqProve(k Scalar, Y *Point, A *Point, B *Point) (*big.Int, *big.Int, error) {
+	nonce := NewScalarFromBytes(utility.TaggedHash("DLEQ/nonce", k.Bytes(), Y.ToSEC(true), A.ToSEC(true), B.ToSEC(true)))
+	if nonce.IsZero() {
+		return nil, nil, errors.New("derived nonce is zero")
+	}
+
+	nonceG := G.ScalarMultiplyConstantTime(nonce)
+	nonceY := Y.ScalarMultiplyConstantTime(nonce)
+	e := dleqChallenge(Y, A, B, &nonceG, &nonceY)
+
+	s := nonce.Add(NewScalar(e).Mul(k))
+	return e, s.BigInt(), nil
+}
+
+func dleqVerify(Y *Point, A *Point, B *Point, e *big.Int, s *big.Int) bool {
+	if e == nil || s == nil || e.Cmp(N) >= 0 || s.Cmp(N) >= 0 {
+		return false
+	}
+
+	// nonce*G = s*G - e*A and nonce*Y = s*Y - e*B
+	sG := G.ScalarMultiply(s)
+	eA := A.ScalarMultiply(e)
+	negEA := eA.negate()
+	nonceG := sG.Add(&negEA)
+
+	sY := Y.ScalarMultiply(s)
+	eB := B.ScalarMultiply(e)
+	negEB := eB.negate()
+	nonceY := sY.Add(&negEB)
+
+	if nonceG.x == nil || nonceY.x == nil {
+		return false
+	}
+
+	return dleqChallenge(Y, A, B, &nonceG, &nonceY).Cmp(e) == 0
+}
+
+func dleqChallenge(Y *Point, A *Point, B *Point, nonceG *Point, nonceY *Point) *big.Int {
+	e := new(big.Int).SetBytes(utility.TaggedHash("DLEQ/challenge",
+		Y.ToSEC(true), A.ToSEC(true), B.ToSEC(true), nonceG.ToSEC(true), nonceY.ToSEC(true)))
+	return e.Mod(e, N)
+}
+
+func parseScalarBytes(buffer []byte) (*big.Int, error) {
+	x := new(big.Int).SetBytes(buffer)
+	if x.Cmp(N) >= 0 {
+		return nil, errors.New("pre-signature scalar exceeds the curve order")
+	}
+	return x, nil
+}
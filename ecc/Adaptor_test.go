@@ -0,0 +1,260 @@
+package ecc_test
+
+import (
+	"bitcoin-go/ecc"
+	"bitcoin-go/utility"
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestSchnorrAdaptor(t *testing.T) {
+
+	// Try a few keys and adaptors so both parities of R and of the public key get exercised.
+	for i := int64(1); i <= 8; i++ {
+		key := ecc.NewPrivateKey(new(big.Int).SetBytes(utility.Hash256([]byte{byte(i), 'k'})))
+		pub := ecc.G.ScalarMultiply(key.Secret)
+
+		secret := new(big.Int).SetBytes(utility.Hash256([]byte{byte(i), 't'}))
+		adaptor := ecc.G.ScalarMultiply(secret)
+
+		msg := utility.Sha256([]byte("atomic swap"))
+
+		preSig, err := key.SchnorrAdaptorSign(msg, &adaptor, make([]byte, 32))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !pub.VerifySchnorrAdaptor(msg, &adaptor, preSig) {
+			t.Fatalf("Pre-signature %v failed to verify", i)
+		}
+
+		// The pre-signature on its own is not a valid signature.
+		if pub.VerifySchnorr(msg, ecc.SchnorrSignature{R: new(big.Int).SetBytes(preSig.R.ToXOnly()), S: preSig.S}) {
+			t.Errorf("Pre-signature %v verified as a signature", i)
+		}
+
+		// Wrong message or adaptor point.
+		if pub.VerifySchnorrAdaptor(utility.Sha256([]byte("other")), &adaptor, preSig) {
+			t.Errorf("Pre-signature %v verified for the wrong message", i)
+		}
+		other := ecc.G.ScalarMultiply(big.NewInt(12345))
+		if pub.VerifySchnorrAdaptor(msg, &other, preSig) {
+			t.Errorf("Pre-signature %v verified for the wrong adaptor", i)
+		}
+
+		sig := preSig.Complete(secret)
+		if !pub.VerifySchnorr(msg, sig) {
+			t.Fatalf("Completed signature %v failed to verify", i)
+		}
+
+		extracted, err := preSig.ExtractSecret(sig, &adaptor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if extracted.Cmp(new(big.Int).Mod(secret, ecc.N)) != 0 {
+			t.Errorf("Extracted %x, expected %x", extracted, secret)
+		}
+
+		// Completing with the wrong secret gives an invalid signature.
+		if pub.VerifySchnorr(msg, preSig.Complete(big.NewInt(12345))) {
+			t.Errorf("Signature %v completed with the wrong secret verified", i)
+		}
+	}
+}
+
+func TestECDSAAdaptor(t *testing.T) {
+
+	for i := int64(1); i <= 8; i++ {
+		key := ecc.NewPrivateKey(new(big.Int).SetBytes(utility.Hash256([]byte{byte(i), 'k'})))
+		pub := ecc.G.ScalarMultiply(key.Secret)
+
+		secret := new(big.Int).SetBytes(utility.Hash256([]byte{byte(i), 't'}))
+		adaptor := ecc.G.ScalarMultiply(secret)
+
+		hash := new(big.Int).SetBytes(utility.Hash256([]byte("atomic swap")))
+
+		preSig, err := key.ECDSAAdaptorSign(hash, &adaptor)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !pub.VerifyECDSAAdaptor(hash, &adaptor, preSig) {
+			t.Fatalf("Pre-signature %v failed to verify", i)
+		}
+
+		if pub.VerifyECDSAAdaptor(new(big.Int).Add(hash, big.NewInt(1)), &adaptor, preSig) {
+			t.Errorf("Pre-signature %v verified for the wrong hash", i)
+		}
+		other := ecc.G.ScalarMultiply(big.NewInt(12345))
+		if pub.VerifyECDSAAdaptor(hash, &other, preSig) {
+			t.Errorf("Pre-signature %v verified for the wrong adaptor", i)
+		}
+
+		sig := preSig.Complete(secret)
+		if !pub.Verify(hash, sig) {
+			t.Fatalf("Completed signature %v failed to verify", i)
+		}
+
+		extracted, err := preSig.ExtractSecret(sig, &adaptor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if extracted.Cmp(new(big.Int).Mod(secret, ecc.N)) != 0 {
+			t.Errorf("Extracted %x, expected %x", extracted, secret)
+		}
+
+		if pub.Verify(hash, preSig.Complete(big.NewInt(12345))) {
+			t.Errorf("Signature %v completed with the wrong secret verified", i)
+		}
+	}
+}
+
+func TestAdaptorExtractMismatch(t *testing.T) {
+	key := ecc.NewPrivateKey(big.NewInt(1000))
+	secret := big.NewInt(2000)
+	adaptor := ecc.G.ScalarMultiply(secret)
+	msg := utility.Sha256([]byte("msg"))
+
+	preSig, err := key.SchnorrAdaptorSign(msg, &adaptor, make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A signature from a different pre-signature doesn't reveal anything.
+	unrelated, err := key.SignSchnorr(msg, make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := preSig.ExtractSecret(unrelated, &adaptor); err == nil {
+		t.Error("Expected an error extracting from an unrelated signature")
+	}
+}
+
+func TestAdaptorSerialize(t *testing.T) {
+	key := ecc.NewPrivateKey(new(big.Int).SetBytes(utility.Hash256([]byte("key"))))
+	pub := ecc.G.ScalarMultiply(key.Secret)
+	secret := new(big.Int).SetBytes(utility.Hash256([]byte("secret")))
+	adaptor := ecc.G.ScalarMultiply(secret)
+
+	msg := utility.Sha256([]byte("atomic swap"))
+	schnorrPreSig, err := key.SchnorrAdaptorSign(msg, &adaptor, make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serialized := schnorrPreSig.Serialize()
+	if len(serialized) != ecc.SchnorrAdaptorSignatureSize {
+		t.Fatalf("Schnorr pre-signature serialized to %v bytes", len(serialized))
+	}
+	parsedSchnorr, err := ecc.ParseSchnorrAdaptorSignature(serialized)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(parsedSchnorr.Serialize(), serialized) {
+		t.Error("Schnorr pre-signature didn't round trip")
+	}
+	if !pub.VerifySchnorrAdaptor(msg, &adaptor, parsedSchnorr) {
+		t.Error("Parsed Schnorr pre-signature failed to verify")
+	}
+	if !pub.VerifySchnorr(msg, parsedSchnorr.Complete(secret)) {
+		t.Error("Parsed Schnorr pre-signature completed to an invalid signature")
+	}
+
+	hash := new(big.Int).SetBytes(utility.Hash256([]byte("atomic swap")))
+	ecdsaPreSig, err := key.ECDSAAdaptorSign(hash, &adaptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serialized = ecdsaPreSig.Serialize()
+	if len(serialized) != ecc.ECDSAAdaptorSignatureSize {
+		t.Fatalf("ECDSA pre-signature serialized to %v bytes", len(serialized))
+	}
+	parsedECDSA, err := ecc.ParseECDSAAdaptorSignature(serialized)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(parsedECDSA.Serialize(), serialized) {
+		t.Error("ECDSA pre-signature didn't round trip")
+	}
+	if !pub.VerifyECDSAAdaptor(hash, &adaptor, parsedECDSA) {
+		t.Error("Parsed ECDSA pre-signature failed to verify")
+	}
+	if !pub.Verify(hash, parsedECDSA.Complete(secret)) {
+		t.Error("Parsed ECDSA pre-signature completed to an invalid signature")
+	}
+
+	// Tampering with the DLEQ proof must be caught once the signature has been parsed.
+	tampered := append([]byte{}, serialized...)
+	tampered[len(tampered)-1] ^= 1
+	parsedECDSA, err = ecc.ParseECDSAAdaptorSignature(tampered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pub.VerifyECDSAAdaptor(hash, &adaptor, parsedECDSA) {
+		t.Error("ECDSA pre-signature with a tampered proof verified")
+	}
+}
+
+func TestParseAdaptorSignatureInvalid(t *testing.T) {
+	key := ecc.NewPrivateKey(big.NewInt(1000))
+	adaptor := ecc.G.ScalarMultiply(big.NewInt(2000))
+
+	schnorrPreSig, err := key.SchnorrAdaptorSign(utility.Sha256([]byte("msg")), &adaptor, make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaPreSig, err := key.ECDSAAdaptorSign(big.NewInt(3000), &adaptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schnorrBytes := schnorrPreSig.Serialize()
+	ecdsaBytes := ecdsaPreSig.Serialize()
+
+	modify := func(buffer []byte, change func([]byte)) []byte {
+		result := append([]byte{}, buffer...)
+		change(result)
+		return result
+	}
+	uncompressed := func(b []byte) { b[0] = 0x04 }
+	offCurve := func(b []byte) { copy(b[1:33], bytes.Repeat([]byte{0xff}, 32)) }
+	orderAt := func(start int) func([]byte) {
+		return func(b []byte) { ecc.N.FillBytes(b[start : start+32]) }
+	}
+
+	for _, test := range []struct {
+		name   string
+		buffer []byte
+	}{
+		{"empty", nil},
+		{"short", schnorrBytes[:64]},
+		{"long", append(append([]byte{}, schnorrBytes...), 0)},
+		{"uncompressed R", modify(schnorrBytes, uncompressed)},
+		{"R not on curve", modify(schnorrBytes, offCurve)},
+		{"s equal to the order", modify(schnorrBytes, orderAt(33))},
+	} {
+		if _, err := ecc.ParseSchnorrAdaptorSignature(test.buffer); err == nil {
+			t.Errorf("Schnorr %v: expected an error", test.name)
+		}
+	}
+
+	for _, test := range []struct {
+		name   string
+		buffer []byte
+	}{
+		{"empty", nil},
+		{"schnorr size", schnorrBytes},
+		{"short", ecdsaBytes[:len(ecdsaBytes)-1]},
+		{"uncompressed R", modify(ecdsaBytes, uncompressed)},
+		{"R not on curve", modify(ecdsaBytes, offCurve)},
+		{"uncompressed R'", modify(ecdsaBytes, func(b []byte) { b[33] = 0x04 })},
+		{"s equal to the order", modify(ecdsaBytes, orderAt(66))},
+		{"proof e equal to the order", modify(ecdsaBytes, orderAt(98))},
+		{"proof s equal to the order", modify(ecdsaBytes, orderAt(130))},
+	} {
+		if _, err := ecc.ParseECDSAAdaptorSignature(test.buffer); err == nil {
+			t.Errorf("ECDSA %v: expected an error", test.name)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package ecc
+
+import (
+	"bitcoin-go/utility"
+	"math/big"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// Collects signatures and checks them all at once.
+//
+// Schnorr signatures are checked with a single randomized linear combination (BIP340 batch
+// verification): with random weights a_i (a_1 = 1) the batch is valid iff
+//
+//	(sum a_i*s_i) * G == sum a_i*R_i + sum (a_i*e_i)*P_i
+//
+// which costs one multi-scalar multiplication instead of one verification per signature.
+// ECDSA has no such trick, so those signatures are spread over a pool of workers.
+//
+// When a batch fails, the entries are checked one by one to find out which ones are invalid.
+type BatchVerifier struct {
+	Workers int // Number of goroutines used for ECDSA and fallback checks, defaults to the number of CPUs
+	entries []batchEntry
+}
+
+type batchEntry struct {
+	pub        Point
+	hash       *big.Int          // ECDSA only
+	ecdsaSig   Signature         // ECDSA only
+	msg        []byte            // Schnorr only
+	schnorrSig *SchnorrSignature // Set for Schnorr entries
+}
+
+func NewBatchVerifier() *BatchVerifier {
+	return &BatchVerifier{Workers: runtime.NumCPU()}
+}
+
+// Queues an ECDSA signature of the hash. Returns the index used to report failures.
+func (b *BatchVerifier) AddECDSA(pub *Point, hash *big.Int, sig Signature) int {
+	b.entries = append(b.entries, batchEntry{pub: *pub, hash: hash, ecdsaSig: sig})
+	return len(b.entries) - 1
+}
+
+// Queues a BIP340 Schnorr signature of the message. Returns the index used to report failures.
+func (b *BatchVerifier) AddSchnorr(pub *Point, msg []byte, sig SchnorrSignature) int {
+	b.entries = append(b.entries, batchEntry{pub: *pub, msg: msg, schnorrSig: &sig})
+	return len(b.entries) - 1
+}
+
+func (b *BatchVerifier) Len() int {
+	return len(b.entries)
+}
+
+// Checks every queued signature. Returns true if they are all valid, otherwise false and the
+// (sorted) indices of the entries that failed.
+func (b *BatchVerifier) Verify() (bool, []int) {
+
+	var ecdsaIndices, schnorrIndices []int
+	for i, entry := range b.entries {
+		if entry.schnorrSig != nil {
+			schnorrIndices = append(schnorrIndices, i)
+		} else {
+			ecdsaIndices = append(ecdsaIndices, i)
+		}
+	}
+
+	failed := b.verifyEach(ecdsaIndices)
+
+	if len(schnorrIndices) > 0 && !b.verifySchnorrBatch(schnorrIndices) {
+		failed = append(failed, b.verifyEach(schnorrIndices)...)
+	}
+
+	sort.Ints(failed)
+	return len(failed) == 0, failed
+}
+
+func (entry *batchEntry) verify() bool {
+	if entry.schnorrSig != nil {
+		return entry.pub.VerifySchnorr(entry.msg, *entry.schnorrSig)
+	}
+	return entry.pub.Verify(entry.hash, entry.ecdsaSig)
+}
+
+// Verifies the entries individually on the worker pool and returns the ones that failed.
+func (b *BatchVerifier) verifyEach(indices []int) []int {
+
+	workers := b.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan int)
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	failed := make([]int, 0)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if !b.entries[i].verify() {
+					mutex.Lock()
+					failed = append(failed, i)
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, i := range indices {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return failed
+}
+
+func (b *BatchVerifier) verifySchnorrBatch(indices []int) bool {
+
+	points := make([]Point, 0, 2*len(indices))
+	scalars := make([]*big.Int, 0, 2*len(indices))
+	sSum := new(big.Int)
+
+	for n, i := range indices {
+		entry := &b.entries[i]
+		sig := entry.schnorrSig
+
+		if entry.pub.x == nil || sig.R == nil || sig.S == nil || sig.S.Cmp(N) >= 0 {
+			return false
+		}
+
+		pub, err := liftX(entry.pub.x)
+		if err != nil {
+			return false
+		}
+
+		R, err := liftX(sig.R)
+		if err != nil {
+			return false
+		}
+
+		e := schnorrChallenge(R.ToXOnly(), pub.ToXOnly(), entry.msg)
+
+		// The first weight is 1, the others are random so a forger can't make errors cancel out.
+		a := big.NewInt(1)
+		if n > 0 {
+			a = randomScalar()
+		}
+
+		sSum.Add(sSum, new(big.Int).Mul(a, sig.S))
+
+		points = append(points, R, pub)
+		scalars = append(scalars, a, ModMulPrime(a, e, N))
+	}
+
+	rhs := multiScalarMultiply(points, scalars)
+	lhs := generatorMultiply(sSum.Mod(sSum, N))
+
+	// lhs - rhs must be the point at infinity.
+	negRhs := rhs.negate(Secp256k1)
+	total := lhs.add(&negRhs, Secp256k1)
+	return total.isInfinity()
+}
+
+func randomScalar() *big.Int {
+	for {
+		a := new(big.Int).SetBytes(utility.RandomData(32))
+		if a.Sign() > 0 && a.Cmp(N) < 0 {
+			return a
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package ecc_test
+
+import (
+	"bitcoin-go/ecc"
+	"bitcoin-go/utility"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func batchTestKey(i int) (ecc.PrivateKey, ecc.Point) {
+	key := ecc.NewPrivateKey(new(big.Int).SetBytes(utility.Hash256([]byte{byte(i)})))
+	return key, ecc.G.ScalarMultiply(key.Secret)
+}
+
+func fillBatch(t *testing.T, batch *ecc.BatchVerifier, count int, badIndices map[int]bool) {
+	for i := 0; i < count; i++ {
+		key, pub := batchTestKey(i)
+		msg := utility.Hash256([]byte{byte(i), 0x42})
+
+		if i%2 == 0 {
+			hash := new(big.Int).SetBytes(msg)
+			sig := key.Sign(hash)
+			if badIndices[i] {
+				hash = new(big.Int).Add(hash, big.NewInt(1))
+			}
+			batch.AddECDSA(&pub, hash, sig)
+		} else {
+			sig, err := key.SignSchnorr(msg, make([]byte, 32))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if badIndices[i] {
+				sig.S = new(big.Int).Add(sig.S, big.NewInt(1))
+			}
+			batch.AddSchnorr(&pub, msg, sig)
+		}
+	}
+}
+
+func TestBatchVerifierValid(t *testing.T) {
+
+	batch := ecc.NewBatchVerifier()
+	if ok, failed := batch.Verify(); !ok || len(failed) != 0 {
+		t.Errorf("Empty batch should verify")
+	}
+
+	fillBatch(t, batch, 16, nil)
+	if batch.Len() != 16 {
+		t.Errorf("Expected 16 entries, got %v", batch.Len())
+	}
+
+	if ok, failed := batch.Verify(); !ok {
+		t.Errorf("Valid batch failed at %v", failed)
+	}
+}
+
+func TestBatchVerifierReportsFailures(t *testing.T) {
+
+	testCases := [][]int{
+		{0},
+		{3},
+		{2, 5, 11},
+		{1, 3, 5, 7, 9, 11, 13, 15},
+	}
+
+	for _, bad := range testCases {
+		badIndices := make(map[int]bool)
+		for _, i := range bad {
+			badIndices[i] = true
+		}
+
+		batch := ecc.NewBatchVerifier()
+		batch.Workers = 3
+		fillBatch(t, batch, 16, badIndices)
+
+		ok, failed := batch.Verify()
+		if ok {
+			t.Errorf("Batch with bad entries %v verified", bad)
+		}
+		if !reflect.DeepEqual(failed, bad) {
+			t.Errorf("Expected failures %v, got %v", bad, failed)
+		}
+	}
+}
+
+func TestBatchVerifierSchnorrInvalidR(t *testing.T) {
+
+	key, pub := batchTestKey(1)
+	msg := utility.Hash256([]byte("msg"))
+	sig, _ := key.SignSchnorr(msg, make([]byte, 32))
+
+	batch := ecc.NewBatchVerifier()
+	batch.AddSchnorr(&pub, msg, sig)
+
+	// x = 5 isn't on the curve.
+	batch.AddSchnorr(&pub, msg, ecc.NewSchnorrSignature(big.NewInt(5), sig.S))
+
+	ok, failed := batch.Verify()
+	if ok || !reflect.DeepEqual(failed, []int{1}) {
+		t.Errorf("Expected failure at index 1, got %v", failed)
+	}
+}
+
+func benchmarkBatch(b *testing.B, schnorr bool) (*ecc.BatchVerifier, []func() bool) {
+	batch := ecc.NewBatchVerifier()
+	checks := make([]func() bool, 0)
+
+	for i := 0; i < 64; i++ {
+		key, pub := batchTestKey(i)
+		msg := utility.Hash256([]byte{byte(i)})
+
+		if schnorr {
+			sig, _ := key.SignSchnorr(msg, make([]byte, 32))
+			batch.AddSchnorr(&pub, msg, sig)
+			checks = append(checks, func() bool { return pub.VerifySchnorr(msg, sig) })
+		} else {
+			hash := new(big.Int).SetBytes(msg)
+			sig := key.Sign(hash)
+			batch.AddECDSA(&pub, hash, sig)
+			checks = append(checks, func() bool { return pub.Verify(hash, sig) })
+		}
+	}
+
+	return batch, checks
+}
+
+func BenchmarkSchnorrIndividual64(b *testing.B) {
+	_, checks := benchmarkBatch(b, true)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, check := range checks {
+			check()
+		}
+	}
+}
+
+func BenchmarkSchnorrBatch64(b *testing.B) {
+	batch, _ := benchmarkBatch(b, true)
+	batch.Workers = 1
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		batch.Verify()
+	}
+}
+
+func BenchmarkECDSAIndividual64(b *testing.B) {
+	_, checks := benchmarkBatch(b, false)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, check := range checks {
+			check()
+		}
+	}
+}
+
+func BenchmarkECDSABatch64(b *testing.B) {
+	batch, _ := benchmarkBatch(b, false)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		batch.Verify()
+	}
+}
@@ -0,0 +1,103 @@
+package ecc
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// A short Weierstrass curve y^2 = x^3 + a*x + b over the prime field F_p, along with a generator
+// point and its order. Secp256k1 is the curve Bitcoin uses and the default for everything in this
+// package; small curves (like the F_223 one from the book) are handy for experimenting, since the
+// numbers are small enough to follow by hand.
+//
+// Point arithmetic and ECDSA work on any curve. The optimized and constant time code (the
+// generator table, FieldElement, Scalar and the Montgomery ladder) as well as Schnorr, MuSig2,
+// ECDH and SEC parsing are secp256k1 only.
+type Curve struct {
+	Name string
+	P    *big.Int // The field prime
+	A    *big.Int
+	B    *big.Int
+	G    Point    // The generator
+	N    *big.Int // The order of the generator
+}
+
+var Secp256k1 *Curve
+
+// Creates a curve, making sure the generator is on it and has the given order. Square roots are
+// taken with the p = 3 mod 4 shortcut of ModSqrtPrime and ECDSA verification inverts mod n with
+// Fermat's little theorem, so p must be 3 mod 4 and n must be prime.
+func NewCurve(name string, p *big.Int, a *big.Int, b *big.Int, gx *big.Int, gy *big.Int, n *big.Int) (*Curve, error) {
+
+	if p.Cmp(BigThree) <= 0 || !p.ProbablyPrime(20) {
+		return nil, errors.New("field size must be a prime greater than 3")
+	}
+	if new(big.Int).Mod(p, big.NewInt(4)).Cmp(BigThree) != 0 {
+		return nil, errors.New("field size must be 3 mod 4")
+	}
+
+	curve := &Curve{Name: name, P: p, A: new(big.Int).Mod(a, p), B: new(big.Int).Mod(b, p), N: n}
+
+	// 4a^3 + 27b^2 == 0 means the curve is singular.
+	discriminant := ModAddPrime(ModMulPrime(big.NewInt(4), ModPowPrimeInt(curve.A, 3, p), p), ModMulPrime(big.NewInt(27), ModPowPrimeInt(curve.B, 2, p), p), p)
+	if discriminant.Sign() == 0 {
+		return nil, errors.New("curve is singular")
+	}
+
+	g, err := curve.NewPoint(gx, gy)
+	if err != nil {
+		return nil, err
+	}
+	curve.G = g
+
+	if !n.ProbablyPrime(20) {
+		return nil, errors.New("order must be a prime")
+	}
+	if nG := wnafMultiply(&g, n); !nG.isInfinity() {
+		return nil, fmt.Errorf("%v * G is not the point at infinity", n)
+	}
+
+	return curve, nil
+}
+
+// Creates the point (x, y), making sure it's on the curve. Nil coordinates give the point at infinity.
+func (c *Curve) NewPoint(x *big.Int, y *big.Int) (Point, error) {
+	if x == nil || y == nil {
+		return c.Infinity(), nil
+	}
+
+	if x.Sign() < 0 || x.Cmp(c.P) >= 0 || y.Sign() < 0 || y.Cmp(c.P) >= 0 {
+		return Point{}, errors.New("coordinate exceeds the field size")
+	}
+	if !c.IsOnCurve(x, y) {
+		return Point{}, fmt.Errorf("(%v, %v) is not on the curve", x, y)
+	}
+
+	return Point{x: x, y: y, curve: c}, nil
+}
+
+func (c *Curve) Infinity() Point {
+	return Point{x: nil, y: nil, curve: c}
+}
+
+func (c *Curve) IsOnCurve(x *big.Int, y *big.Int) bool {
+	y_squared := ModPowPrimeInt(y, 2, c.P)
+	sum := ModAddPrime(ModAddPrime(ModPowPrimeInt(x, 3, c.P), ModMulPrime(c.A, x, c.P), c.P), c.B, c.P)
+	return y_squared.Cmp(sum) == 0
+}
+
+// Two curves are the same if they have the same equation over the same field.
+func (c *Curve) Equals(c2 *Curve) bool {
+	if c == c2 {
+		return true
+	}
+	if c == nil || c2 == nil {
+		return false
+	}
+	return c.P.Cmp(c2.P) == 0 && c.A.Cmp(c2.A) == 0 && c.B.Cmp(c2.B) == 0
+}
+
+func (c *Curve) String() string {
+	return fmt.Sprintf("%v: y^2 = x^3 + %v*x + %v over F_%v", c.Name, c.A, c.B, c.P)
+}
@@ -0,0 +1,205 @@
+package ecc_test
+
+import (
+	"bitcoin-go/ecc"
+	"bitcoin-go/utility"
+	"math/big"
+	"testing"
+)
+
+// y^2 = x^3 + 7 over F_223, the curve used in the book's exercises. (15, 86) generates a group
+// of order 7 and (0, 26) one of order 3. (47, 71) has order 21, so it can't be a generator.
+func newF223Curve(t *testing.T, gx int64, gy int64, n int64) *ecc.Curve {
+	curve, err := ecc.NewCurve("F223", big.NewInt(223), big.NewInt(0), big.NewInt(7), big.NewInt(gx), big.NewInt(gy), big.NewInt(n))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return curve
+}
+
+func newF223Point(t *testing.T, curve *ecc.Curve, x int64, y int64) ecc.Point {
+	point, err := curve.NewPoint(big.NewInt(x), big.NewInt(y))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return point
+}
+
+func TestNewCurve(t *testing.T) {
+
+	curve := newF223Curve(t, 15, 86, 7)
+	if curve.Equals(ecc.Secp256k1) {
+		t.Error("F223 curve equals secp256k1")
+	}
+	if !curve.Equals(newF223Curve(t, 0, 26, 3)) {
+		t.Error("Curves with the same equation should be equal")
+	}
+
+	testCases := []struct {
+		p, a, b, gx, gy, n int64
+	}{
+		{221, 0, 7, 47, 71, 21}, // Not a prime
+		{223, 0, 0, 47, 71, 21}, // Singular
+		{223, 0, 7, 47, 72, 21}, // Generator not on the curve
+		{223, 0, 7, 47, 71, 7},  // Wrong order
+		{223, 0, 7, 47, 71, 21}, // Order isn't a prime
+		{13, 0, 7, 7, 5, 7},     // Field size is 1 mod 4
+	}
+
+	for _, testCase := range testCases {
+		_, err := ecc.NewCurve("bad", big.NewInt(testCase.p), big.NewInt(testCase.a), big.NewInt(testCase.b), big.NewInt(testCase.gx), big.NewInt(testCase.gy), big.NewInt(testCase.n))
+		if err == nil {
+			t.Errorf("Expected an error for %v", testCase)
+		}
+	}
+}
+
+func TestCurveOnCurve(t *testing.T) {
+	curve := newF223Curve(t, 15, 86, 7)
+
+	valid := [][2]int64{{192, 105}, {17, 56}, {1, 193}}
+	invalid := [][2]int64{{200, 119}, {42, 99}}
+
+	for _, c := range valid {
+		if _, err := curve.NewPoint(big.NewInt(c[0]), big.NewInt(c[1])); err != nil {
+			t.Errorf("(%v, %v) should be on the curve: %v", c[0], c[1], err)
+		}
+	}
+	for _, c := range invalid {
+		if _, err := curve.NewPoint(big.NewInt(c[0]), big.NewInt(c[1])); err == nil {
+			t.Errorf("(%v, %v) shouldn't be on the curve", c[0], c[1])
+		}
+	}
+}
+
+func TestCurveAdd(t *testing.T) {
+	curve := newF223Curve(t, 15, 86, 7)
+
+	testCases := [][6]int64{
+		{192, 105, 17, 56, 170, 142},
+		{47, 71, 117, 141, 60, 139},
+		{143, 98, 76, 66, 47, 71},
+	}
+
+	for _, c := range testCases {
+		p1 := newF223Point(t, curve, c[0], c[1])
+		p2 := newF223Point(t, curve, c[2], c[3])
+		expected := newF223Point(t, curve, c[4], c[5])
+
+		if sum := p1.Add(&p2); !sum.Equals(&expected) {
+			t.Errorf("(%v, %v) + (%v, %v) = (%v, %v), expected (%v, %v)", c[0], c[1], c[2], c[3], sum.X(), sum.Y(), c[4], c[5])
+		}
+	}
+
+	// Points on different curves can't be added.
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic adding points on different curves")
+		}
+	}()
+	p := newF223Point(t, curve, 47, 71)
+	p.Add(&ecc.G)
+}
+
+func TestCurveScalarMultiply(t *testing.T) {
+	curve := newF223Curve(t, 15, 86, 7)
+
+	testCases := []struct {
+		coefficient int64
+		x, y        int64
+		point       [2]int64
+	}{
+		{2, 49, 71, [2]int64{192, 105}},
+		{2, 64, 168, [2]int64{143, 98}},
+		{2, 36, 111, [2]int64{47, 71}},
+		{4, 194, 51, [2]int64{47, 71}},
+		{8, 116, 55, [2]int64{47, 71}},
+	}
+
+	for _, testCase := range testCases {
+		p := newF223Point(t, curve, testCase.point[0], testCase.point[1])
+		expected := newF223Point(t, curve, testCase.x, testCase.y)
+
+		if result := p.ScalarMultiply(big.NewInt(testCase.coefficient)); !result.Equals(&expected) {
+			t.Errorf("%v * %v = (%v, %v), expected (%v, %v)", testCase.coefficient, testCase.point, result.X(), result.Y(), testCase.x, testCase.y)
+		}
+	}
+
+	// The generator's order.
+	if result := curve.G.ScalarMultiply(big.NewInt(7)); !result.IsInfinity() {
+		t.Errorf("7 * G should be the point at infinity, got (%v, %v)", result.X(), result.Y())
+	}
+
+	// (47, 71) isn't in the group generated by G and has order 21, reducing mod 7 would break this.
+	other := newF223Point(t, curve, 47, 71)
+	if result := other.ScalarMultiply(big.NewInt(7)); result.IsInfinity() {
+		t.Error("7 * (47, 71) shouldn't be the point at infinity")
+	}
+	if result := other.ScalarMultiply(big.NewInt(21)); !result.IsInfinity() {
+		t.Errorf("21 * (47, 71) should be the point at infinity, got (%v, %v)", result.X(), result.Y())
+	}
+	result := other.ScalarMultiply(big.NewInt(22))
+	if expected := other.ScalarMultiply(big.NewInt(1)); !result.Equals(&expected) {
+		t.Errorf("22 * (47, 71) should be (47, 71), got (%v, %v)", result.X(), result.Y())
+	}
+}
+
+func TestCurveSignVerify(t *testing.T) {
+
+	curve := newF223Curve(t, 15, 86, 7)
+
+	for secret := int64(1); secret < 7; secret++ {
+		key, err := ecc.NewPrivateKeyOnCurve(big.NewInt(secret), curve)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub := key.PublicKey()
+
+		if !pub.Curve().Equals(curve) {
+			t.Fatal("Public key is on the wrong curve")
+		}
+
+		for i := byte(0); i < 10; i++ {
+			hash := new(big.Int).SetBytes(utility.Hash256([]byte{i}))
+			sig := key.Sign(hash)
+
+			if sig.R.Cmp(big.NewInt(7)) >= 0 || sig.S.Cmp(big.NewInt(7)) >= 0 {
+				t.Fatalf("Signature values out of range: %v", sig)
+			}
+			if !pub.Verify(hash, sig) {
+				t.Errorf("Signature by %v of %x failed to verify", secret, hash)
+			}
+		}
+	}
+
+	// Curves that didn't come from NewCurve are checked too.
+	composite := *curve
+	composite.G = newF223Point(t, curve, 47, 71)
+	composite.N = big.NewInt(21)
+	if _, err := ecc.NewPrivateKeyOnCurve(big.NewInt(3), &composite); err == nil {
+		t.Error("Expected an error creating a key on a generator with a composite order")
+	}
+}
+
+func TestSecp256k1Curve(t *testing.T) {
+	if !ecc.Secp256k1.G.Equals(&ecc.G) || ecc.Secp256k1.N.Cmp(ecc.N) != 0 || ecc.Secp256k1.P.Cmp(ecc.P) != 0 {
+		t.Error("Secp256k1 doesn't match the package globals")
+	}
+
+	if curve := ecc.G.Curve(); curve != ecc.Secp256k1 {
+		t.Error("G should be on secp256k1")
+	}
+
+	// Points without an explicit curve are on secp256k1.
+	var zero ecc.Point
+	if zero.Curve() != ecc.Secp256k1 {
+		t.Error("The zero point should default to secp256k1")
+	}
+
+	key := ecc.NewPrivateKey(big.NewInt(12345))
+	pub := key.PublicKey()
+	expected := ecc.G.ScalarMultiply(big.NewInt(12345))
+	if !pub.Equals(&expected) {
+		t.Error("PublicKey doesn't match secret * G")
+	}
+}
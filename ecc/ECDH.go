@@ -0,0 +1,60 @@
+package ecc
+
+import (
+	"bitcoin-go/utility"
+	"errors"
+)
+
+// Turns the shared point (as 32 byte big-endian x and y coordinates) into the shared secret.
+type ECDHHashFunction func(x []byte, y []byte) []byte
+
+// The libsecp256k1 default: SHA256 of the compressed SEC encoding of the shared point.
+func ECDHHashSHA256(x []byte, y []byte) []byte {
+	buffer := make([]byte, 33)
+	buffer[0] = 0x02 | y[31]&1
+	copy(buffer[1:], x)
+	return utility.Sha256(buffer)
+}
+
+// No hashing, just the x coordinate of the shared point. Only use this when the caller
+// feeds the result into its own KDF.
+func ECDHHashXOnly(x []byte, y []byte) []byte {
+	return append([]byte{}, x...)
+}
+
+// Derives the secret shared with the owner of pub. If hash is nil, ECDHHashSHA256 is used.
+func (key *PrivateKey) ECDH(pub *Point, hash ECDHHashFunction) ([]byte, error) {
+
+	if key.Secret == nil || key.Secret.Sign() <= 0 || key.Secret.Cmp(N) >= 0 {
+		return nil, errors.New("secret must be in the range [1, N-1]")
+	}
+
+	if pub == nil || pub.x == nil {
+		return nil, errors.New("public key is the point at infinity")
+	}
+
+	if !Secp256k1.Equals(pub.Curve()) {
+		return nil, errors.New("public key is not on secp256k1")
+	}
+
+	shared := pub.ScalarMultiplyConstantTime(NewScalar(key.Secret))
+	if shared.x == nil {
+		return nil, errors.New("shared point is the point at infinity")
+	}
+
+	if hash == nil {
+		hash = ECDHHashSHA256
+	}
+
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	fillBufferWithIntBytes(x, shared.x, false)
+	fillBufferWithIntBytes(y, shared.y, false)
+
+	return hash(x, y), nil
+}
+
+// Same as key.ECDH(p, hash), for callers that start from the other party's point.
+func (p *Point) ECDH(key *PrivateKey, hash ECDHHashFunction) ([]byte, error) {
+	return key.ECDH(p, hash)
+}
@@ -0,0 +1,71 @@
+package ecc_test
+
+import (
+	"bitcoin-go/ecc"
+	"bitcoin-go/utility"
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestECDH(t *testing.T) {
+
+	alice := ecc.NewPrivateKey(new(big.Int).SetBytes(utility.RandomData(32)))
+	bob := ecc.NewPrivateKey(new(big.Int).SetBytes(utility.RandomData(32)))
+	alicePub := ecc.G.ScalarMultiply(alice.Secret)
+	bobPub := ecc.G.ScalarMultiply(bob.Secret)
+
+	aliceSecret, err := alice.ECDH(&bobPub, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bobSecret, err := alicePub.ECDH(&bob, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(aliceSecret, bobSecret) {
+		t.Errorf("Shared secrets differ")
+	}
+
+	// The default hash is SHA256 of the compressed shared point.
+	shared := bobPub.ScalarMultiply(alice.Secret)
+	if !bytes.Equal(aliceSecret, utility.Sha256(shared.ToSEC(true))) {
+		t.Errorf("Unexpected default hash")
+	}
+
+	raw, _ := alice.ECDH(&bobPub, ecc.ECDHHashXOnly)
+	if !bytes.Equal(raw, shared.ToSEC(true)[1:]) {
+		t.Errorf("Unexpected x-only secret")
+	}
+
+	custom := func(x []byte, y []byte) []byte {
+		return utility.Hash256(append(append([]byte{}, x...), y...))
+	}
+	customSecret, _ := alice.ECDH(&bobPub, custom)
+	if !bytes.Equal(customSecret, utility.Hash256(shared.ToSEC(false)[1:])) {
+		t.Errorf("Custom hash function wasn't used")
+	}
+}
+
+func TestECDHInvalid(t *testing.T) {
+
+	key := ecc.NewPrivateKey(big.NewInt(1234))
+	infinity := ecc.G.ScalarMultiply(ecc.N)
+
+	if _, err := key.ECDH(&infinity, nil); err == nil {
+		t.Errorf("Point at infinity should be rejected")
+	}
+
+	if _, err := key.ECDH(nil, nil); err == nil {
+		t.Errorf("Nil point should be rejected")
+	}
+
+	for _, secret := range []*big.Int{big.NewInt(0), ecc.N} {
+		bad := ecc.NewPrivateKey(secret)
+		if _, err := bad.ECDH(&ecc.G, nil); err == nil {
+			t.Errorf("Secret %x should be rejected", secret)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package ecc
+
+import (
+	"math/big"
+)
+
+// An element of the secp256k1 base field (integers mod P), stored as four 64 bit limbs in
+// Montgomery form. Unlike the big.Int helpers in bigIntModMath.go, every operation runs in
+// constant time, so these are safe to use with secret values.
+type FieldElement struct {
+	n limbs
+}
+
+var fieldModulus *montgomeryModulus
+
+func NewFieldElement(x *big.Int) FieldElement {
+	reduced := new(big.Int).Mod(x, P)
+	return FieldElement{n: fieldModulus.toMontgomery(bigIntToLimbs(reduced))}
+}
+
+// Interprets 32 big-endian bytes as a field element (reducing mod P if needed).
+func NewFieldElementFromBytes(b []byte) FieldElement {
+	return FieldElement{n: fieldModulus.toMontgomery(bytesToLimbs(b))}
+}
+
+func (f FieldElement) Add(g FieldElement) FieldElement {
+	return FieldElement{n: fieldModulus.add(f.n, g.n)}
+}
+
+func (f FieldElement) Sub(g FieldElement) FieldElement {
+	return FieldElement{n: fieldModulus.sub(f.n, g.n)}
+}
+
+func (f FieldElement) Mul(g FieldElement) FieldElement {
+	return FieldElement{n: fieldModulus.mul(f.n, g.n)}
+}
+
+func (f FieldElement) Square() FieldElement {
+	return FieldElement{n: fieldModulus.mul(f.n, f.n)}
+}
+
+func (f FieldElement) Negate() FieldElement {
+	return FieldElement{n: fieldModulus.sub(limbs{}, f.n)}
+}
+
+// The multiplicative inverse. The inverse of zero is zero.
+func (f FieldElement) Inverse() FieldElement {
+	return FieldElement{n: fieldModulus.inverse(f.n)}
+}
+
+func (f FieldElement) Equals(g FieldElement) bool {
+	return limbsEqual(f.n, g.n)
+}
+
+func (f FieldElement) IsZero() bool {
+	return limbsIsZero(f.n)
+}
+
+func (f FieldElement) Bytes() []byte {
+	return limbsToBytes(fieldModulus.fromMontgomery(f.n))
+}
+
+func (f FieldElement) BigInt() *big.Int {
+	return new(big.Int).SetBytes(f.Bytes())
+}
+
+// Returns g when bit == 1 and f when bit == 0, without branching.
+func (f FieldElement) selectIf(bit uint64, g FieldElement) FieldElement {
+	return FieldElement{n: selectLimbs(bit, f.n, g.n)}
+}
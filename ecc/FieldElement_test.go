@@ -0,0 +1,136 @@
+package ecc_test
+
+import (
+	"bitcoin-go/ecc"
+	"bitcoin-go/utility"
+	"math/big"
+	"testing"
+)
+
+func TestFieldElementArithmetic(t *testing.T) {
+
+	values := []*big.Int{
+		big.NewInt(0),
+		big.NewInt(1),
+		big.NewInt(7),
+		new(big.Int).Sub(ecc.P, big.NewInt(1)),
+		new(big.Int).Add(ecc.P, big.NewInt(5)), // Not reduced
+		ecc.Gx,
+		ecc.Gy,
+	}
+	for i := 0; i < 10; i++ {
+		values = append(values, new(big.Int).SetBytes(utility.RandomData(32)))
+	}
+
+	for _, a := range values {
+		for _, b := range values {
+			fa := ecc.NewFieldElement(a)
+			fb := ecc.NewFieldElement(b)
+
+			if res := fa.Add(fb); res.BigInt().Cmp(ecc.ModAdd(a, b)) != 0 {
+				t.Errorf("Add mismatch for %x + %x", a, b)
+			}
+			if res := fa.Sub(fb); res.BigInt().Cmp(ecc.ModSub(a, b)) != 0 {
+				t.Errorf("Sub mismatch for %x - %x", a, b)
+			}
+			if res := fa.Mul(fb); res.BigInt().Cmp(ecc.ModMul(a, b)) != 0 {
+				t.Errorf("Mul mismatch for %x * %x", a, b)
+			}
+		}
+
+		fa := ecc.NewFieldElement(a)
+		if fa.Square().BigInt().Cmp(ecc.ModMul(a, a)) != 0 {
+			t.Errorf("Square mismatch for %x", a)
+		}
+
+		if !fa.Add(fa.Negate()).IsZero() {
+			t.Errorf("Negate mismatch for %x", a)
+		}
+
+		if !fa.IsZero() {
+			one := ecc.NewFieldElement(big.NewInt(1))
+			if !fa.Mul(fa.Inverse()).Equals(one) {
+				t.Errorf("Inverse mismatch for %x", a)
+			}
+		}
+
+		fromBytes := ecc.NewFieldElementFromBytes(fa.Bytes())
+		if !fromBytes.Equals(fa) {
+			t.Errorf("Bytes round trip failed for %x", a)
+		}
+	}
+}
+
+func TestScalarArithmetic(t *testing.T) {
+
+	values := []*big.Int{
+		big.NewInt(0),
+		big.NewInt(1),
+		new(big.Int).Sub(ecc.N, big.NewInt(1)),
+		new(big.Int).Add(ecc.N, big.NewInt(3)),
+	}
+	for i := 0; i < 10; i++ {
+		values = append(values, new(big.Int).SetBytes(utility.RandomData(32)))
+	}
+
+	for _, a := range values {
+		for _, b := range values {
+			sa := ecc.NewScalar(a)
+			sb := ecc.NewScalar(b)
+
+			sum := new(big.Int).Add(a, b)
+			if sa.Add(sb).BigInt().Cmp(sum.Mod(sum, ecc.N)) != 0 {
+				t.Errorf("Add mismatch for %x + %x", a, b)
+			}
+
+			diff := new(big.Int).Sub(a, b)
+			if sa.Sub(sb).BigInt().Cmp(diff.Mod(diff, ecc.N)) != 0 {
+				t.Errorf("Sub mismatch for %x - %x", a, b)
+			}
+
+			if sa.Mul(sb).BigInt().Cmp(ecc.ModMulPrime(a, b, ecc.N)) != 0 {
+				t.Errorf("Mul mismatch for %x * %x", a, b)
+			}
+		}
+
+		sa := ecc.NewScalar(a)
+		if !sa.IsZero() {
+			inverse := new(big.Int).ModInverse(a, ecc.N)
+			if sa.Inverse().BigInt().Cmp(inverse) != 0 {
+				t.Errorf("Inverse mismatch for %x", a)
+			}
+		}
+
+		if !ecc.NewScalarFromBytes(sa.Bytes()).Equals(sa) {
+			t.Errorf("Bytes round trip failed for %x", a)
+		}
+	}
+}
+
+func TestScalarMultiplyConstantTime(t *testing.T) {
+
+	other := ecc.G.ScalarMultiply(big.NewInt(0xc0ffee))
+	scalars := []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3), new(big.Int).Sub(ecc.N, big.NewInt(1))}
+	for i := 0; i < 10; i++ {
+		scalars = append(scalars, new(big.Int).SetBytes(utility.RandomData(32)))
+	}
+
+	for _, k := range scalars {
+		for _, p := range []ecc.Point{ecc.G, other} {
+			expected := p.ScalarMultiply(k)
+			actual := p.ScalarMultiplyConstantTime(ecc.NewScalar(k))
+			if !expected.Equals(&actual) {
+				t.Errorf("Mismatch for %x", k)
+			}
+		}
+	}
+
+	// k = 0 and k = N both land on the point at infinity.
+	for _, k := range []*big.Int{big.NewInt(0), ecc.N} {
+		inf := ecc.G.ScalarMultiplyConstantTime(ecc.NewScalar(k))
+		sum := inf.Add(&ecc.G)
+		if inf.Equals(&ecc.G) || !sum.Equals(&ecc.G) {
+			t.Error()
+		}
+	}
+}
@@ -0,0 +1,479 @@
+package ecc
+
+import (
+	"bitcoin-go/utility"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// MuSig2 multi-signatures (BIP327). n signers aggregate their keys into a single x-only key and
+// jointly produce an ordinary BIP340 Schnorr signature for it in two rounds:
+//
+//  1. Every signer runs MuSigNonceGen and shares the public nonce. The public nonces are combined
+//     with MuSigNonceAgg.
+//  2. Every signer creates a partial signature with MuSigSign, and anyone can combine the partial
+//     signatures with MuSigSession.AggregatePartialSigs.
+//
+// Nonces, partial signatures and aggregate nonces are passed around in their BIP327 byte encodings.
+
+const (
+	MuSigPubNonceSize = 66
+	MuSigSecNonceSize = 97
+)
+
+// Identifies the signer that handed us a bad public key, nonce or partial signature.
+type MuSigInvalidContributionError struct {
+	Signer       int
+	Contribution string
+}
+
+func (e *MuSigInvalidContributionError) Error() string {
+	return fmt.Sprintf("invalid %v from signer %v", e.Contribution, e.Signer)
+}
+
+// The result of key aggregation, plus the accumulated tweaks.
+type MuSigKeyAggContext struct {
+	q    Point
+	gacc *big.Int // Accumulated sign flips from x-only tweaks (1 or N-1)
+	tacc *big.Int // Accumulated tweak
+}
+
+// The aggregated public key, including any tweaks applied.
+func (ctx *MuSigKeyAggContext) AggregatedKey() Point {
+	return ctx.q
+}
+
+// Sorts the keys by their compressed SEC encoding, so every signer ends up with the same key order.
+func MuSigKeySort(pubkeys []Point) []Point {
+	sorted := make([]Point, len(pubkeys))
+	copy(sorted, pubkeys)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].ToSEC(true), sorted[j].ToSEC(true)) < 0
+	})
+	return sorted
+}
+
+// Aggregates the public keys: Q = sum(a_i * P_i), with a_i = hash(L || P_i) for every key except the
+// second distinct one, which gets a_i = 1.
+func MuSigKeyAgg(pubkeys []Point) (MuSigKeyAggContext, error) {
+
+	if len(pubkeys) == 0 {
+		return MuSigKeyAggContext{}, errors.New("at least one public key is required")
+	}
+
+	for i := range pubkeys {
+		if pubkeys[i].x == nil {
+			return MuSigKeyAggContext{}, &MuSigInvalidContributionError{Signer: i, Contribution: "pubkey"}
+		}
+	}
+
+	points := make([]Point, len(pubkeys))
+	scalars := make([]*big.Int, len(pubkeys))
+	for i := range pubkeys {
+		points[i] = pubkeys[i]
+		scalars[i] = muSigKeyAggCoeff(pubkeys, &pubkeys[i])
+	}
+
+	q := multiScalarMultiply(points, scalars)
+	if q.isInfinity() {
+		return MuSigKeyAggContext{}, errors.New("aggregated key is the point at infinity")
+	}
+
+	return MuSigKeyAggContext{q: q.toAffine(Secp256k1), gacc: big.NewInt(1), tacc: big.NewInt(0)}, nil
+}
+
+func muSigHashKeys(pubkeys []Point) []byte {
+	buffer := make([]byte, 0, 33*len(pubkeys))
+	for i := range pubkeys {
+		buffer = append(buffer, pubkeys[i].ToSEC(true)...)
+	}
+	return utility.TaggedHash("KeyAgg list", buffer)
+}
+
+func muSigSecondKey(pubkeys []Point) []byte {
+	first := pubkeys[0].ToSEC(true)
+	for i := 1; i < len(pubkeys); i++ {
+		if sec := pubkeys[i].ToSEC(true); !bytes.Equal(sec, first) {
+			return sec
+		}
+	}
+	return make([]byte, 33)
+}
+
+func muSigKeyAggCoeff(pubkeys []Point, pubkey *Point) *big.Int {
+	sec := pubkey.ToSEC(true)
+	if bytes.Equal(sec, muSigSecondKey(pubkeys)) {
+		return big.NewInt(1)
+	}
+
+	coeff := new(big.Int).SetBytes(utility.TaggedHash("KeyAgg coefficient", muSigHashKeys(pubkeys), sec))
+	return coeff.Mod(coeff, N)
+}
+
+// Tweaks the aggregated key. X-only tweaks (as used by Taproot) apply to the key with an even y,
+// plain tweaks apply to the key as is.
+func (ctx *MuSigKeyAggContext) ApplyTweak(tweak []byte, xOnly bool) error {
+
+	if len(tweak) != 32 {
+		return errors.New("tweak must be 32 bytes")
+	}
+
+	t := new(big.Int).SetBytes(tweak)
+	if t.Cmp(N) >= 0 {
+		return errors.New("tweak exceeds the curve order")
+	}
+
+	g := big.NewInt(1)
+	if xOnly && !ctx.q.hasEvenY() {
+		g = new(big.Int).Sub(N, BigOne)
+	}
+
+	gQ := ctx.q.ScalarMultiply(g)
+	tG := G.ScalarMultiply(t)
+	q := gQ.Add(&tG)
+	if q.x == nil {
+		return errors.New("tweaked key is the point at infinity")
+	}
+
+	ctx.q = q
+	ctx.gacc = ModMulPrime(g, ctx.gacc, N)
+	ctx.tacc = new(big.Int).Mod(new(big.Int).Add(t, new(big.Int).Mul(g, ctx.tacc)), N)
+	return nil
+}
+
+// Generates a signer's nonce pair. secret, aggregatedKey (32 byte x-only), msg and extraIn are
+// optional (nil) but make the nonce more robust against bad randomness. If rand is nil, 32 fresh
+// random bytes are used. Returns the secret nonce, which must only ever be used once, and the
+// public nonce to share with the other signers.
+func MuSigNonceGen(secret *big.Int, pubkey *Point, aggregatedKey []byte, msg []byte, extraIn []byte, rand []byte) ([]byte, []byte, error) {
+
+	if rand == nil {
+		rand = utility.RandomData(32)
+	} else if len(rand) != 32 {
+		return nil, nil, errors.New("rand must be 32 bytes")
+	}
+
+	if secret != nil {
+		secretBytes := make([]byte, 32)
+		secret.FillBytes(secretBytes)
+		aux := utility.TaggedHash("MuSig/aux", rand)
+		for i := range aux {
+			aux[i] ^= secretBytes[i]
+		}
+		rand = aux
+	}
+
+	pk := pubkey.ToSEC(true)
+
+	var msgPrefixed []byte
+	if msg == nil {
+		msgPrefixed = []byte{0x00}
+	} else {
+		msgPrefixed = make([]byte, 9, 9+len(msg))
+		msgPrefixed[0] = 0x01
+		binary.BigEndian.PutUint64(msgPrefixed[1:], uint64(len(msg)))
+		msgPrefixed = append(msgPrefixed, msg...)
+	}
+
+	extraLength := make([]byte, 4)
+	binary.BigEndian.PutUint32(extraLength, uint32(len(extraIn)))
+
+	secnonce := make([]byte, 0, MuSigSecNonceSize)
+	pubnonce := make([]byte, 0, MuSigPubNonceSize)
+
+	for i := byte(0); i < 2; i++ {
+		hash := utility.TaggedHash("MuSig/nonce", rand, []byte{byte(len(pk))}, pk,
+			[]byte{byte(len(aggregatedKey))}, aggregatedKey, msgPrefixed, extraLength, extraIn, []byte{i})
+
+		k := NewScalarFromBytes(hash)
+		if k.IsZero() {
+			return nil, nil, errors.New("derived nonce is zero")
+		}
+
+		R := G.ScalarMultiplyConstantTime(k)
+		secnonce = append(secnonce, k.Bytes()...)
+		pubnonce = append(pubnonce, R.ToSEC(true)...)
+	}
+
+	secnonce = append(secnonce, pk...)
+	return secnonce, pubnonce, nil
+}
+
+// Combines the signers' public nonces into the aggregate nonce.
+func MuSigNonceAgg(pubnonces [][]byte) ([]byte, error) {
+
+	aggnonce := make([]byte, 0, MuSigPubNonceSize)
+
+	for j := 0; j < 2; j++ {
+		R := Secp256k1.Infinity()
+		for i, pubnonce := range pubnonces {
+			if len(pubnonce) != MuSigPubNonceSize {
+				return nil, &MuSigInvalidContributionError{Signer: i, Contribution: "pubnonce"}
+			}
+
+			Ri, err := parseCompressedPoint(pubnonce[33*j : 33*(j+1)])
+			if err != nil {
+				return nil, &MuSigInvalidContributionError{Signer: i, Contribution: "pubnonce"}
+			}
+			R = R.Add(&Ri)
+		}
+		aggnonce = append(aggnonce, serializeCompressedExt(&R)...)
+	}
+
+	return aggnonce, nil
+}
+
+// Everything the signers have to agree on before creating partial signatures.
+type MuSigSession struct {
+	AggNonce []byte
+	PubKeys  []Point
+	Tweaks   [][]byte
+	IsXOnly  []bool
+	Msg      []byte
+}
+
+type muSigSessionValues struct {
+	keyAgg MuSigKeyAggContext
+	b      *big.Int
+	R      Point
+	e      *big.Int
+}
+
+func (session *MuSigSession) values() (muSigSessionValues, error) {
+
+	if len(session.Tweaks) != len(session.IsXOnly) {
+		return muSigSessionValues{}, errors.New("every tweak needs an x-only flag")
+	}
+
+	if len(session.AggNonce) != MuSigPubNonceSize {
+		return muSigSessionValues{}, errors.New("aggregate nonce must be 66 bytes")
+	}
+
+	keyAgg, err := MuSigKeyAgg(session.PubKeys)
+	if err != nil {
+		return muSigSessionValues{}, err
+	}
+
+	for i := range session.Tweaks {
+		if err := keyAgg.ApplyTweak(session.Tweaks[i], session.IsXOnly[i]); err != nil {
+			return muSigSessionValues{}, err
+		}
+	}
+
+	qBytes := keyAgg.q.ToXOnly()
+	b := new(big.Int).SetBytes(utility.TaggedHash("MuSig/noncecoef", session.AggNonce, qBytes, session.Msg))
+	b.Mod(b, N)
+
+	R1, err := parseCompressedPointExt(session.AggNonce[:33])
+	if err != nil {
+		return muSigSessionValues{}, errors.New("invalid aggregate nonce")
+	}
+	R2, err := parseCompressedPointExt(session.AggNonce[33:])
+	if err != nil {
+		return muSigSessionValues{}, errors.New("invalid aggregate nonce")
+	}
+
+	bR2 := R2.ScalarMultiply(b)
+	R := R1.Add(&bR2)
+	if R.x == nil {
+		// Only happens if the signers are colluding, BIP327 says to use G.
+		R = G
+	}
+
+	e := schnorrChallenge(R.ToXOnly(), qBytes, session.Msg)
+
+	return muSigSessionValues{keyAgg: keyAgg, b: b, R: R, e: e}, nil
+}
+
+// The aggregated (and tweaked) key the final signature will be valid for.
+func (session *MuSigSession) AggregatedKey() (Point, error) {
+	values, err := session.values()
+	if err != nil {
+		return Point{}, err
+	}
+	return values.keyAgg.q, nil
+}
+
+func (session *MuSigSession) keyAggCoeff(pubkey *Point) (*big.Int, error) {
+	for i := range session.PubKeys {
+		if session.PubKeys[i].Equals(pubkey) {
+			return muSigKeyAggCoeff(session.PubKeys, pubkey), nil
+		}
+	}
+	return nil, errors.New("public key is not part of the session")
+}
+
+// Creates this signer's partial signature. The secret nonce is wiped so it can't be used twice.
+func (key *PrivateKey) MuSigSign(secnonce []byte, session *MuSigSession) ([]byte, error) {
+
+	if len(secnonce) != MuSigSecNonceSize {
+		return nil, errors.New("secret nonce must be 97 bytes")
+	}
+
+	k1 := new(big.Int).SetBytes(secnonce[:32])
+	k2 := new(big.Int).SetBytes(secnonce[32:64])
+	pk := append([]byte{}, secnonce[64:]...)
+
+	// Never let the same nonce sign twice.
+	for i := 0; i < 64; i++ {
+		secnonce[i] = 0
+	}
+
+	if k1.Sign() == 0 || k1.Cmp(N) >= 0 || k2.Sign() == 0 || k2.Cmp(N) >= 0 {
+		return nil, errors.New("secret nonce is invalid or has already been used")
+	}
+
+	if key.Secret.Sign() <= 0 || key.Secret.Cmp(N) >= 0 {
+		return nil, errors.New("secret must be in the range [1, N-1]")
+	}
+
+	values, err := session.values()
+	if err != nil {
+		return nil, err
+	}
+
+	d := NewScalar(key.Secret)
+	P := G.ScalarMultiplyConstantTime(d)
+	if !bytes.Equal(P.ToSEC(true), pk) {
+		return nil, errors.New("secret nonce was generated for a different key")
+	}
+
+	a, err := session.keyAggCoeff(&P)
+	if err != nil {
+		return nil, err
+	}
+
+	// d = g * gacc * d'
+	if !values.keyAgg.q.hasEvenY() {
+		d = d.Negate()
+	}
+	d = d.Mul(NewScalar(values.keyAgg.gacc))
+
+	k1s := NewScalar(k1)
+	k2s := NewScalar(k2)
+	if !values.R.hasEvenY() {
+		k1s = k1s.Negate()
+		k2s = k2s.Negate()
+	}
+
+	// s = k1 + b*k2 + e*a*d
+	s := k1s.Add(NewScalar(values.b).Mul(k2s)).Add(NewScalar(values.e).Mul(NewScalar(a)).Mul(d))
+	psig := s.Bytes()
+
+	R1 := G.ScalarMultiplyConstantTime(NewScalar(k1))
+	R2 := G.ScalarMultiplyConstantTime(NewScalar(k2))
+	pubnonce := append(R1.ToSEC(true), R2.ToSEC(true)...)
+
+	if !session.VerifyPartialSig(psig, pubnonce, &P) {
+		return nil, errors.New("created partial signature does not verify")
+	}
+
+	return psig, nil
+}
+
+// Checks a partial signature against the signer's public nonce and public key.
+func (session *MuSigSession) VerifyPartialSig(psig []byte, pubnonce []byte, pubkey *Point) bool {
+
+	if len(psig) != 32 || len(pubnonce) != MuSigPubNonceSize || pubkey == nil || pubkey.x == nil {
+		return false
+	}
+
+	s := new(big.Int).SetBytes(psig)
+	if s.Cmp(N) >= 0 {
+		return false
+	}
+
+	values, err := session.values()
+	if err != nil {
+		return false
+	}
+
+	R1, err := parseCompressedPoint(pubnonce[:33])
+	if err != nil {
+		return false
+	}
+	R2, err := parseCompressedPoint(pubnonce[33:])
+	if err != nil {
+		return false
+	}
+
+	a, err := session.keyAggCoeff(pubkey)
+	if err != nil {
+		return false
+	}
+
+	// Re = R1 + b*R2, negated if the final nonce has an odd y.
+	bR2 := R2.ScalarMultiply(values.b)
+	Re := R1.Add(&bR2)
+	if !values.R.hasEvenY() {
+		Re = Re.negate()
+	}
+
+	// g' = g * gacc
+	g := new(big.Int).Set(values.keyAgg.gacc)
+	if !values.keyAgg.q.hasEvenY() {
+		g = new(big.Int).Sub(N, g)
+	}
+
+	// s*G == Re + e*a*g'*P
+	sG := G.ScalarMultiply(s)
+	eaP := pubkey.ScalarMultiply(ModMulPrime(ModMulPrime(values.e, a, N), g, N))
+	expected := Re.Add(&eaP)
+
+	return sG.Equals(&expected)
+}
+
+// Combines the partial signatures into the final BIP340 signature for the aggregated key.
+func (session *MuSigSession) AggregatePartialSigs(psigs [][]byte) (SchnorrSignature, error) {
+
+	values, err := session.values()
+	if err != nil {
+		return SchnorrSignature{}, err
+	}
+
+	s := new(big.Int)
+	for i, psig := range psigs {
+		si := new(big.Int).SetBytes(psig)
+		if len(psig) != 32 || si.Cmp(N) >= 0 {
+			return SchnorrSignature{}, &MuSigInvalidContributionError{Signer: i, Contribution: "psig"}
+		}
+		s.Add(s, si)
+	}
+
+	// s + e * g * tacc
+	g := big.NewInt(1)
+	if !values.keyAgg.q.hasEvenY() {
+		g = new(big.Int).Sub(N, BigOne)
+	}
+	s.Add(s, new(big.Int).Mul(values.e, new(big.Int).Mul(g, values.keyAgg.tacc)))
+	s.Mod(s, N)
+
+	return SchnorrSignature{R: values.R.x, S: s}, nil
+}
+
+// Only accepts the 33 byte compressed encoding.
+func parseCompressedPoint(buffer []byte) (Point, error) {
+	if len(buffer) != 33 || (buffer[0] != 0x02 && buffer[0] != 0x03) {
+		return Point{}, errors.New("not a compressed point")
+	}
+	return ParseSEC(buffer)
+}
+
+// Same as parseCompressedPoint, but 33 zero bytes mean the point at infinity.
+func parseCompressedPointExt(buffer []byte) (Point, error) {
+	if bytes.Equal(buffer, make([]byte, 33)) {
+		return Secp256k1.Infinity(), nil
+	}
+	return parseCompressedPoint(buffer)
+}
+
+func serializeCompressedExt(p *Point) []byte {
+	if p.x == nil {
+		return make([]byte, 33)
+	}
+	return p.ToSEC(true)
+}
@@ -0,0 +1,399 @@
+package ecc_test
+
+import (
+	"bitcoin-go/ecc"
+	"bitcoin-go/utility"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func muSigPoints(t *testing.T, hexKeys []string, indices []int) []ecc.Point {
+	points := make([]ecc.Point, len(indices))
+	for i, index := range indices {
+		buffer, _ := hex.DecodeString(hexKeys[index])
+		p, err := ecc.ParseSEC(buffer)
+		if err != nil {
+			t.Fatalf("Bad test key %v: %v", hexKeys[index], err)
+		}
+		points[i] = p
+	}
+	return points
+}
+
+// From BIP327's key_agg_vectors.json
+var muSigKeyAggPubKeys = []string{
+	"02F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+	"03DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+	"023590A94E768F8E1815C2F24B4D80A8E3149316C3518CE7B7AD338368D038CA66",
+}
+
+func TestMuSigKeyAggVectors(t *testing.T) {
+
+	testCases := []struct {
+		indices  []int
+		expected string
+	}{
+		{[]int{0, 1, 2}, "90539EEDE565F5D054F32CC0C220126889ED1E5D193BAF15AEF344FE59D4610C"},
+		{[]int{2, 1, 0}, "6204DE8B083426DC6EAF9502D27024D53FC826BF7D2012148A0575435DF54B2B"},
+		{[]int{0, 0, 0}, "B436E3BAD62B8CD409969A224731C193D051162D8C5AE8B109306127DA3AA935"},
+		{[]int{0, 0, 1, 1}, "69BC22BFA5D106306E48A20679DE1D7389386124D07571D0D872686028C26A3E"},
+	}
+
+	for _, testCase := range testCases {
+		ctx, err := ecc.MuSigKeyAgg(muSigPoints(t, muSigKeyAggPubKeys, testCase.indices))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		key := ctx.AggregatedKey()
+		if actual := strings.ToUpper(hex.EncodeToString(key.ToXOnly())); actual != testCase.expected {
+			t.Errorf("%v: expected %v, got %v", testCase.indices, testCase.expected, actual)
+		}
+	}
+}
+
+func TestMuSigKeyAggInvalid(t *testing.T) {
+
+	// Invalid keys from key_agg_vectors.json: not on the curve, x exceeds the field size and a bad prefix.
+	for _, key := range []string{
+		"020000000000000000000000000000000000000000000000000000000000000005",
+		"02FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC30",
+		"04F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+	} {
+		buffer, _ := hex.DecodeString(key)
+		if _, err := ecc.ParseSEC(buffer); err == nil {
+			t.Errorf("%v should be rejected", key)
+		}
+	}
+
+	ctx, err := ecc.MuSigKeyAgg(muSigPoints(t, muSigKeyAggPubKeys, []int{0, 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A tweak equal to the curve order is invalid.
+	tweak, _ := hex.DecodeString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141")
+	if err := ctx.ApplyTweak(tweak, true); err == nil {
+		t.Errorf("Tweak equal to N should be rejected")
+	}
+
+	if _, err := ecc.MuSigKeyAgg(nil); err == nil {
+		t.Errorf("Empty key list should be rejected")
+	}
+}
+
+// Decodes an optional field of the vectors, "null" meaning the argument isn't given at all (which
+// isn't the same as an empty message).
+func muSigOptionalHex(s string) []byte {
+	if s == "null" {
+		return nil
+	}
+	buffer, _ := hex.DecodeString(s)
+	return append([]byte{}, buffer...)
+}
+
+func TestMuSigNonceGenVectors(t *testing.T) {
+
+	// From BIP327's nonce_gen_vectors.json. The expected value is the secret nonce, k1 || k2 || pk.
+	testCases := []struct {
+		rand     string
+		sk       string
+		pk       string
+		aggpk    string
+		msg      string
+		extraIn  string
+		expected string
+	}{
+		{
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"0202020202020202020202020202020202020202020202020202020202020202",
+			"024D4B6CD1361032CA9BD2AEB9D900AA4D45D9EAD80AC9423374C451A7254D0766",
+			"0707070707070707070707070707070707070707070707070707070707070707",
+			"0101010101010101010101010101010101010101010101010101010101010101",
+			"0808080808080808080808080808080808080808080808080808080808080808",
+			"227243DCB40EF2A13A981DB188FA433717B506BDFA14B1AE47D5DC027C9C3B9EF2370B2AD206E724243215137C86365699361126991E6FEC816845F837BDDAC3024D4B6CD1361032CA9BD2AEB9D900AA4D45D9EAD80AC9423374C451A7254D0766",
+		},
+		{
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"0202020202020202020202020202020202020202020202020202020202020202",
+			"024D4B6CD1361032CA9BD2AEB9D900AA4D45D9EAD80AC9423374C451A7254D0766",
+			"0707070707070707070707070707070707070707070707070707070707070707",
+			"",
+			"0808080808080808080808080808080808080808080808080808080808080808",
+			"CD0F47FE471D6788FF3243F47345EA0A179AEF69476BE8348322EF39C2723318870C2065AFB52DEDF02BF4FDBF6D2F442E608692F50C2374C08FFFE57042A61C024D4B6CD1361032CA9BD2AEB9D900AA4D45D9EAD80AC9423374C451A7254D0766",
+		},
+		{
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"0202020202020202020202020202020202020202020202020202020202020202",
+			"024D4B6CD1361032CA9BD2AEB9D900AA4D45D9EAD80AC9423374C451A7254D0766",
+			"0707070707070707070707070707070707070707070707070707070707070707",
+			"2626262626262626262626262626262626262626262626262626262626262626262626262626",
+			"0808080808080808080808080808080808080808080808080808080808080808",
+			"011F8BC60EF061DEEF4D72A0A87200D9994B3F0CD9867910085C38D5366E3E6B9FF03BC0124E56B24069E91EC3F162378983F194E8BD0ED89BE3059649EAE262024D4B6CD1361032CA9BD2AEB9D900AA4D45D9EAD80AC9423374C451A7254D0766",
+		},
+		{
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"null",
+			"02F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+			"null",
+			"null",
+			"null",
+			"890E83616A3BC4640AB9B6374F21C81FF89CDDDBAFAA7475AE2A102A92E3EDB29FD7E874E23342813A60D9646948242646B7951CA046B4B36D7D6078506D3C9402F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+		},
+	}
+
+	for i, testCase := range testCases {
+		var secret *big.Int
+		if sk := muSigOptionalHex(testCase.sk); sk != nil {
+			secret = new(big.Int).SetBytes(sk)
+		}
+		pk := muSigPoints(t, []string{testCase.pk}, []int{0})[0]
+
+		secnonce, pubnonce, err := ecc.MuSigNonceGen(secret, &pk, muSigOptionalHex(testCase.aggpk),
+			muSigOptionalHex(testCase.msg), muSigOptionalHex(testCase.extraIn), muSigOptionalHex(testCase.rand))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual := strings.ToUpper(hex.EncodeToString(secnonce)); actual != testCase.expected {
+			t.Errorf("%v: expected %v, got %v", i, testCase.expected, actual)
+		}
+
+		// The public nonce is k1 * G || k2 * G.
+		for j := 0; j < 2; j++ {
+			R := ecc.G.ScalarMultiply(new(big.Int).SetBytes(secnonce[j*32 : (j+1)*32]))
+			if expected := hex.EncodeToString(R.ToSEC(true)); hex.EncodeToString(pubnonce[j*33:(j+1)*33]) != expected {
+				t.Errorf("%v: public nonce %v doesn't match the secret nonce", i, j+1)
+			}
+		}
+	}
+}
+
+func TestMuSigNonceAggVectors(t *testing.T) {
+
+	// From nonce_agg_vectors.json
+	pnonces := [][]byte{
+		muSigHex("020151C80F435648DF67A22B749CD798CE54E0321D034B92B709B567D60A42E66603BA47FBC1834437B3212E89A84D8425E7BF12E0245D98262268EBDCB385D50641"),
+		muSigHex("03FF406FFD8ADB9CD29877E4985014F66A59F6CD01C0E88CAA8E5F3166B1F676A60248C264CDD57D3C24D79990B0F865674EB62A0F9018277A95011B41BFC193B833"),
+	}
+
+	aggnonce, err := ecc.MuSigNonceAgg(pnonces)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "035FE1873B4F2967F52FEA4A06AD5A8ECCBE9D0FD73068012C894E2E87CCB5804B024725377345BDE0E9C33AF3C43C0A29A9249F2F2956FA8CFEB55C8573D0262DC8"
+	if actual := strings.ToUpper(hex.EncodeToString(aggnonce)); actual != expected {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+
+	// The second nonce of signer 1 isn't on the curve.
+	bad := append([]byte{}, pnonces[1]...)
+	copy(bad[33:], muSigHex("020000000000000000000000000000000000000000000000000000000000000009"))
+	_, err = ecc.MuSigNonceAgg([][]byte{pnonces[0], bad})
+	if contributionErr, ok := err.(*ecc.MuSigInvalidContributionError); !ok || contributionErr.Signer != 1 {
+		t.Errorf("Expected an invalid contribution from signer 1, got %v", err)
+	}
+}
+
+// Shared by sign_verify_vectors.json and tweak_vectors.json
+var muSigSignSecret = "7FB9E0E687ADA1EEBF7ECFE2F21E73EBDB51A7D450948DFE8D76D7F2D1007671"
+var muSigSignSecNonce = "508B81A611F100A6B2B6B29656590898AF488BCF2E1F55CF22E5CFB84421FE61FA27FD49B1D50085B481285E1CA205D55C82CC1B31FF5CD54A489829355901F703935F972DA013F80AE011890FA89B67A27B7BE6CCB24D3274D18B2D4067F261A9"
+var muSigSignPubNonces = []string{
+	"0337C87821AFD50A8644D820A8F3E02E499C931865C2360FB43D0A0D20DAFE07EA0287BF891D2A6DEAEBADC909352AA9405D1428C15F4B75F04DAE642A95C2548480",
+	"0279BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F817980279BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798",
+	"032DE2662628C90B03F5E720284EB52FF7D71F4284F627B68A853D78C78E1FFE9303E4C5524E83FFE1493B9077CF1CA6BEB2090C93D930321071AD40B2F44E599046",
+}
+var muSigSignMsg = "F95466D086770E689964664219266FE5ED215C92AE20BAB5C9D79ADDDDF3C0CF"
+
+func muSigHex(s string) []byte {
+	buffer, _ := hex.DecodeString(s)
+	return buffer
+}
+
+func muSigVectorSession(t *testing.T, keys []string, indices []int) ecc.MuSigSession {
+	pubnonces := make([][]byte, len(indices))
+	for i, index := range indices {
+		pubnonces[i] = muSigHex(muSigSignPubNonces[index])
+	}
+
+	aggnonce, err := ecc.MuSigNonceAgg(pubnonces)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ecc.MuSigSession{AggNonce: aggnonce, PubKeys: muSigPoints(t, keys, indices), Msg: muSigHex(muSigSignMsg)}
+}
+
+func TestMuSigSignVectors(t *testing.T) {
+
+	keys := []string{
+		"03935F972DA013F80AE011890FA89B67A27B7BE6CCB24D3274D18B2D4067F261A9",
+		"02F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+		"02DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA661",
+	}
+
+	key := ecc.NewPrivateKey(ecc.NewScalarFromBytes(muSigHex(muSigSignSecret)).BigInt())
+	signerPub := muSigPoints(t, keys, []int{0})[0]
+
+	testCases := []struct {
+		indices  []int
+		expected string
+	}{
+		{[]int{0, 1, 2}, "012ABBCB52B3016AC03AD82395A1A415C48B93DEF78718E62A7A90052FE224FB"},
+		{[]int{1, 0, 2}, "9FF2F7AAA856150CC8819254218D3ADEEB0535269051897724F9DB3789513A52"},
+		{[]int{1, 2, 0}, "FA23C359F6FAC4E7796BB93BC9F0532A95468C539BA20FF86D7C76ED92227900"},
+	}
+
+	for _, testCase := range testCases {
+		session := muSigVectorSession(t, keys, testCase.indices)
+
+		psig, err := key.MuSigSign(muSigHex(muSigSignSecNonce), &session)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual := strings.ToUpper(hex.EncodeToString(psig)); actual != testCase.expected {
+			t.Errorf("%v: expected %v, got %v", testCase.indices, testCase.expected, actual)
+		}
+
+		pubnonce := muSigHex(muSigSignPubNonces[0])
+		if !session.VerifyPartialSig(psig, pubnonce, &signerPub) {
+			t.Errorf("%v: partial signature didn't verify", testCase.indices)
+		}
+
+		// The negated partial signature, or one checked against the wrong signer, must fail.
+		negated := ecc.NewScalarFromBytes(psig).Negate().Bytes()
+		if session.VerifyPartialSig(negated, pubnonce, &signerPub) {
+			t.Errorf("%v: negated partial signature verified", testCase.indices)
+		}
+
+		other := muSigPoints(t, keys, []int{1})[0]
+		if session.VerifyPartialSig(psig, muSigHex(muSigSignPubNonces[1]), &other) {
+			t.Errorf("%v: partial signature verified for the wrong signer", testCase.indices)
+		}
+
+		if session.VerifyPartialSig(muSigHex("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141"), pubnonce, &signerPub) {
+			t.Errorf("%v: partial signature equal to N verified", testCase.indices)
+		}
+	}
+}
+
+func TestMuSigTweakVectors(t *testing.T) {
+
+	keys := []string{
+		"03935F972DA013F80AE011890FA89B67A27B7BE6CCB24D3274D18B2D4067F261A9",
+		"02F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+		"02DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+	}
+
+	tweaks := []string{
+		"E8F791FF9225A2AF0102AFFF4A9A723D9612A682A25EBE79802B263CDFCD83BB",
+		"AE2EA797CC0FE72AC5B97B97F3C6957D7E4199A167A58EB08BCAFFDA70AC0455",
+		"F52ECBC565B3D8BEA2DFD5B75A4F457E54369809322E4120831626F290FA87E0",
+		"1969AD73CC177FA0B4FCED6DF1F7BF9907E665FDE9BA196A74FED0A3CF5AEF9D",
+	}
+
+	key := ecc.NewPrivateKey(ecc.NewScalarFromBytes(muSigHex(muSigSignSecret)).BigInt())
+
+	testCases := []struct {
+		tweakIndices []int
+		isXOnly      []bool
+		expected     string
+	}{
+		{[]int{0}, []bool{true}, "E28A5C66E61E178C2BA19DB77B6CF9F7E2F0F56C17918CD13135E60CC848FE91"},
+		{[]int{0}, []bool{false}, "38B0767798252F21BF5702C48028B095428320F73A4B14DB1E25DE58543D2D2D"},
+		{[]int{0, 1}, []bool{false, true}, "408A0A21C4A0F5DACAF9646AD6EB6FECD7F7A11F03ED1F48DFFF2185BC2C2408"},
+		{[]int{0, 1, 2, 3}, []bool{false, false, true, true}, "45ABD206E61E3DF2EC9E264A6FEC8292141A633C28586388235541F9ADE75435"},
+		{[]int{0, 1, 2, 3}, []bool{true, false, true, false}, "B255FDCAC27B40C7CE7848E2D3B7BF5EA0ED756DA81565AC804CCCA3E1D5D239"},
+	}
+
+	for _, testCase := range testCases {
+		session := muSigVectorSession(t, keys, []int{1, 2, 0})
+		for _, i := range testCase.tweakIndices {
+			session.Tweaks = append(session.Tweaks, muSigHex(tweaks[i]))
+		}
+		session.IsXOnly = testCase.isXOnly
+
+		psig, err := key.MuSigSign(muSigHex(muSigSignSecNonce), &session)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual := strings.ToUpper(hex.EncodeToString(psig)); actual != testCase.expected {
+			t.Errorf("%v %v: expected %v, got %v", testCase.tweakIndices, testCase.isXOnly, testCase.expected, actual)
+		}
+	}
+}
+
+func TestMuSigRoundTrip(t *testing.T) {
+
+	for _, signers := range []int{2, 3} {
+		keys := make([]ecc.PrivateKey, signers)
+		pubkeys := make([]ecc.Point, signers)
+		for i := range keys {
+			keys[i] = ecc.NewPrivateKey(new(big.Int).SetBytes(utility.RandomData(32)))
+			pubkeys[i] = ecc.G.ScalarMultiply(keys[i].Secret)
+		}
+		pubkeys = ecc.MuSigKeySort(pubkeys)
+
+		msg := utility.Hash256([]byte("2-of-2 and 3-of-3 custody"))
+
+		// Taproot style x-only tweak on top of the aggregated key.
+		tweak := utility.TaggedHash("TapTweak", []byte("tweak"))
+
+		secnonces := make([][]byte, signers)
+		pubnonces := make([][]byte, signers)
+		for i := range keys {
+			pub := ecc.G.ScalarMultiply(keys[i].Secret)
+			var err error
+			secnonces[i], pubnonces[i], err = ecc.MuSigNonceGen(keys[i].Secret, &pub, nil, msg, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		aggnonce, err := ecc.MuSigNonceAgg(pubnonces)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		session := ecc.MuSigSession{AggNonce: aggnonce, PubKeys: pubkeys, Tweaks: [][]byte{tweak}, IsXOnly: []bool{true}, Msg: msg}
+
+		psigs := make([][]byte, signers)
+		for i := range keys {
+			psigs[i], err = keys[i].MuSigSign(secnonces[i], &session)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pub := ecc.G.ScalarMultiply(keys[i].Secret)
+			if !session.VerifyPartialSig(psigs[i], pubnonces[i], &pub) {
+				t.Errorf("Partial signature %v didn't verify", i)
+			}
+		}
+
+		// A secret nonce can't be used twice.
+		if _, err := keys[0].MuSigSign(secnonces[0], &session); err == nil {
+			t.Errorf("Secret nonce was reused")
+		}
+
+		sig, err := session.AggregatePartialSigs(psigs)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		aggregated, _ := session.AggregatedKey()
+		if !aggregated.VerifySchnorr(msg, sig) {
+			t.Errorf("%v signers: aggregated signature didn't verify", signers)
+		}
+
+		// Dropping a partial signature breaks the result.
+		partial, _ := session.AggregatePartialSigs(psigs[1:])
+		if aggregated.VerifySchnorr(msg, partial) {
+			t.Errorf("%v signers: incomplete signature verified", signers)
+		}
+	}
+}
@@ -2,34 +2,45 @@ package ecc
 
 import (
 	"bitcoin-go/utility"
+	"errors"
+	"fmt"
 	"math/big"
 )
 
 type Point struct {
-	x *big.Int
-	y *big.Int
-	a *big.Int
-	b *big.Int
+	x     *big.Int
+	y     *big.Int
+	curve *Curve // nil means secp256k1
 }
 
+// Creates a point on secp256k1. Panics if (x, y) isn't on the curve, use Secp256k1.NewPoint for untrusted data.
 func NewSecp256k1Point(x *big.Int, y *big.Int) Point {
-	return newSecp256k1PointWithCustomAAndB(x, y, A, B)
+	point, err := Secp256k1.NewPoint(x, y)
+	if err != nil {
+		panic(err)
+	}
+	return point
 }
 
-// User beware. This will create a point with the sufficient values, but many of the point operations still assume prime = P.
-func newSecp256k1PointWithCustomAAndB(x *big.Int, y *big.Int, a *big.Int, b *big.Int) Point {
-
-	if x != nil && y != nil {
+// The curve the point is on.
+func (p *Point) Curve() *Curve {
+	if p.curve == nil {
+		return Secp256k1
+	}
+	return p.curve
+}
 
-		y_squared := ModPowInt(y, 2)
-		sum := ModAdd(ModAdd(ModPowInt(x, 3), ModMul(a, x)), b)
+// The affine coordinates, both nil for the point at infinity.
+func (p *Point) X() *big.Int {
+	return p.x
+}
 
-		if y_squared.Cmp(sum) != 0 {
-			panic("Bad point parameters.")
-		}
-	}
+func (p *Point) Y() *big.Int {
+	return p.y
+}
 
-	return Point{x: x, y: y, a: a, b: b}
+func (p *Point) IsInfinity() bool {
+	return p.x == nil
 }
 
 func (p *Point) Equals(p2 *Point) bool {
@@ -46,16 +57,12 @@ func (p *Point) Equals(p2 *Point) bool {
 	}
 
 	if p.x != nil && p.y != nil {
-		if p.x.Cmp(p2.x) != 0 || p.y.Cmp(p2.y) != 0 {
+		if p2.x == nil || p.x.Cmp(p2.x) != 0 || p.y.Cmp(p2.y) != 0 {
 			return false
 		}
 	}
 
-	if p.a.Cmp(p2.a) == 0 && p.b.Cmp(p2.b) == 0 {
-		return true
-	}
-
-	return false
+	return p.Curve().Equals(p2.Curve())
 }
 
 func (p *Point) NotEquals(p2 *Point) bool {
@@ -64,9 +71,11 @@ func (p *Point) NotEquals(p2 *Point) bool {
 
 func (p *Point) Add(p2 *Point) Point {
 
-	if p.a.Cmp(p2.a) != 0 || p.b.Cmp(p2.b) != 0 {
+	curve := p.Curve()
+	if !curve.Equals(p2.Curve()) {
 		panic("Points aren't on the same curve.")
 	}
+	prime := curve.P
 
 	// Case 0.0: self is the point at infinity, return other
 	if p.x == nil {
@@ -81,7 +90,7 @@ func (p *Point) Add(p2 *Point) Point {
 	// Case 1: self.x == other.x, self.y != other.y
 	// Result is point at infinity
 	if p.x.Cmp(p2.x) == 0 && p.y.Cmp(p2.y) != 0 {
-		return Point{nil, nil, p.a, p.b}
+		return curve.Infinity()
 	}
 
 	// Case 2: self.x ≠ other.x
@@ -90,20 +99,17 @@ func (p *Point) Add(p2 *Point) Point {
 	// x3=s**2-x1-x2
 	// y3=s*(x1-x3)-y1
 	if p.x.Cmp(p2.x) != 0 {
-		s := ModDiv(ModSub(p2.y, p.y), ModSub(p2.x, p.x))
-		x := ModSub(ModSub(ModPowInt(s, 2), p.x), p2.x)
-		y := ModSub(ModMul(s, ModSub(p.x, x)), p.y)
+		s := ModDivPrime(ModSubPrime(p2.y, p.y, prime), ModSubPrime(p2.x, p.x, prime), prime)
+		x := ModSubPrime(ModSubPrime(ModPowPrimeInt(s, 2, prime), p.x, prime), p2.x, prime)
+		y := ModSubPrime(ModMulPrime(s, ModSubPrime(p.x, x, prime), prime), p.y, prime)
 
-		return Point{x, y, p.a, p.b}
+		return Point{x, y, curve}
 	}
 
 	// Case 4: if we are tangent to the vertical line,
 	// we return the point at infinity
-	// note instead of figuring out what 0 is for each type
-	// we just use 0 * self.x
-	mult_x_by_zero := ModMulInt(p.x, 0)
-	if p.Equals(p2) && p.y.Cmp(mult_x_by_zero) == 0 {
-		return Point{nil, nil, p.a, p.b}
+	if p.y.Sign() == 0 {
+		return curve.Infinity()
 	}
 
 	// Case 3: self == other
@@ -111,50 +117,67 @@ func (p *Point) Add(p2 *Point) Point {
 	// s=(3*x1**2+a)/(2*y1)
 	// x3=s**2-2*x1
 	// y3=s*(x1-x3)-y1
-	if p.Equals(p2) {
-		left_side := ModAdd(ModMulInt(ModPowInt(p.x, 2), 3), p.a)
-		right_side := ModMulInt(p.y, 2)
-		s := ModDiv(left_side, right_side)
-		x := ModSub(ModPowInt(s, 2), ModMulInt(p.x, 2))
-		y := ModSub(ModMul(s, ModSub(p.x, x)), p.y)
-		return Point{x, y, p.a, p.b}
-	}
-
-	panic("We shouldn't get here!")
+	left_side := ModAddPrime(ModMulPrime(ModPowPrimeInt(p.x, 2, prime), BigThree, prime), curve.A, prime)
+	right_side := ModMulPrime(p.y, BigTwo, prime)
+	s := ModDivPrime(left_side, right_side, prime)
+	x := ModSubPrime(ModPowPrimeInt(s, 2, prime), ModMulPrime(p.x, BigTwo, prime), prime)
+	y := ModSubPrime(ModMulPrime(s, ModSubPrime(p.x, x, prime), prime), p.y, prime)
+	return Point{x, y, curve}
 }
 
 func (p *Point) ScalarMultiply(coefficient *big.Int) Point {
 
-	coef := big.NewInt(0)
-	coef = coef.Mod(coefficient, N)
-	current := p.Clone()
-	result := Point{nil, nil, p.a, p.b}
-
-	for coef.Cmp(BigZero) != 0 {
-
-		and := new(big.Int)
-		and.And(coef, BigOne)
+	// secp256k1 has a cofactor of 1, so every point has order N and the coefficient can be reduced.
+	// On other curves a point doesn't have to be in the group generated by G, so only negative
+	// coefficients get reduced mod N (which is then only correct for multiples of G).
+	curve := p.Curve()
+	coef := new(big.Int).Set(coefficient)
+	if coef.Sign() < 0 || Secp256k1.Equals(curve) {
+		coef.Mod(coef, curve.N)
+	}
 
-		if and.Cmp(BigZero) != 0 {
-			result = result.Add(&current)
-		}
+	if p.x == nil || coef.Sign() == 0 {
+		return curve.Infinity()
+	}
 
-		current = current.Add(&current)
-		coef = coef.Rsh(coef, 1)
+	// The generator has a precomputed table, everything else uses wNAF.
+	var result jacobianPoint
+	if p.isGenerator() {
+		result = generatorMultiply(coef)
+	} else {
+		result = wnafMultiply(p, coef)
 	}
 
-	return result
+	return result.toAffine(curve)
 }
 
 func (p *Point) Verify(hash *big.Int, sig Signature) bool {
-	s_inv := ModPowPrime(sig.S, ModSubInt(N, 2), N)
-	u := ModMulPrime(hash, s_inv, N)
-	v := ModMulPrime(sig.R, s_inv, N)
+	if p.x == nil || sig.R == nil || sig.S == nil {
+		return false
+	}
+
+	curve := p.Curve()
+	n := curve.N
+
+	// r and s must both be in the range [1, N-1]
+	if sig.R.Sign() <= 0 || sig.R.Cmp(n) >= 0 || sig.S.Sign() <= 0 || sig.S.Cmp(n) >= 0 {
+		return false
+	}
+
+	s_inv := ModPowPrime(sig.S, new(big.Int).Sub(n, BigTwo), n)
+	u := ModMulPrime(hash, s_inv, n)
+	v := ModMulPrime(sig.R, s_inv, n)
+
+	// Stay in Jacobian coordinates until the very end so we only pay for one inverse.
+	sub_total_uG := curve.generatorMultiply(u)
+	sub_total_vSelf := wnafMultiply(p, v)
+	total := sub_total_uG.add(&sub_total_vSelf, curve)
+	if total.isInfinity() {
+		return false
+	}
 
-	sub_total_uG := G.ScalarMultiply(u)
-	sub_total_vSelf := p.ScalarMultiply(v)
-	total := sub_total_uG.Add(&sub_total_vSelf)
-	return total.x.Cmp(sig.R) == 0
+	affine := total.toAffine(curve)
+	return new(big.Int).Mod(affine.x, n).Cmp(sig.R) == 0
 }
 
 func (p *Point) ToSEC(compressed bool) []byte {
@@ -175,32 +198,62 @@ func (p *Point) ToSEC(compressed bool) []byte {
 	}
 }
 
+// Parses a SEC encoded point. Panics on malformed input, use ParseSEC for untrusted data.
 func NewPointFromSEC(buffer []byte) Point {
+	point, err := ParseSEC(buffer)
+	if err != nil {
+		panic(err)
+	}
+	return point
+}
 
-	// TODO: Modify to return (Point, error) or (Point, ok) for failure cases.
-	if buffer[0] == 0x04 {
-		x := new(big.Int)
-		y := new(big.Int)
-		x.SetBytes(buffer[1:33])
-		y.SetBytes(buffer[33:65])
-		return NewSecp256k1Point(x, y)
-	} else {
-		x := new(big.Int)
-		x.SetBytes(buffer[1:])
-		yEven := (buffer[0] == 0x02)
-
-		alpha := ModAdd(ModPowInt(x, 3), B)
-		beta := ModSqrt(alpha)
-		alt_beta := ModSub(P, beta)
-		even_beta := beta
-		odd_beta := alt_beta
-
-		if !IsEven(beta) {
-			even_beta = alt_beta
-			odd_beta = beta
+// Parses a compressed (33 byte) or uncompressed (65 byte) SEC encoded secp256k1 point, making sure
+// the coordinates are in range and the point is actually on the curve.
+func ParseSEC(buffer []byte) (Point, error) {
+
+	if len(buffer) == 0 {
+		return Point{}, errors.New("empty SEC public key")
+	}
+
+	switch buffer[0] {
+	case 0x04:
+		if len(buffer) != 65 {
+			return Point{}, fmt.Errorf("uncompressed SEC public key must be 65 bytes, not %v", len(buffer))
+		}
+
+		x := new(big.Int).SetBytes(buffer[1:33])
+		y := new(big.Int).SetBytes(buffer[33:65])
+		if x.Cmp(P) >= 0 || y.Cmp(P) >= 0 {
+			return Point{}, errors.New("SEC public key coordinate exceeds the field size")
+		}
+
+		if !Secp256k1.IsOnCurve(x, y) {
+			return Point{}, errors.New("SEC public key is not on the curve")
+		}
+
+		return Point{x: x, y: y, curve: Secp256k1}, nil
+
+	case 0x02, 0x03:
+		if len(buffer) != 33 {
+			return Point{}, fmt.Errorf("compressed SEC public key must be 33 bytes, not %v", len(buffer))
 		}
 
-		return NewSecp256k1Point(x, utility.IIF(yEven, even_beta, odd_beta).(*big.Int))
+		x := new(big.Int).SetBytes(buffer[1:])
+
+		// liftX checks the range of x and whether there's a y for it, and returns the even y.
+		point, err := liftX(x)
+		if err != nil {
+			return Point{}, err
+		}
+
+		if buffer[0] == 0x03 {
+			point = point.negate()
+		}
+
+		return point, nil
+
+	default:
+		return Point{}, fmt.Errorf("unknown SEC prefix byte 0x%02x", buffer[0])
 	}
 }
 
@@ -213,12 +266,31 @@ func (p *Point) Address(compressed bool, testnet bool) string {
 	return utility.H160ToP2PKHAddress(h160, testnet)
 }
 
+// The native SegWit (bc1q...) address of the compressed public key.
+func (p *Point) P2WPKHAddress(testnet bool) string {
+	return utility.H160ToP2WPKHAddress(p.Hash160(true), testnet)
+}
+
+// The Taproot (bc1p...) address with this point as the internal key, tweaked with the merkle
+// root of the script tree (nil for a key path only output).
+func (p *Point) P2TRAddress(merkleRoot []byte, testnet bool) (string, error) {
+	internalKey, _, err := p.ToXOnlyPublicKey()
+	if err != nil {
+		return "", err
+	}
+	outputKey, _, err := TaprootOutputKey(&internalKey, merkleRoot)
+	if err != nil {
+		return "", err
+	}
+	return utility.OutputKeyToP2TRAddress(outputKey.Serialize(), testnet), nil
+}
+
 func (p *Point) Clone() Point {
-	clone := Point{x: new(big.Int), y: new(big.Int), a: new(big.Int), b: new(big.Int)}
-	clone.x.Set(p.x)
-	clone.y.Set(p.y)
-	clone.a.Set(p.a)
-	clone.b.Set(p.b)
+	clone := Point{curve: p.curve}
+	if p.x != nil {
+		clone.x = new(big.Int).Set(p.x)
+		clone.y = new(big.Int).Set(p.y)
+	}
 	return clone
 }
 
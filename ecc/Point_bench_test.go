@@ -0,0 +1,157 @@
+package ecc
+
+import (
+	"bitcoin-go/utility"
+	"math/big"
+	"testing"
+)
+
+// The original affine double-and-add implementation, kept around so the benchmarks can
+// compare it with the Jacobian/wNAF code and the tests can cross-check the results.
+func legacyScalarMultiply(p *Point, coefficient *big.Int) Point {
+
+	coef := new(big.Int).Mod(coefficient, N)
+	current := p.Clone()
+	result := p.Curve().Infinity()
+
+	for coef.Sign() != 0 {
+		if coef.Bit(0) == 1 {
+			result = result.Add(&current)
+		}
+
+		current = current.Add(&current)
+		coef.Rsh(coef, 1)
+	}
+
+	return result
+}
+
+func legacySign(key *PrivateKey, hash *big.Int) Signature {
+	k := deterministicK(key.Secret, hash, nil)
+	r := legacyScalarMultiply(&G, k).x
+	k_inv := ModPowPrime(k, new(big.Int).Sub(N, BigTwo), N)
+
+	s := new(big.Int).Mul(r, key.Secret)
+	s.Add(s, hash)
+	s = ModMulPrime(s, k_inv, N)
+
+	half_n := new(big.Int).Rsh(N, 1)
+	if s.Cmp(half_n) > 0 {
+		s.Sub(N, s)
+	}
+
+	return Signature{R: r, S: s}
+}
+
+func legacyVerify(p *Point, hash *big.Int, sig Signature) bool {
+	s_inv := ModPowPrime(sig.S, ModSubInt(N, 2), N)
+	u := ModMulPrime(hash, s_inv, N)
+	v := ModMulPrime(sig.R, s_inv, N)
+
+	uG := legacyScalarMultiply(&G, u)
+	vP := legacyScalarMultiply(p, v)
+	total := uG.Add(&vP)
+	return total.x.Cmp(sig.R) == 0
+}
+
+func TestScalarMultiplyMatchesAffine(t *testing.T) {
+
+	other := legacyScalarMultiply(&G, big.NewInt(0xc0ffee))
+
+	for i := 0; i < 20; i++ {
+		k := new(big.Int).SetBytes(utility.RandomData(32))
+
+		expected := legacyScalarMultiply(&G, k)
+		actual := G.ScalarMultiply(k)
+		if !expected.Equals(&actual) {
+			t.Errorf("Generator mismatch for %x", k)
+		}
+
+		expected = legacyScalarMultiply(&other, k)
+		actual = other.ScalarMultiply(k)
+		if !expected.Equals(&actual) {
+			t.Errorf("Point mismatch for %x", k)
+		}
+	}
+
+	// Edge cases around the group order.
+	for _, k := range []*big.Int{BigOne, BigTwo, new(big.Int).Sub(N, BigOne), N} {
+		expected := legacyScalarMultiply(&G, k)
+		actual := G.ScalarMultiply(k)
+		if expected.x == nil {
+			if actual.x != nil {
+				t.Errorf("Expected infinity for %x", k)
+			}
+			continue
+		}
+		if !expected.Equals(&actual) {
+			t.Errorf("Mismatch for %x", k)
+		}
+	}
+}
+
+func benchmarkKeyAndHash() (PrivateKey, Point, *big.Int) {
+	key := NewPrivateKey(utility.HexStringToBigInt("1cca23de92fd1862fb5b76e5f4f50eb082165e5191e116c18ed1a6b24be6a53f"))
+	pub := G.ScalarMultiply(key.Secret)
+	hash := new(big.Int).SetBytes(utility.Hash256([]byte("Programming Bitcoin!")))
+	return key, pub, hash
+}
+
+func BenchmarkSignLegacy(b *testing.B) {
+	key, _, hash := benchmarkKeyAndHash()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		legacySign(&key, hash)
+	}
+}
+
+func BenchmarkSign(b *testing.B) {
+	key, _, hash := benchmarkKeyAndHash()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key.Sign(hash)
+	}
+}
+
+func BenchmarkVerifyLegacy(b *testing.B) {
+	key, pub, hash := benchmarkKeyAndHash()
+	sig := key.Sign(hash)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		legacyVerify(&pub, hash, sig)
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	key, pub, hash := benchmarkKeyAndHash()
+	sig := key.Sign(hash)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pub.Verify(hash, sig)
+	}
+}
+
+func BenchmarkScalarMultiplyLegacy(b *testing.B) {
+	_, pub, hash := benchmarkKeyAndHash()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		legacyScalarMultiply(&pub, hash)
+	}
+}
+
+func BenchmarkScalarMultiply(b *testing.B) {
+	_, pub, hash := benchmarkKeyAndHash()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pub.ScalarMultiply(hash)
+	}
+}
+
+func BenchmarkScalarMultiplyConstantTime(b *testing.B) {
+	_, pub, hash := benchmarkKeyAndHash()
+	k := NewScalar(hash)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pub.ScalarMultiplyConstantTime(k)
+	}
+}
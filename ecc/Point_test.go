@@ -118,6 +118,48 @@ func TestPointAddress(t *testing.T) {
 	}
 }
 
+func TestPointSegWitAddress(t *testing.T) {
+
+	one := ecc.G.ScalarMultiply(big.NewInt(1))
+	if address := one.P2WPKHAddress(false); address != "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4" {
+		t.Errorf("Unexpected P2WPKH address %v", address)
+	}
+	if address := one.P2WPKHAddress(true); address != "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx" {
+		t.Errorf("Unexpected testnet P2WPKH address %v", address)
+	}
+
+	// From BIP341's wallet-test-vectors.json
+	testCases := []struct {
+		internalKey string
+		merkleRoot  string
+		address     string
+	}{
+		{"d6889cb081036e0faefa3a35157ad71086b123b2b144b649798b494c300a961d", "", "bc1p2wsldez5mud2yam29q22wgfh9439spgduvct83k3pm50fcxa5dps59h4z5"},
+		{"187791b6f712a8ea41c8ecdd0ee77fab3e85263b37e1ec18a3651926b3a6cf27", "5b75adecf53548f3ec6ad7d78383bf84cc57b55a3127c72b9a2481752dd88b21", "bc1pz37fc4cn9ah8anwm4xqqhvxygjf9rjf2resrw8h8w4tmvcs0863sa2e586"},
+	}
+
+	for _, testCase := range testCases {
+		internalKey, err := ecc.ParseXOnlyPublicKey(BytesFromHex(testCase.internalKey))
+		if err != nil {
+			t.Fatal(err)
+		}
+		point := internalKey.Point()
+
+		var merkleRoot []byte
+		if testCase.merkleRoot != "" {
+			merkleRoot = BytesFromHex(testCase.merkleRoot)
+		}
+
+		address, err := point.P2TRAddress(merkleRoot, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if address != testCase.address {
+			t.Errorf("Expected %v, got %v", testCase.address, address)
+		}
+	}
+}
+
 func N256P(xHex string, yHex string) ecc.Point {
 	return ecc.NewSecp256k1Point(
 		utility.HexStringToBigInt(xHex),
@@ -195,3 +237,46 @@ func PointAddressTestCase(secret *big.Int, mainNetAddr string, testNetAddr strin
 
 	return true
 }
+
+func TestParseSECInvalid(t *testing.T) {
+	valid := ecc.G.ToSEC(true)
+	uncompressed := ecc.G.ToSEC(false)
+
+	invalid := map[string][]byte{
+		"empty":                  {},
+		"unknown prefix":         append([]byte{0x05}, valid[1:]...),
+		"compressed too long":    append(append([]byte{}, valid...), 0x00),
+		"uncompressed too short": uncompressed[:64],
+		"hybrid prefix":          append([]byte{0x06}, uncompressed[1:]...),
+	}
+
+	// x = 5 has no matching y on secp256k1.
+	notOnCurve := make([]byte, 33)
+	notOnCurve[0] = 0x02
+	notOnCurve[32] = 0x05
+	invalid["x not on curve"] = notOnCurve
+
+	// x >= P
+	tooLarge := make([]byte, 33)
+	tooLarge[0] = 0x02
+	ecc.P.FillBytes(tooLarge[1:])
+	invalid["x too large"] = tooLarge
+
+	// Flip a bit of y so the point is off the curve.
+	offCurve := append([]byte{}, uncompressed...)
+	offCurve[64] ^= 0x01
+	invalid["y not on curve"] = offCurve
+
+	for name, buffer := range invalid {
+		if _, err := ecc.ParseSEC(buffer); err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+
+	for _, buffer := range [][]byte{valid, uncompressed} {
+		p, err := ecc.ParseSEC(buffer)
+		if err != nil || !p.Equals(&ecc.G) {
+			t.Errorf("Failed to parse %x", buffer)
+		}
+	}
+}
@@ -2,29 +2,125 @@ package ecc
 
 import (
 	"bitcoin-go/utility"
+	"errors"
+	"fmt"
 	"math/big"
 )
 
 type PrivateKey struct {
 	Secret *big.Int
+	curve  *Curve // nil means secp256k1
 }
 
 func NewPrivateKey(secret *big.Int) PrivateKey {
 	return PrivateKey{Secret: secret}
 }
 
+// A key on some other curve. Only ECDSA signing works for those, everything else assumes secp256k1.
+// ECDSA divides by the nonce mod N, so the generator must have a prime order. NewCurve makes sure
+// of that, this catches curves put together by hand.
+func NewPrivateKeyOnCurve(secret *big.Int, curve *Curve) (PrivateKey, error) {
+	if !curve.N.ProbablyPrime(20) {
+		return PrivateKey{}, errors.New("ECDSA needs a generator with a prime order")
+	}
+	return PrivateKey{Secret: secret, curve: curve}, nil
+}
+
+func (key *PrivateKey) Curve() *Curve {
+	if key.curve == nil {
+		return Secp256k1
+	}
+	return key.curve
+}
+
+// The public key, computed in constant time on secp256k1 since the secret is involved.
+func (key *PrivateKey) PublicKey() Point {
+	curve := key.Curve()
+	if !Secp256k1.Equals(curve) {
+		return curve.G.ScalarMultiply(key.Secret)
+	}
+	return G.ScalarMultiplyConstantTime(NewScalar(key.Secret))
+}
+
+// Signs the hash using a deterministic RFC 6979 nonce, so the same key and hash always produce the same signature.
 func (key *PrivateKey) Sign(hash *big.Int) Signature {
-	k := getK()
-	r := (G.ScalarMultiply(k)).x
-	k_inv := ModPowPrime(k, ModSubInt(N, 2), N)
-	s := ModMulPrime(ModAdd(ModMul(r, key.Secret), hash), k_inv, N)
+	return key.SignWithEntropy(hash, nil)
+}
+
+// Same as Sign, but mixes extra entropy into the nonce derivation (as Bitcoin Core does when grinding for low R values).
+func (key *PrivateKey) SignWithEntropy(hash *big.Int, extraEntropy []byte) Signature {
+	sig, _ := key.sign(hash, extraEntropy)
+	return sig
+}
+
+// Creates the signature along with its recovery id: bit 0 is the parity of R's y coordinate
+// and bit 1 is set when R's x coordinate was reduced mod N.
+func (key *PrivateKey) sign(hash *big.Int, extraEntropy []byte) (Signature, byte) {
+	if !Secp256k1.Equals(key.Curve()) {
+		return key.signOnCurve(hash, extraEntropy)
+	}
+
+	k := NewScalar(deterministicK(key.Secret, hash, extraEntropy))
+
+	// Everything involving the secret or the nonce is done in constant time.
+	R := G.ScalarMultiplyConstantTime(k)
+	r := new(big.Int).Mod(R.x, N)
+
+	recoveryId := byte(R.y.Bit(0))
+	if R.x.Cmp(N) >= 0 {
+		recoveryId |= 2
+	}
+
+	d := NewScalar(key.Secret)
+	z := NewScalar(hash)
+	s := NewScalar(r).Mul(d).Add(z).Mul(k.Inverse()).BigInt()
 
-	half_n := ModMulInt(N, 2)
+	// Use the low-s value (BIP 62). Negating s is the same as negating R, so flip the parity.
+	half_n := new(big.Int).Rsh(N, 1)
 	if s.Cmp(half_n) > 0 {
-		s = ModSub(N, s)
+		s.Sub(N, s)
+		recoveryId ^= 1
 	}
 
-	return Signature{R: r, S: s}
+	return Signature{R: r, S: s}, recoveryId
+}
+
+// The textbook version of sign with big.Int arithmetic, for curves other than secp256k1.
+// It isn't constant time, which is fine for the toy curves it's meant for.
+func (key *PrivateKey) signOnCurve(hash *big.Int, extraEntropy []byte) (Signature, byte) {
+	curve := key.Curve()
+	n := curve.N // Prime, NewCurve and NewPrivateKeyOnCurve check
+
+	for {
+		k := deterministicKForOrder(key.Secret, hash, extraEntropy, n)
+
+		R := curve.G.ScalarMultiply(k)
+		r := new(big.Int).Mod(R.x, n)
+
+		// s = (z + r*d) / k
+		s := new(big.Int).Mul(r, key.Secret)
+		s.Add(s, hash)
+		s = ModMulPrime(s, new(big.Int).ModInverse(k, n), n)
+
+		// Tiny groups can hit r = 0 or s = 0, so retry with a different nonce.
+		if r.Sign() == 0 || s.Sign() == 0 {
+			extraEntropy = append(append([]byte{}, extraEntropy...), 0x00)
+			continue
+		}
+
+		recoveryId := byte(R.y.Bit(0))
+		if R.x.Cmp(n) >= 0 {
+			recoveryId |= 2
+		}
+
+		half_n := new(big.Int).Rsh(n, 1)
+		if s.Cmp(half_n) > 0 {
+			s.Sub(n, s)
+			recoveryId ^= 1
+		}
+
+		return Signature{R: r, S: s}, recoveryId
+	}
 }
 
 func (key *PrivateKey) WIF(compressed bool, testnet bool) string {
@@ -33,7 +129,8 @@ func (key *PrivateKey) WIF(compressed bool, testnet bool) string {
 
 	bytes[0] = utility.IIF(testnet, (byte)(0xef), (byte)(0x80)).(byte)
 
-	copy(bytes[1:], key.Secret.Bytes())
+	// Secrets with leading zero bytes still take up the full 32 bytes.
+	fillBufferWithIntBytes(bytes[1:33], key.Secret, false)
 
 	if compressed {
 		bytes[33] = 0x01
@@ -47,10 +144,40 @@ func (key *PrivateKey) WIF(compressed bool, testnet bool) string {
 	return utility.EncodeBase58Checksum(bytes[:length])
 }
 
-func getK() *big.Int {
+// Decodes a WIF string (as made by PrivateKey.WIF), returning the key along with whether its
+// public key should be compressed and whether it's for testnet.
+func ParseWIF(wif string) (key PrivateKey, compressed bool, testnet bool, err error) {
+
+	payload, err := utility.DecodeBase58Checksum(wif)
+	if err != nil {
+		return PrivateKey{}, false, false, err
+	}
+
+	switch payload[0] {
+	case 0x80:
+		testnet = false
+	case 0xef:
+		testnet = true
+	default:
+		return PrivateKey{}, false, false, fmt.Errorf("unknown WIF version byte 0x%02x", payload[0])
+	}
+
+	switch len(payload) {
+	case 33:
+		compressed = false
+	case 34:
+		if payload[33] != 0x01 {
+			return PrivateKey{}, false, false, fmt.Errorf("invalid WIF compression flag 0x%02x", payload[33])
+		}
+		compressed = true
+	default:
+		return PrivateKey{}, false, false, fmt.Errorf("WIF payload must be 33 or 34 bytes, not %v", len(payload))
+	}
+
+	secret := new(big.Int).SetBytes(payload[1:33])
+	if secret.Sign() == 0 || secret.Cmp(N) >= 0 {
+		return PrivateKey{}, false, false, errors.New("WIF secret must be in the range [1, N-1]")
+	}
 
-	r := utility.RandomData(32)
-	i := new(big.Int)
-	i.SetBytes(r)
-	return i
+	return NewPrivateKey(secret), compressed, testnet, nil
 }
@@ -33,6 +33,75 @@ func TestPrivateKeyWIF(t *testing.T) {
 	}
 }
 
+func TestPrivateKeyWIFShortSecret(t *testing.T) {
+
+	// A secret of 1 is 31 zero bytes followed by 0x01.
+	if !WIFTestCase(big.NewInt(1), false, false, "5HpHagT65TZzG1PH3CSu63k8DbpvD8s5ip4nEB3kEsreAnchuDf") {
+		t.Error()
+	}
+	if !WIFTestCase(big.NewInt(1), true, false, "KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoWn") {
+		t.Error()
+	}
+}
+
+func TestParseWIF(t *testing.T) {
+
+	testCases := []struct {
+		wif        string
+		secret     string
+		compressed bool
+		testnet    bool
+	}{
+		{"L5oLkpV3aqBJ4BgssVAsax1iRa77G5CVYnv9adQ6Z87te7TyUdSC", "ffffffffffffff80000000000000000000000000000000000000000000000000", true, false},
+		{"93XfLeifX7Jx7n7ELGMAf1SUR6f9kgQs8Xke8WStMwUtrDucMzn", "fffffffffffffe00000000000000000000000000000000000000000000000000", false, true},
+		{"cNYfWuhDpbNM1JWc3c6JTrtrFVxU4AGhUKgw5f93NP2QaBqmxKkg", "1cca23de92fd1862fb5b76e5f4f50eb082165e5191e116c18ed1a6b24be6a53f", true, true},
+		{"5HpHagT65TZzG1PH3CSu63k8DbpvD8s5ip4nEB3kEsreAnchuDf", "01", false, false},
+		{"KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoWn", "01", true, false},
+	}
+
+	for _, testCase := range testCases {
+		key, compressed, testnet, err := ecc.ParseWIF(testCase.wif)
+		if err != nil {
+			t.Errorf("Failed to parse %v: %v", testCase.wif, err)
+			continue
+		}
+
+		if key.Secret.Cmp(utility.HexStringToBigInt(testCase.secret)) != 0 {
+			t.Errorf("Expected secret %v, got %x", testCase.secret, key.Secret)
+		}
+		if compressed != testCase.compressed || testnet != testCase.testnet {
+			t.Errorf("Expected compressed=%v testnet=%v, got %v %v", testCase.compressed, testCase.testnet, compressed, testnet)
+		}
+
+		if wif := key.WIF(compressed, testnet); wif != testCase.wif {
+			t.Errorf("Round trip gave %v, expected %v", wif, testCase.wif)
+		}
+	}
+}
+
+func TestParseWIFInvalid(t *testing.T) {
+
+	secret := make([]byte, 32)
+	secret[31] = 1
+
+	testCases := map[string]string{
+		"bad checksum":     "KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoWo",
+		"bad character":    "KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoW0",
+		"empty":            "",
+		"wrong version":    utility.EncodeBase58Checksum(append([]byte{0x00}, secret...)),
+		"compression flag": utility.EncodeBase58Checksum(append(append([]byte{0x80}, secret...), 0x02)),
+		"too short":        utility.EncodeBase58Checksum(append([]byte{0x80}, secret[1:]...)),
+		"zero secret":      utility.EncodeBase58Checksum(append([]byte{0x80}, make([]byte, 32)...)),
+		"secret above N":   utility.EncodeBase58Checksum(append([]byte{0x80}, ecc.N.Bytes()...)),
+	}
+
+	for name, wif := range testCases {
+		if _, _, _, err := ecc.ParseWIF(wif); err == nil {
+			t.Errorf("Expected an error for %v", name)
+		}
+	}
+}
+
 func WIFTestCase(secret *big.Int, compressed bool, testnet bool, expectedWif string) bool {
 
 	pk := ecc.NewPrivateKey(secret)
@@ -40,3 +109,80 @@ func WIFTestCase(secret *big.Int, compressed bool, testnet bool, expectedWif str
 
 	return wif == expectedWif
 }
+
+func TestSignRFC6979(t *testing.T) {
+
+	// Test vectors shared by Trezor and CoreBitcoin (key, message, r, s)
+	vectors := [][4]string{
+		{"cca9fbcc1b41e5a95d369eaa6ddcff73b61a4efaa279cfc6567e8daa39cbaf50", "sample",
+			"af340daf02cc15c8d5d08d7735dfe6b98a474ed373bdb5fbecf7571be52b3842", "5009fb27f37034a9b24b707b7c6b79ca23ddef9e25f7282e8a797efe53a8f124"},
+		{"0000000000000000000000000000000000000000000000000000000000000001", "Satoshi Nakamoto",
+			"934b1ea10a4b3c1757e2b0c017d0b6143ce3c9a7e6a4a49860d7a6ab210ee3d8", "2442ce9d2b916064108014783e923ec36b49743e2ffa1c4496f01a512aafd9e5"},
+		{"fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364140", "Satoshi Nakamoto",
+			"fd567d121db66e382991534ada77a6bd3106f0a1098c231e47993447cd6af2d0", "6b39cd0eb1bc8603e159ef5c20a5c8ad685a45b06ce9bebed3f153d10d93bed5"},
+		{"f8b8af8ce3c7cca5e300d33939540c10d45ce001b8f252bfbc57ba0342904181", "Alan Turing",
+			"7063ae83e7f62bbb171798131b4a0564b956930092b33b07b395615d9ec7e15c", "58dfcc1e00a35e1572f366ffe34ba0fc47db1e7189759b9fb233c5b05ab388ea"},
+		{"0000000000000000000000000000000000000000000000000000000000000001", "All those moments will be lost in time, like tears in rain. Time to die...",
+			"8600dbd41e348fe5c9465ab92d23e3db8b98b873beecd930736488696438cb6b", "547fe64427496db33bf66019dacbf0039c04199abb0122918601db38a72cfc21"},
+		{"e91671c46231f833a6406ccbea0e3e392c76c167bac1cb013f6f1013980455c2", "There is a computer disease that anybody who works with computers knows about. It's a very serious disease and it interferes completely with the work. The trouble with computers is that you 'play' with them!",
+			"b552edd27580141f3b2a5463048cb7cd3e047b97c9f98076c32dbdf85a68718b", "279fa72dd19bfae05577e06c7c0c1900c371fcd5893f7e1d56a37d30174671f6"},
+	}
+
+	for _, v := range vectors {
+		pk := ecc.NewPrivateKey(utility.HexStringToBigInt(v[0]))
+		hash := new(big.Int).SetBytes(utility.Sha256([]byte(v[1])))
+		expected := ecc.NewSignature(utility.HexStringToBigInt(v[2]), utility.HexStringToBigInt(v[3]))
+
+		sig := pk.Sign(hash)
+		if !sig.Equals(&expected) {
+			t.Errorf("Unexpected signature for %q", v[1])
+		}
+
+		pub := ecc.G.ScalarMultiply(pk.Secret)
+		if !pub.Verify(hash, sig) {
+			t.Errorf("Signature for %q didn't verify", v[1])
+		}
+	}
+}
+
+func TestSignWithEntropy(t *testing.T) {
+
+	pk := ecc.NewPrivateKey(big.NewInt(12345))
+	hash := new(big.Int).SetBytes(utility.Hash256([]byte("Programming Bitcoin!")))
+	pub := ecc.G.ScalarMultiply(pk.Secret)
+
+	sig1 := pk.Sign(hash)
+	sig2 := pk.Sign(hash)
+	if !sig1.Equals(&sig2) {
+		t.Error()
+	}
+
+	entropy := make([]byte, 32)
+	entropy[0] = 0x01
+	sig3 := pk.SignWithEntropy(hash, entropy)
+	if sig1.Equals(&sig3) {
+		t.Error()
+	}
+
+	if !pub.Verify(hash, sig3) {
+		t.Error()
+	}
+
+	sig4 := pk.SignWithEntropy(hash, entropy)
+	if !sig3.Equals(&sig4) {
+		t.Error()
+	}
+}
+
+func TestPublicKeyMatchesScalarMultiply(t *testing.T) {
+
+	nMinus1 := new(big.Int).Sub(ecc.N, big.NewInt(1))
+	for _, secret := range []*big.Int{big.NewInt(1), big.NewInt(12345), utility.HexStringToBigInt("b7e151628aed2a6abf7158809cf4f3c762e7160f38b4da56a784d9045190cfef"), nMinus1} {
+		key := ecc.NewPrivateKey(secret)
+		pub := key.PublicKey()
+		expected := ecc.G.ScalarMultiply(secret)
+		if !pub.Equals(&expected) {
+			t.Errorf("Public key of %x doesn't match", secret)
+		}
+	}
+}
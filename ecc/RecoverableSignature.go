@@ -0,0 +1,115 @@
+package ecc
+
+import (
+	"errors"
+	"math/big"
+)
+
+// An ECDSA signature that carries enough extra information (the recovery id) to
+// reconstruct the public key that created it from just the signature and the hash.
+type RecoverableSignature struct {
+	Signature
+	RecoveryId byte // 0-3: bit 0 is the parity of R.y, bit 1 means R.x was >= N
+}
+
+// The header byte of the compact encoding is 27 + recovery id, plus 4 for compressed keys.
+const compactHeaderBase = 27
+
+func NewRecoverableSignature(r *big.Int, s *big.Int, recoveryId byte) RecoverableSignature {
+	return RecoverableSignature{Signature: Signature{R: r, S: s}, RecoveryId: recoveryId}
+}
+
+// Signs the hash (deterministically, like Sign) and keeps the recovery id.
+func (key *PrivateKey) SignRecoverable(hash *big.Int) RecoverableSignature {
+	sig, recoveryId := key.sign(hash, nil)
+	return RecoverableSignature{Signature: sig, RecoveryId: recoveryId}
+}
+
+// The 65 byte compact encoding: [header] [R (32 bytes)] [S (32 bytes)]. Whether the
+// key is compressed is recorded in the header so the recovered key can be serialized the same way.
+func (sig *RecoverableSignature) SerializeCompact(compressed bool) []byte {
+	buffer := make([]byte, 65)
+
+	buffer[0] = compactHeaderBase + sig.RecoveryId
+	if compressed {
+		buffer[0] += 4
+	}
+
+	fillBufferWithIntBytes(buffer[1:33], sig.R, false)
+	fillBufferWithIntBytes(buffer[33:], sig.S, false)
+	return buffer
+}
+
+// Parses a 65 byte compact signature, returning the signature and whether the key was compressed.
+func ParseCompactSignature(buffer []byte) (RecoverableSignature, bool, error) {
+	if len(buffer) != 65 {
+		return RecoverableSignature{}, false, errors.New("compact signature must be 65 bytes")
+	}
+
+	header := buffer[0]
+	if header < compactHeaderBase || header >= compactHeaderBase+8 {
+		return RecoverableSignature{}, false, errors.New("compact signature has an invalid header byte")
+	}
+
+	header -= compactHeaderBase
+	compressed := header >= 4
+
+	r := new(big.Int).SetBytes(buffer[1:33])
+	s := new(big.Int).SetBytes(buffer[33:])
+
+	return NewRecoverableSignature(r, s, header&3), compressed, nil
+}
+
+// Recovers the public key that produced the signature over the hash.
+//
+// R is rebuilt from r and the recovery id, then Q = r^-1 * (s*R - z*G).
+func RecoverPublicKey(hash *big.Int, sig RecoverableSignature) (Point, error) {
+
+	if sig.RecoveryId > 3 {
+		return Point{}, errors.New("recovery id must be between 0 and 3")
+	}
+
+	if sig.R == nil || sig.S == nil || sig.R.Sign() <= 0 || sig.R.Cmp(N) >= 0 || sig.S.Sign() <= 0 || sig.S.Cmp(N) >= 0 {
+		return Point{}, errors.New("signature values must be in the range [1, N-1]")
+	}
+
+	// The x coordinate of R is r, or r + N if it was reduced when signing.
+	x := new(big.Int).Set(sig.R)
+	if sig.RecoveryId&2 != 0 {
+		x.Add(x, N)
+	}
+
+	R, err := liftX(x)
+	if err != nil {
+		return Point{}, err
+	}
+
+	// liftX picks the even y, so negate for odd ones.
+	if sig.RecoveryId&1 == 1 {
+		R = R.negate()
+	}
+
+	rInv := new(big.Int).ModInverse(sig.R, N)
+	u1 := ModMulPrime(new(big.Int).Sub(N, new(big.Int).Mod(hash, N)), rInv, N)
+	u2 := ModMulPrime(sig.S, rInv, N)
+
+	// Q = u1*G + u2*R
+	q := generatorMultiply(u1)
+	u2R := wnafMultiply(&R, u2)
+	q = q.add(&u2R, Secp256k1)
+
+	if q.isInfinity() {
+		return Point{}, errors.New("recovered public key is the point at infinity")
+	}
+
+	return q.toAffine(Secp256k1), nil
+}
+
+// Recovers the public key and checks that it verifies the signature.
+func (sig *RecoverableSignature) Verify(hash *big.Int, pub *Point) bool {
+	recovered, err := RecoverPublicKey(hash, *sig)
+	if err != nil {
+		return false
+	}
+	return recovered.Equals(pub) && pub.Verify(hash, sig.Signature)
+}
@@ -0,0 +1,98 @@
+package ecc_test
+
+import (
+	"bitcoin-go/ecc"
+	"bitcoin-go/utility"
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestRecoverPublicKey(t *testing.T) {
+
+	for i := 0; i < 20; i++ {
+		key := ecc.NewPrivateKey(new(big.Int).SetBytes(utility.RandomData(32)))
+		pub := ecc.G.ScalarMultiply(key.Secret)
+		hash := new(big.Int).SetBytes(utility.Hash256(utility.RandomData(32)))
+
+		sig := key.SignRecoverable(hash)
+
+		// The plain signature must be unchanged.
+		plain := key.Sign(hash)
+		if !plain.Equals(&sig.Signature) {
+			t.Fatalf("Recoverable signature differs from the regular one")
+		}
+
+		recovered, err := ecc.RecoverPublicKey(hash, sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !recovered.Equals(&pub) {
+			t.Errorf("Recovered the wrong key for %x", key.Secret)
+		}
+		if !sig.Verify(hash, &pub) {
+			t.Errorf("Recoverable signature didn't verify")
+		}
+
+		// Flipping the parity gives a different (but still valid looking) key.
+		flipped := ecc.NewRecoverableSignature(sig.R, sig.S, sig.RecoveryId^1)
+		other, err := ecc.RecoverPublicKey(hash, flipped)
+		if err != nil || other.Equals(&pub) {
+			t.Errorf("Flipped recovery id recovered the same key")
+		}
+	}
+}
+
+func TestRecoverPublicKeyInvalid(t *testing.T) {
+	hash := new(big.Int).SetBytes(utility.Hash256([]byte("invalid")))
+
+	testCases := []ecc.RecoverableSignature{
+		ecc.NewRecoverableSignature(big.NewInt(0), big.NewInt(1), 0),
+		ecc.NewRecoverableSignature(big.NewInt(1), big.NewInt(0), 0),
+		ecc.NewRecoverableSignature(ecc.N, big.NewInt(1), 0),
+		ecc.NewRecoverableSignature(big.NewInt(1), big.NewInt(1), 4),
+		// r + N is larger than P, so there's no matching R.
+		ecc.NewRecoverableSignature(new(big.Int).Sub(ecc.N, big.NewInt(1)), big.NewInt(1), 2),
+	}
+
+	for i, sig := range testCases {
+		if _, err := ecc.RecoverPublicKey(hash, sig); err == nil {
+			t.Errorf("Case %v: expected an error", i)
+		}
+	}
+}
+
+func TestCompactSignature(t *testing.T) {
+	key := ecc.NewPrivateKey(big.NewInt(0xdeadbeef))
+	hash := new(big.Int).SetBytes(utility.Hash256([]byte("compact")))
+	sig := key.SignRecoverable(hash)
+
+	for _, compressed := range []bool{true, false} {
+		buffer := sig.SerializeCompact(compressed)
+		if len(buffer) != 65 {
+			t.Fatalf("Wrong length %v", len(buffer))
+		}
+
+		parsed, parsedCompressed, err := ecc.ParseCompactSignature(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsedCompressed != compressed || parsed.RecoveryId != sig.RecoveryId || !parsed.Equals(&sig.Signature) {
+			t.Errorf("Compact round trip failed (compressed: %v)", compressed)
+		}
+		if !bytes.Equal(parsed.SerializeCompact(compressed), buffer) {
+			t.Errorf("Re-serialization differs")
+		}
+	}
+
+	buffer := sig.SerializeCompact(true)
+	for _, header := range []byte{26, 35} {
+		buffer[0] = header
+		if _, _, err := ecc.ParseCompactSignature(buffer); err == nil {
+			t.Errorf("Header %v should be rejected", header)
+		}
+	}
+	if _, _, err := ecc.ParseCompactSignature(buffer[:64]); err == nil {
+		t.Errorf("Short buffer should be rejected")
+	}
+}
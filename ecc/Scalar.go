@@ -0,0 +1,61 @@
+package ecc
+
+import (
+	"math/big"
+)
+
+// An integer mod N (the order of the secp256k1 group), stored as four 64 bit limbs in
+// Montgomery form. Every operation runs in constant time, so secrets and nonces should
+// be handled as Scalars rather than big.Ints.
+type Scalar struct {
+	n limbs
+}
+
+var scalarModulus *montgomeryModulus
+
+func NewScalar(x *big.Int) Scalar {
+	reduced := new(big.Int).Mod(x, N)
+	return Scalar{n: scalarModulus.toMontgomery(bigIntToLimbs(reduced))}
+}
+
+// Interprets 32 big-endian bytes as a scalar (reducing mod N if needed).
+func NewScalarFromBytes(b []byte) Scalar {
+	return Scalar{n: scalarModulus.toMontgomery(bytesToLimbs(b))}
+}
+
+func (s Scalar) Add(t Scalar) Scalar {
+	return Scalar{n: scalarModulus.add(s.n, t.n)}
+}
+
+func (s Scalar) Sub(t Scalar) Scalar {
+	return Scalar{n: scalarModulus.sub(s.n, t.n)}
+}
+
+func (s Scalar) Mul(t Scalar) Scalar {
+	return Scalar{n: scalarModulus.mul(s.n, t.n)}
+}
+
+func (s Scalar) Negate() Scalar {
+	return Scalar{n: scalarModulus.sub(limbs{}, s.n)}
+}
+
+// The multiplicative inverse. The inverse of zero is zero.
+func (s Scalar) Inverse() Scalar {
+	return Scalar{n: scalarModulus.inverse(s.n)}
+}
+
+func (s Scalar) Equals(t Scalar) bool {
+	return limbsEqual(s.n, t.n)
+}
+
+func (s Scalar) IsZero() bool {
+	return limbsIsZero(s.n)
+}
+
+func (s Scalar) Bytes() []byte {
+	return limbsToBytes(scalarModulus.fromMontgomery(s.n))
+}
+
+func (s Scalar) BigInt() *big.Int {
+	return new(big.Int).SetBytes(s.Bytes())
+}
@@ -0,0 +1,188 @@
+package ecc
+
+import (
+	"bitcoin-go/utility"
+	"errors"
+	"math/big"
+)
+
+// BIP340 Schnorr signatures. Public keys are "x-only": only the x coordinate is serialized
+// and the y coordinate is implicitly the even one.
+
+type SchnorrSignature struct {
+	R *big.Int // The x coordinate of the nonce point
+	S *big.Int
+}
+
+func NewSchnorrSignature(r *big.Int, s *big.Int) SchnorrSignature {
+	return SchnorrSignature{R: r, S: s}
+}
+
+func ParseSchnorrSignature(buffer []byte) (SchnorrSignature, error) {
+	if len(buffer) != 64 {
+		return SchnorrSignature{}, errors.New("schnorr signature must be 64 bytes")
+	}
+
+	r := new(big.Int).SetBytes(buffer[:32])
+	s := new(big.Int).SetBytes(buffer[32:])
+
+	if r.Cmp(P) >= 0 {
+		return SchnorrSignature{}, errors.New("schnorr signature r value exceeds the field size")
+	}
+	if s.Cmp(N) >= 0 {
+		return SchnorrSignature{}, errors.New("schnorr signature s value exceeds the curve order")
+	}
+
+	return SchnorrSignature{R: r, S: s}, nil
+}
+
+func (sig *SchnorrSignature) Serialize() []byte {
+	buffer := make([]byte, 64)
+	fillBufferWithIntBytes(buffer[:32], sig.R, false)
+	fillBufferWithIntBytes(buffer[32:], sig.S, false)
+	return buffer
+}
+
+func (sig *SchnorrSignature) Equals(sig2 *SchnorrSignature) bool {
+	if sig2 == nil {
+		return false
+	}
+	return sig.R.Cmp(sig2.R) == 0 && sig.S.Cmp(sig2.S) == 0
+}
+
+// The 32 byte x-only serialization of the point.
+func (p *Point) ToXOnly() []byte {
+	buffer := make([]byte, 32)
+	fillBufferWithIntBytes(buffer, p.x, false)
+	return buffer
+}
+
+// Parses an x-only public key, picking the point with the even y coordinate.
+func ParseXOnly(buffer []byte) (Point, error) {
+	if len(buffer) != 32 {
+		return Point{}, errors.New("x-only public key must be 32 bytes")
+	}
+
+	x := new(big.Int).SetBytes(buffer)
+	return liftX(x)
+}
+
+func liftX(x *big.Int) (Point, error) {
+	if x.Cmp(P) >= 0 {
+		return Point{}, errors.New("x coordinate exceeds the field size")
+	}
+
+	alpha := ModAdd(ModPowInt(x, 3), B)
+	beta := ModSqrt(alpha)
+	if ModPowInt(beta, 2).Cmp(alpha) != 0 {
+		return Point{}, errors.New("x coordinate is not on the curve")
+	}
+
+	if !IsEven(beta) {
+		beta = ModSub(P, beta)
+	}
+
+	return Point{x: x, y: beta, curve: Secp256k1}, nil
+}
+
+func (p *Point) hasEvenY() bool {
+	return IsEven(p.y)
+}
+
+func (p *Point) negate() Point {
+	if p.x == nil {
+		return *p
+	}
+	prime := p.Curve().P
+	return Point{x: p.x, y: ModSubPrime(prime, p.y, prime), curve: p.curve}
+}
+
+// Signs an arbitrary length message. If auxRand is nil, 32 fresh random bytes are used.
+func (key *PrivateKey) SignSchnorr(msg []byte, auxRand []byte) (SchnorrSignature, error) {
+
+	if key.Secret.Sign() <= 0 || key.Secret.Cmp(N) >= 0 {
+		return SchnorrSignature{}, errors.New("secret must be in the range [1, N-1]")
+	}
+
+	if auxRand == nil {
+		auxRand = utility.RandomData(32)
+	} else if len(auxRand) != 32 {
+		return SchnorrSignature{}, errors.New("aux randomness must be 32 bytes")
+	}
+
+	// Use the secret that corresponds to the even-y public key.
+	d := NewScalar(key.Secret)
+	pub := G.ScalarMultiplyConstantTime(d)
+	if !pub.hasEvenY() {
+		d = d.Negate()
+	}
+
+	pubBytes := pub.ToXOnly()
+
+	// t = bytes(d) xor hash_BIP0340/aux(a)
+	t := utility.TaggedHash("BIP0340/aux", auxRand)
+	dBytes := d.Bytes()
+	for i := range t {
+		t[i] ^= dBytes[i]
+	}
+
+	rand := utility.TaggedHash("BIP0340/nonce", t, pubBytes, msg)
+	k := NewScalarFromBytes(rand)
+	if k.IsZero() {
+		return SchnorrSignature{}, errors.New("derived nonce is zero")
+	}
+
+	R := G.ScalarMultiplyConstantTime(k)
+	if !R.hasEvenY() {
+		k = k.Negate()
+	}
+
+	e := NewScalar(schnorrChallenge(R.ToXOnly(), pubBytes, msg))
+	s := k.Add(e.Mul(d)).BigInt()
+
+	sig := SchnorrSignature{R: R.x, S: s}
+
+	// Make sure we never hand out a signature that doesn't verify.
+	if !pub.VerifySchnorr(msg, sig) {
+		return SchnorrSignature{}, errors.New("created signature does not verify")
+	}
+
+	return sig, nil
+}
+
+// Verifies the signature against the x-only form of this point.
+func (p *Point) VerifySchnorr(msg []byte, sig SchnorrSignature) bool {
+	if p.x == nil || sig.R == nil || sig.S == nil {
+		return false
+	}
+
+	pub, err := liftX(p.x)
+	if err != nil {
+		return false
+	}
+
+	if sig.R.Cmp(P) >= 0 || sig.S.Cmp(N) >= 0 {
+		return false
+	}
+
+	rBytes := make([]byte, 32)
+	fillBufferWithIntBytes(rBytes, sig.R, false)
+	e := schnorrChallenge(rBytes, pub.ToXOnly(), msg)
+
+	// R = s*G - e*P
+	sG := G.ScalarMultiply(sig.S)
+	eP := pub.ScalarMultiply(e)
+	negEP := eP.negate()
+	R := sG.Add(&negEP)
+
+	if R.x == nil || !R.hasEvenY() {
+		return false
+	}
+
+	return R.x.Cmp(sig.R) == 0
+}
+
+func schnorrChallenge(r []byte, pub []byte, msg []byte) *big.Int {
+	e := new(big.Int).SetBytes(utility.TaggedHash("BIP0340/challenge", r, pub, msg))
+	return e.Mod(e, N)
+}
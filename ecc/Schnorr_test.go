@@ -0,0 +1,104 @@
+package ecc_test
+
+import (
+	"bitcoin-go/ecc"
+	"bitcoin-go/utility"
+	"bytes"
+	"encoding/csv"
+	"os"
+	"testing"
+)
+
+func TestSchnorrVectors(t *testing.T) {
+
+	file, err := os.Open("testdata/bip340_vectors.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Vectors 15 to 18 sign messages that aren't 32 bytes long.
+	if len(records) != 20 {
+		t.Fatalf("Expected 19 vectors, got %v", len(records)-1)
+	}
+
+	for _, record := range records[1:] {
+		index, secret, pubKey, auxRand, msg, sigBytes, result := record[0], record[1], record[2], record[3], record[4], record[5], record[6] == "TRUE"
+
+		if len(secret) > 0 {
+			pk := ecc.NewPrivateKey(utility.HexStringToBigInt(secret))
+			sig, err := pk.SignSchnorr(BytesFromHex(msg), BytesFromHex(auxRand))
+			if err != nil {
+				t.Errorf("Vector %v: %v", index, err)
+				continue
+			}
+
+			if !bytes.Equal(sig.Serialize(), BytesFromHex(sigBytes)) {
+				t.Errorf("Vector %v: unexpected signature %x", index, sig.Serialize())
+			}
+
+			pub := ecc.G.ScalarMultiply(pk.Secret)
+			if !bytes.Equal(pub.ToXOnly(), BytesFromHex(pubKey)) {
+				t.Errorf("Vector %v: unexpected public key", index)
+			}
+		}
+
+		if SchnorrVerifyTestCase(pubKey, msg, sigBytes) != result {
+			t.Errorf("Vector %v: expected verification result %v", index, result)
+		}
+	}
+}
+
+func TestSchnorrRoundTrip(t *testing.T) {
+
+	pk := ecc.NewPrivateKey(utility.HexStringToBigInt("1cca23de92fd1862fb5b76e5f4f50eb082165e5191e116c18ed1a6b24be6a53f"))
+	pub := ecc.G.ScalarMultiply(pk.Secret)
+	msg := []byte("Programming Bitcoin!")
+
+	// No aux randomness means fresh randomness, which must still verify.
+	sig, err := pk.SignSchnorr(msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !pub.VerifySchnorr(msg, sig) {
+		t.Error()
+	}
+
+	parsed, err := ecc.ParseSchnorrSignature(sig.Serialize())
+	if err != nil || !parsed.Equals(&sig) {
+		t.Error()
+	}
+
+	if pub.VerifySchnorr([]byte("Programming Bitcoin?"), sig) {
+		t.Error()
+	}
+
+	if _, err := pk.SignSchnorr(msg, make([]byte, 31)); err == nil {
+		t.Error()
+	}
+
+	if _, err := ecc.ParseSchnorrSignature(make([]byte, 63)); err == nil {
+		t.Error()
+	}
+}
+
+func SchnorrVerifyTestCase(pubKey string, msg string, sigBytes string) bool {
+
+	pub, err := ecc.ParseXOnly(BytesFromHex(pubKey))
+	if err != nil {
+		return false
+	}
+
+	sig, err := ecc.ParseSchnorrSignature(BytesFromHex(sigBytes))
+	if err != nil {
+		return false
+	}
+
+	return pub.VerifySchnorr(BytesFromHex(msg), sig)
+}
@@ -1,6 +1,8 @@
 package ecc
 
 import (
+	"errors"
+	"fmt"
 	"math/big"
 )
 
@@ -30,10 +32,10 @@ func (sig *Signature) ToDER() []byte {
 
 	var buffer []byte
 
-	rbytes, rzeros, rsign := analyzeBigInt(sig.R)
-	rauglen := len(rbytes) - rzeros + rsign
-	sbytes, szeros, ssign := analyzeBigInt(sig.S)
-	sauglen := len(sbytes) - szeros + ssign
+	rbytes, rsign := analyzeBigInt(sig.R)
+	rauglen := len(rbytes) + rsign
+	sbytes, ssign := analyzeBigInt(sig.S)
+	sauglen := len(sbytes) + ssign
 
 	// Create the buffer the correct size. It has three markers, three 1-byte lengths
 	// and then the augmented sizes of the R and S values
@@ -44,53 +46,113 @@ func (sig *Signature) ToDER() []byte {
 	buffer[1] = byte(rauglen + sauglen + 4)
 
 	// Fill in the R value.
-	index := 2 + fillInValue(buffer[2:], rbytes[rzeros:], rsign)
+	index := 2 + fillInValue(buffer[2:], rbytes, rsign)
 
 	// Fill in the S value.
-	fillInValue(buffer[index:], sbytes[szeros:], ssign)
+	fillInValue(buffer[index:], sbytes, ssign)
 
 	return buffer
 }
 
+// Parses a DER encoded signature. Panics on malformed input, use ParseDER for untrusted data.
 func NewSignatureFromDER(bytes []byte) Signature {
+	sig, err := ParseDER(bytes)
+	if err != nil {
+		panic(err)
+	}
+	return sig
+}
+
+// Parses a DER encoded signature (without a trailing sighash byte), enforcing the strict
+// encoding rules of BIP66:
+//
+//	0x30 [total-length] 0x02 [R-length] [R] 0x02 [S-length] [S]
+//
+// Both integers must be positive and minimally encoded.
+func ParseDER(bytes []byte) (Signature, error) {
+
+	// Smallest possible is 2 one byte integers (8 bytes), largest is 2 33 byte integers (72 bytes).
+	if len(bytes) < 8 || len(bytes) > 72 {
+		return Signature{}, fmt.Errorf("DER signature has an invalid length of %v", len(bytes))
+	}
 
-	// TODO: Fix this to return either (Signature, error) or (Signature, bool) to handle errors gracefully.
 	if bytes[0] != 0x30 {
-		panic("Not a DER format")
+		return Signature{}, errors.New("DER signature doesn't start with a compound marker")
 	}
 
-	startOfR := 2
-	startOfS := 4 + (int)(bytes[3])
+	if int(bytes[1]) != len(bytes)-2 {
+		return Signature{}, errors.New("DER signature length doesn't match the data")
+	}
 
-	r := extractBigIntFromBytes(bytes[startOfR:])
-	s := extractBigIntFromBytes(bytes[startOfS:])
+	rLen := int(bytes[3])
+	if 5+rLen >= len(bytes) {
+		return Signature{}, errors.New("DER signature R length is too long")
+	}
+
+	sLen := int(bytes[5+rLen])
+	if rLen+sLen+6 != len(bytes) {
+		return Signature{}, errors.New("DER signature R and S lengths don't match the data")
+	}
 
-	return NewSignature(r, s)
+	r, err := parseDERInteger(bytes[2 : 4+rLen])
+	if err != nil {
+		return Signature{}, err
+	}
+
+	s, err := parseDERInteger(bytes[4+rLen:])
+	if err != nil {
+		return Signature{}, err
+	}
+
+	return NewSignature(r, s), nil
 }
 
-func analyzeBigInt(i *big.Int) ([]byte, int, int) {
+func parseDERInteger(buffer []byte) (*big.Int, error) {
+
+	if buffer[0] != 0x02 {
+		return nil, errors.New("DER integer doesn't start with an integer marker")
+	}
+
+	len := int(buffer[1])
+	if len == 0 {
+		return nil, errors.New("DER integer has zero length")
+	}
+
+	value := buffer[2 : 2+len]
 
-	// Get the bytes
-	var buffer []byte = i.Bytes()
+	if value[0]&0x80 != 0 {
+		return nil, errors.New("DER integer is negative")
+	}
 
-	// Track the leading zeros
-	zeros := 0
-	for zeros = 0; buffer[zeros] == 0x00; zeros++ {
+	// A leading zero byte is only allowed when it's needed to keep the number positive.
+	if len > 1 && value[0] == 0x00 && value[1]&0x80 == 0 {
+		return nil, errors.New("DER integer has an unnecessary leading zero")
+	}
+
+	return new(big.Int).SetBytes(value), nil
+}
+
+// Returns the minimal big-endian encoding of the integer and whether a zero byte has to be
+// prepended to keep it from being interpreted as negative.
+func analyzeBigInt(i *big.Int) ([]byte, int) {
+
+	buffer := i.Bytes()
+	if len(buffer) == 0 {
+		buffer = []byte{0x00}
 	}
 
-	// See if we need a byte for a negative number
 	signByte := 0
-	if i.Sign() == -1 {
+	if buffer[0]&0x80 != 0 {
 		signByte = 1
 	}
 
-	return buffer, zeros, signByte
+	return buffer, signByte
 }
 
 func fillInValue(dest []byte, bytes []byte, sign int) int {
 
 	dest[0] = 0x02
-	dest[1] = byte(len(bytes))
+	dest[1] = byte(len(bytes) + sign)
 	if sign > 0 {
 		dest[2] = 0x00
 	}
@@ -98,24 +160,3 @@ func fillInValue(dest []byte, bytes []byte, sign int) int {
 
 	return len(bytes) + 2 + sign
 }
-
-func extractBigIntFromBytes(buffer []byte) *big.Int {
-
-	if buffer[0] != 0x02 {
-		panic("Not a valid DER encoding.")
-	}
-
-	len := (int)(buffer[1])
-	intOffset := 2
-
-	// Account for the padding of a negative number
-	if buffer[intOffset] == 0x00 {
-		intOffset += 1
-		len -= 1
-	}
-
-	i := new(big.Int)
-	i.SetBytes(buffer[intOffset : intOffset+len])
-
-	return i
-}
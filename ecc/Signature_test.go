@@ -3,6 +3,7 @@ package ecc_test
 import (
 	"bitcoin-go/ecc"
 	"bitcoin-go/utility"
+	"encoding/hex"
 	"testing"
 )
 
@@ -19,3 +20,51 @@ func TestDER(t *testing.T) {
 		t.Errorf("Didn't work")
 	}
 }
+
+func TestDERHighBitPadding(t *testing.T) {
+	// Both values have their high bit set, so each needs a zero byte in front.
+	r := utility.HexStringToBigInt("8d8d1c87e51d0d441be8b3dd5b05c8795b48875dffe00b7ffcfac23010d3a395")
+	s := utility.HexStringToBigInt("f8342ceff8935ededd102dd876ffd6ba72d6a427a3edb13d26eb0781cb423c4a")
+	sig := ecc.NewSignature(r, s)
+
+	der := sig.ToDER()
+	if len(der) != 72 || der[3] != 33 || der[4] != 0x00 {
+		t.Errorf("R wasn't padded: %x", der)
+	}
+
+	sig2, err := ecc.ParseDER(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sig2.Equals(&sig) {
+		t.Errorf("Round trip failed")
+	}
+}
+
+func TestParseDERStrict(t *testing.T) {
+	valid := "3044022016b7cefa0a3bb26d7fc2b7ffd3cd7ae5c3b4bd03c3e7c58d23d7d3dc8c70dd8d02203d2b2cc9f84e13fe5d46ccf5e5a0d37ab3f0edc9cae6fd4c1ce7ea7cd8d3c6e6"
+	buffer, _ := hex.DecodeString(valid)
+	if _, err := ecc.ParseDER(buffer); err != nil {
+		t.Errorf("Valid signature rejected: %v", err)
+	}
+
+	invalid := map[string]string{
+		"too short":           "300602010102",
+		"wrong marker":        "3144022016b7cefa0a3bb26d7fc2b7ffd3cd7ae5c3b4bd03c3e7c58d23d7d3dc8c70dd8d02203d2b2cc9f84e13fe5d46ccf5e5a0d37ab3f0edc9cae6fd4c1ce7ea7cd8d3c6e6",
+		"wrong total length":  "3045022016b7cefa0a3bb26d7fc2b7ffd3cd7ae5c3b4bd03c3e7c58d23d7d3dc8c70dd8d02203d2b2cc9f84e13fe5d46ccf5e5a0d37ab3f0edc9cae6fd4c1ce7ea7cd8d3c6e6",
+		"R integer marker":    "3044032016b7cefa0a3bb26d7fc2b7ffd3cd7ae5c3b4bd03c3e7c58d23d7d3dc8c70dd8d02203d2b2cc9f84e13fe5d46ccf5e5a0d37ab3f0edc9cae6fd4c1ce7ea7cd8d3c6e6",
+		"R length too long":   "3044024116b7cefa0a3bb26d7fc2b7ffd3cd7ae5c3b4bd03c3e7c58d23d7d3dc8c70dd8d02203d2b2cc9f84e13fe5d46ccf5e5a0d37ab3f0edc9cae6fd4c1ce7ea7cd8d3c6e6",
+		"S length mismatch":   "3044022016b7cefa0a3bb26d7fc2b7ffd3cd7ae5c3b4bd03c3e7c58d23d7d3dc8c70dd8d02213d2b2cc9f84e13fe5d46ccf5e5a0d37ab3f0edc9cae6fd4c1ce7ea7cd8d3c6e6",
+		"negative R":          "3044022096b7cefa0a3bb26d7fc2b7ffd3cd7ae5c3b4bd03c3e7c58d23d7d3dc8c70dd8d02203d2b2cc9f84e13fe5d46ccf5e5a0d37ab3f0edc9cae6fd4c1ce7ea7cd8d3c6e6",
+		"unnecessary padding": "304502210016b7cefa0a3bb26d7fc2b7ffd3cd7ae5c3b4bd03c3e7c58d23d7d3dc8c70dd8d02203d2b2cc9f84e13fe5d46ccf5e5a0d37ab3f0edc9cae6fd4c1ce7ea7cd8d3c6e6",
+		"zero length S":       "3008020101020001",
+		"trailing sighash":    "3044022016b7cefa0a3bb26d7fc2b7ffd3cd7ae5c3b4bd03c3e7c58d23d7d3dc8c70dd8d02203d2b2cc9f84e13fe5d46ccf5e5a0d37ab3f0edc9cae6fd4c1ce7ea7cd8d3c6e601",
+	}
+
+	for name, encoded := range invalid {
+		buffer, _ := hex.DecodeString(encoded)
+		if _, err := ecc.ParseDER(buffer); err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
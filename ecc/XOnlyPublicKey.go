@@ -0,0 +1,143 @@
+package ecc
+
+import (
+	"bitcoin-go/utility"
+	"errors"
+	"math/big"
+)
+
+// A BIP340 public key: only the x coordinate is kept, the y coordinate is implicitly even.
+type XOnlyPublicKey struct {
+	point Point
+}
+
+// Converts the point to its x-only form. The flag reports whether the point had an odd y, and
+// so had to be negated; whoever holds the secret has to negate it to match.
+func (p *Point) ToXOnlyPublicKey() (XOnlyPublicKey, bool, error) {
+	if p.x == nil {
+		return XOnlyPublicKey{}, false, errors.New("point at infinity has no x-only form")
+	}
+
+	if p.hasEvenY() {
+		return XOnlyPublicKey{point: *p}, false, nil
+	}
+	return XOnlyPublicKey{point: p.negate()}, true, nil
+}
+
+func ParseXOnlyPublicKey(buffer []byte) (XOnlyPublicKey, error) {
+	point, err := ParseXOnly(buffer)
+	if err != nil {
+		return XOnlyPublicKey{}, err
+	}
+	return XOnlyPublicKey{point: point}, nil
+}
+
+// The 32 byte serialization.
+func (k *XOnlyPublicKey) Serialize() []byte {
+	return k.point.ToXOnly()
+}
+
+// The full point, which always has an even y.
+func (k *XOnlyPublicKey) Point() Point {
+	return k.point
+}
+
+func (k *XOnlyPublicKey) Equals(k2 *XOnlyPublicKey) bool {
+	return k2 != nil && k.point.Equals(&k2.point)
+}
+
+func (k *XOnlyPublicKey) VerifySchnorr(msg []byte, sig SchnorrSignature) bool {
+	return k.point.VerifySchnorr(msg, sig)
+}
+
+// Computes Q = P + t*G for the (even y) point of the key. Returns Q in x-only form and the
+// parity of Q, which script path spends need for the control block.
+func (k *XOnlyPublicKey) TweakAdd(tweak []byte) (XOnlyPublicKey, bool, error) {
+	q, err := k.point.TweakAdd(tweak)
+	if err != nil {
+		return XOnlyPublicKey{}, false, err
+	}
+	return q.ToXOnlyPublicKey()
+}
+
+// Computes P + t*G.
+func (p *Point) TweakAdd(tweak []byte) (Point, error) {
+	t, err := parseTweak(tweak)
+	if err != nil {
+		return Point{}, err
+	}
+
+	tG := G.ScalarMultiply(t)
+	q := p.Add(&tG)
+	if q.x == nil {
+		return Point{}, errors.New("tweaked key is the point at infinity")
+	}
+	return q, nil
+}
+
+// Computes d + t, the secret for Point.TweakAdd.
+func (key *PrivateKey) TweakAdd(tweak []byte) (PrivateKey, error) {
+	t, err := parseTweak(tweak)
+	if err != nil {
+		return PrivateKey{}, err
+	}
+
+	if key.Secret.Sign() <= 0 || key.Secret.Cmp(N) >= 0 {
+		return PrivateKey{}, errors.New("secret must be in the range [1, N-1]")
+	}
+
+	d := NewScalar(key.Secret).Add(NewScalar(t))
+	if d.IsZero() {
+		return PrivateKey{}, errors.New("tweaked secret is zero")
+	}
+	return NewPrivateKey(d.BigInt()), nil
+}
+
+// The secret for XOnlyPublicKey.TweakAdd: the secret is negated first if its point has an odd y.
+func (key *PrivateKey) TweakAddXOnly(tweak []byte) (PrivateKey, error) {
+	if key.Secret.Sign() <= 0 || key.Secret.Cmp(N) >= 0 {
+		return PrivateKey{}, errors.New("secret must be in the range [1, N-1]")
+	}
+
+	d := NewScalar(key.Secret)
+	pub := G.ScalarMultiplyConstantTime(d)
+	if !pub.hasEvenY() {
+		d = d.Negate()
+	}
+
+	even := NewPrivateKey(d.BigInt())
+	return even.TweakAdd(tweak)
+}
+
+func parseTweak(tweak []byte) (*big.Int, error) {
+	if len(tweak) != 32 {
+		return nil, errors.New("tweak must be 32 bytes")
+	}
+
+	t := new(big.Int).SetBytes(tweak)
+	if t.Cmp(N) >= 0 {
+		return nil, errors.New("tweak exceeds the curve order")
+	}
+	return t, nil
+}
+
+// The BIP341 tweak hash_TapTweak(internal key || merkle root). A nil merkle root means the
+// output has no script tree and can only be spent with the key.
+func TaprootTweak(internalKey *XOnlyPublicKey, merkleRoot []byte) []byte {
+	return utility.TaggedHash("TapTweak", internalKey.Serialize(), merkleRoot)
+}
+
+// The key that goes in a Taproot output (OP_1 <output key>), along with its parity.
+func TaprootOutputKey(internalKey *XOnlyPublicKey, merkleRoot []byte) (XOnlyPublicKey, bool, error) {
+	return internalKey.TweakAdd(TaprootTweak(internalKey, merkleRoot))
+}
+
+// The secret that signs for the Taproot output key when spending with the key path.
+func (key *PrivateKey) TaprootTweak(merkleRoot []byte) (PrivateKey, error) {
+	pub := key.PublicKey()
+	internalKey, _, err := pub.ToXOnlyPublicKey()
+	if err != nil {
+		return PrivateKey{}, err
+	}
+	return key.TweakAddXOnly(TaprootTweak(&internalKey, merkleRoot))
+}
@@ -0,0 +1,145 @@
+package ecc_test
+
+import (
+	"bitcoin-go/ecc"
+	"bitcoin-go/utility"
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+// From BIP341's wallet-test-vectors.json
+func TestTaprootOutputKeyVectors(t *testing.T) {
+
+	testCases := []struct {
+		internalKey string
+		merkleRoot  string
+		tweak       string
+		outputKey   string
+	}{
+		{"d6889cb081036e0faefa3a35157ad71086b123b2b144b649798b494c300a961d", "", "b86e7be8f39bab32a6f2c0443abbc210f0edac0e2c53d501b36b64437d9c6c70", "53a1f6e454df1aa2776a2814a721372d6258050de330b3c6d10ee8f4e0dda343"},
+		{"187791b6f712a8ea41c8ecdd0ee77fab3e85263b37e1ec18a3651926b3a6cf27", "5b75adecf53548f3ec6ad7d78383bf84cc57b55a3127c72b9a2481752dd88b21", "cbd8679ba636c1110ea247542cfbd964131a6be84f873f7f3b62a777528ed001", "147c9c57132f6e7ecddba9800bb0c4449251c92a1e60371ee77557b6620f3ea3"},
+	}
+
+	for _, testCase := range testCases {
+		buffer, _ := hex.DecodeString(testCase.internalKey)
+		internalKey, err := ecc.ParseXOnlyPublicKey(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var merkleRoot []byte
+		if testCase.merkleRoot != "" {
+			merkleRoot, _ = hex.DecodeString(testCase.merkleRoot)
+		}
+
+		if tweak := hex.EncodeToString(ecc.TaprootTweak(&internalKey, merkleRoot)); tweak != testCase.tweak {
+			t.Errorf("Expected tweak %v, got %v", testCase.tweak, tweak)
+		}
+
+		outputKey, _, err := ecc.TaprootOutputKey(&internalKey, merkleRoot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := hex.EncodeToString(outputKey.Serialize()); actual != testCase.outputKey {
+			t.Errorf("Expected output key %v, got %v", testCase.outputKey, actual)
+		}
+	}
+}
+
+func TestTaprootTweakPrivateKeyVector(t *testing.T) {
+
+	key := ecc.NewPrivateKey(utility.HexStringToBigInt("6b973d88838f27366ed61c9ad6367663045cb456e28335c109e30717ae0c6baa"))
+
+	tweaked, err := key.TaprootTweak(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := utility.HexStringToBigInt("2405b971772ad26915c8dcdf10f238753a9b837e5f8e6a86fd7c0cce5b7296d9")
+	if tweaked.Secret.Cmp(expected) != 0 {
+		t.Errorf("Expected %x, got %x", expected, tweaked.Secret)
+	}
+}
+
+func TestTweakAddConsistency(t *testing.T) {
+
+	for i := 0; i < 10; i++ {
+		key := ecc.NewPrivateKey(new(big.Int).SetBytes(utility.RandomData(32)))
+		pub := ecc.G.ScalarMultiply(key.Secret)
+		tweak := utility.Hash256(utility.RandomData(32))
+
+		// Plain tweaks: (d + t) * G == P + t * G
+		tweakedKey, err := key.TweakAdd(tweak)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tweakedPub, err := pub.TweakAdd(tweak)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := ecc.G.ScalarMultiply(tweakedKey.Secret)
+		if !expected.Equals(&tweakedPub) {
+			t.Errorf("Plain tweak mismatch")
+		}
+
+		// X-only tweaks sign for the tweaked x-only key.
+		xOnly, _, err := pub.ToXOnlyPublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputKey, _, err := xOnly.TweakAdd(tweak)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signingKey, err := key.TweakAddXOnly(tweak)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msg := utility.Hash256([]byte("taproot"))
+		sig, err := signingKey.SignSchnorr(msg, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !outputKey.VerifySchnorr(msg, sig) {
+			t.Errorf("Signature from the tweaked secret didn't verify")
+		}
+	}
+}
+
+func TestXOnlyPublicKey(t *testing.T) {
+
+	// Find a point with an odd y coordinate.
+	p := ecc.G
+	for k := int64(2); p.ToSEC(true)[0] != 0x03; k++ {
+		p = ecc.G.ScalarMultiply(big.NewInt(k))
+	}
+	xOnly, negated, err := p.ToXOnlyPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !negated {
+		t.Errorf("Expected the point to be negated")
+	}
+
+	parsed, err := ecc.ParseXOnlyPublicKey(xOnly.Serialize())
+	if err != nil || !parsed.Equals(&xOnly) {
+		t.Errorf("Round trip failed")
+	}
+
+	if !bytes.Equal(xOnly.Serialize(), p.ToSEC(true)[1:]) {
+		t.Errorf("x coordinate changed")
+	}
+
+	infinity := ecc.G.ScalarMultiply(ecc.N)
+	if _, _, err := infinity.ToXOnlyPublicKey(); err == nil {
+		t.Errorf("Point at infinity should be rejected")
+	}
+
+	tooLarge, _ := hex.DecodeString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141")
+	if _, err := ecc.G.TweakAdd(tooLarge); err == nil {
+		t.Errorf("Tweak equal to N should be rejected")
+	}
+}
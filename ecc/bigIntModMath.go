@@ -4,20 +4,28 @@ import (
 	"math/big"
 )
 
-func ModAdd(x *big.Int, y *big.Int) *big.Int {
+func ModAddPrime(x *big.Int, y *big.Int, prime *big.Int) *big.Int {
 	z := new(big.Int)
 	z = z.Add(x, y)
-	return z.Mod(z, P)
+	return z.Mod(z, prime)
+}
+
+func ModAdd(x *big.Int, y *big.Int) *big.Int {
+	return ModAddPrime(x, y, P)
 }
 
 func ModAddInt(x *big.Int, y int64) *big.Int {
 	return ModAdd(x, big.NewInt(y))
 }
 
-func ModSub(x *big.Int, y *big.Int) *big.Int {
+func ModSubPrime(x *big.Int, y *big.Int, prime *big.Int) *big.Int {
 	z := new(big.Int)
 	z = z.Sub(x, y)
-	return z.Mod(z, P)
+	return z.Mod(z, prime)
+}
+
+func ModSub(x *big.Int, y *big.Int) *big.Int {
+	return ModSubPrime(x, y, P)
 }
 
 func ModSubInt(x *big.Int, y int64) *big.Int {
@@ -55,19 +63,30 @@ func ModPowPrimeInt(x *big.Int, pow int64, prime *big.Int) *big.Int {
 	return ModPowPrime(x, big.NewInt(pow), prime)
 }
 
+func ModDivPrime(x *big.Int, y *big.Int, prime *big.Int) *big.Int {
+	power := new(big.Int).Sub(prime, BigTwo)
+	inverse := ModPowPrime(y, power, prime)
+	return ModMulPrime(x, inverse, prime)
+}
+
 func ModDiv(x *big.Int, y *big.Int) *big.Int {
-	power := ModSubInt(P, 2)
-	inverse := ModPowPrime(y, power, P)
-	return ModMul(x, inverse)
+	return ModDivPrime(x, y, P)
 }
 
 func ModDivInt(x *big.Int, y int64) *big.Int {
 	return ModDiv(x, big.NewInt(y))
 }
 
+// Only works for primes where prime % 4 == 3, which is the case for secp256k1 (and F_223).
+// The result is only a square root if x actually has one.
+func ModSqrtPrime(x *big.Int, prime *big.Int) *big.Int {
+	exp := new(big.Int).Add(prime, BigOne)
+	exp.Rsh(exp, 2)
+	return ModPowPrime(x, exp, prime)
+}
+
 func ModSqrt(x *big.Int) *big.Int {
-	exp := ModDivInt(ModAddInt(P, 1), 4)
-	return ModPow(x, exp)
+	return ModSqrtPrime(x, P)
 }
 
 func IsEven(x *big.Int) bool {
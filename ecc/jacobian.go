@@ -0,0 +1,329 @@
+package ecc
+
+import (
+	"math/big"
+	"sync"
+)
+
+// Points in Jacobian coordinates, (X, Y, Z) represents the affine point (X/Z^2, Y/Z^3).
+// This lets us add and double without a modular inverse per operation; we only pay for
+// a single inverse when converting back to a regular Point. Z == 0 is the point at infinity.
+type jacobianPoint struct {
+	x *big.Int
+	y *big.Int
+	z *big.Int
+}
+
+// Window size used for the wNAF multiplication of arbitrary points.
+const wnafWindow = 5
+
+// The generator gets a fixed-base table: for every 4 bit window i of the scalar we store
+// j * 16^i * G (j = 1..15) in affine form, so a multiplication is just 64 mixed additions.
+const gTableWindows = 64
+const gTableWindowSize = 15
+
+var gTable [][]Point
+var gTableOnce sync.Once
+
+func newJacobianInfinity() jacobianPoint {
+	return jacobianPoint{x: big.NewInt(1), y: big.NewInt(1), z: big.NewInt(0)}
+}
+
+func toJacobian(p *Point) jacobianPoint {
+	if p.x == nil {
+		return newJacobianInfinity()
+	}
+	return jacobianPoint{x: new(big.Int).Set(p.x), y: new(big.Int).Set(p.y), z: big.NewInt(1)}
+}
+
+func (j *jacobianPoint) isInfinity() bool {
+	return j.z.Sign() == 0
+}
+
+func (j *jacobianPoint) toAffine(c *Curve) Point {
+	if j.isInfinity() {
+		return c.Infinity()
+	}
+
+	p := c.P
+	zInv := new(big.Int).ModInverse(j.z, p)
+	zInv2 := ModMulPrime(zInv, zInv, p)
+	zInv3 := ModMulPrime(zInv2, zInv, p)
+
+	return Point{x: ModMulPrime(j.x, zInv2, p), y: ModMulPrime(j.y, zInv3, p), curve: c}
+}
+
+func (j *jacobianPoint) negate(c *Curve) jacobianPoint {
+	return jacobianPoint{x: j.x, y: ModSubPrime(c.P, j.y, c.P), z: j.z}
+}
+
+// "dbl-2007-bl" from the Explicit-Formulas Database, valid for any curve parameter a.
+func (j *jacobianPoint) double(c *Curve) jacobianPoint {
+	if j.isInfinity() || j.y.Sign() == 0 {
+		return newJacobianInfinity()
+	}
+
+	p := c.P
+	xx := ModMulPrime(j.x, j.x, p)
+	yy := ModMulPrime(j.y, j.y, p)
+	yyyy := ModMulPrime(yy, yy, p)
+	zz := ModMulPrime(j.z, j.z, p)
+
+	// S = 2*((X1+YY)^2-XX-YYYY)
+	s := ModAddPrime(j.x, yy, p)
+	s = ModSubPrime(ModSubPrime(ModMulPrime(s, s, p), xx, p), yyyy, p)
+	s = ModAddPrime(s, s, p)
+
+	// M = 3*XX+a*ZZ^2
+	m := ModMulPrime(xx, big.NewInt(3), p)
+	if c.A.Sign() != 0 {
+		m = ModAddPrime(m, ModMulPrime(c.A, ModMulPrime(zz, zz, p), p), p)
+	}
+
+	// X3 = M^2-2*S
+	x3 := ModSubPrime(ModMulPrime(m, m, p), ModAddPrime(s, s, p), p)
+
+	// Y3 = M*(S-X3)-8*YYYY
+	y3 := ModSubPrime(ModMulPrime(m, ModSubPrime(s, x3, p), p), ModMulPrime(yyyy, big.NewInt(8), p), p)
+
+	// Z3 = (Y1+Z1)^2-YY-ZZ
+	z3 := ModAddPrime(j.y, j.z, p)
+	z3 = ModSubPrime(ModSubPrime(ModMulPrime(z3, z3, p), yy, p), zz, p)
+
+	return jacobianPoint{x: x3, y: y3, z: z3}
+}
+
+// "add-2007-bl" from the Explicit-Formulas Database.
+func (j *jacobianPoint) add(j2 *jacobianPoint, c *Curve) jacobianPoint {
+	if j.isInfinity() {
+		return *j2
+	}
+	if j2.isInfinity() {
+		return *j
+	}
+
+	p := c.P
+	z1z1 := ModMulPrime(j.z, j.z, p)
+	z2z2 := ModMulPrime(j2.z, j2.z, p)
+	u1 := ModMulPrime(j.x, z2z2, p)
+	u2 := ModMulPrime(j2.x, z1z1, p)
+	s1 := ModMulPrime(ModMulPrime(j.y, j2.z, p), z2z2, p)
+	s2 := ModMulPrime(ModMulPrime(j2.y, j.z, p), z1z1, p)
+
+	h := ModSubPrime(u2, u1, p)
+	r := ModSubPrime(s2, s1, p)
+
+	if h.Sign() == 0 {
+		if r.Sign() == 0 {
+			return j.double(c)
+		}
+		return newJacobianInfinity()
+	}
+
+	// I = (2*H)^2, J = H*I, r = 2*(S2-S1), V = U1*I
+	i := ModAddPrime(h, h, p)
+	i = ModMulPrime(i, i, p)
+	jj := ModMulPrime(h, i, p)
+	r = ModAddPrime(r, r, p)
+	v := ModMulPrime(u1, i, p)
+
+	// X3 = r^2-J-2*V
+	x3 := ModSubPrime(ModSubPrime(ModMulPrime(r, r, p), jj, p), ModAddPrime(v, v, p), p)
+
+	// Y3 = r*(V-X3)-2*S1*J
+	y3 := ModSubPrime(ModMulPrime(r, ModSubPrime(v, x3, p), p), ModMulPrime(ModMulPrime(s1, jj, p), big.NewInt(2), p), p)
+
+	// Z3 = ((Z1+Z2)^2-Z1Z1-Z2Z2)*H
+	z3 := ModAddPrime(j.z, j2.z, p)
+	z3 = ModMulPrime(ModSubPrime(ModSubPrime(ModMulPrime(z3, z3, p), z1z1, p), z2z2, p), h, p)
+
+	return jacobianPoint{x: x3, y: y3, z: z3}
+}
+
+// "madd-2007-bl", adding an affine point (Z2 == 1) saves a handful of multiplications.
+func (j *jacobianPoint) addAffine(pt *Point, c *Curve) jacobianPoint {
+	if pt.x == nil {
+		return *j
+	}
+	if j.isInfinity() {
+		return toJacobian(pt)
+	}
+
+	p := c.P
+	z1z1 := ModMulPrime(j.z, j.z, p)
+	u2 := ModMulPrime(pt.x, z1z1, p)
+	s2 := ModMulPrime(ModMulPrime(pt.y, j.z, p), z1z1, p)
+
+	h := ModSubPrime(u2, j.x, p)
+	r := ModSubPrime(s2, j.y, p)
+
+	if h.Sign() == 0 {
+		if r.Sign() == 0 {
+			return j.double(c)
+		}
+		return newJacobianInfinity()
+	}
+
+	// HH = H^2, I = 4*HH, J = H*I, r = 2*(S2-Y1), V = X1*I
+	hh := ModMulPrime(h, h, p)
+	i := ModMulPrime(hh, big.NewInt(4), p)
+	jj := ModMulPrime(h, i, p)
+	r = ModAddPrime(r, r, p)
+	v := ModMulPrime(j.x, i, p)
+
+	// X3 = r^2-J-2*V
+	x3 := ModSubPrime(ModSubPrime(ModMulPrime(r, r, p), jj, p), ModAddPrime(v, v, p), p)
+
+	// Y3 = r*(V-X3)-2*Y1*J
+	y3 := ModSubPrime(ModMulPrime(r, ModSubPrime(v, x3, p), p), ModMulPrime(ModMulPrime(j.y, jj, p), big.NewInt(2), p), p)
+
+	// Z3 = (Z1+H)^2-Z1Z1-HH
+	z3 := ModAddPrime(j.z, h, p)
+	z3 = ModSubPrime(ModSubPrime(ModMulPrime(z3, z3, p), z1z1, p), hh, p)
+
+	return jacobianPoint{x: x3, y: y3, z: z3}
+}
+
+// Computes the width-w non-adjacent form of k, least significant digit first.
+// Every non-zero digit is odd and lies in (-2^(w-1), 2^(w-1)).
+func wnaf(k *big.Int, w uint) []int {
+	digits := make([]int, 0, k.BitLen()+1)
+	window := 1 << w
+	mask := big.Word(window - 1)
+
+	k = new(big.Int).Set(k)
+	for k.Sign() > 0 {
+		digit := 0
+		if k.Bit(0) == 1 {
+			digit = int(k.Bits()[0] & mask)
+			if digit >= window/2 {
+				digit -= window
+			}
+			k.Sub(k, big.NewInt(int64(digit)))
+		}
+
+		digits = append(digits, digit)
+		k.Rsh(k, 1)
+	}
+
+	return digits
+}
+
+func wnafMultiply(p *Point, k *big.Int) jacobianPoint {
+
+	// Precompute the odd multiples P, 3P, 5P, ... (2^(w-1)-1)P
+	c := p.Curve()
+	base := toJacobian(p)
+	twoP := base.double(c)
+	table := make([]jacobianPoint, 1<<(wnafWindow-2))
+	table[0] = base
+	for i := 1; i < len(table); i++ {
+		table[i] = table[i-1].add(&twoP, c)
+	}
+
+	digits := wnaf(k, wnafWindow)
+	result := newJacobianInfinity()
+
+	for i := len(digits) - 1; i >= 0; i-- {
+		result = result.double(c)
+
+		if digits[i] > 0 {
+			result = result.add(&table[digits[i]/2], c)
+		} else if digits[i] < 0 {
+			neg := table[-digits[i]/2].negate(c)
+			result = result.add(&neg, c)
+		}
+	}
+
+	return result
+}
+
+// Multiplies the generator of the curve, using the precomputed table for secp256k1.
+func (c *Curve) generatorMultiply(k *big.Int) jacobianPoint {
+	if Secp256k1.Equals(c) {
+		return generatorMultiply(k)
+	}
+	return wnafMultiply(&c.G, k)
+}
+
+// Multiplies the secp256k1 generator.
+func generatorMultiply(k *big.Int) jacobianPoint {
+	gTableOnce.Do(buildGeneratorTable)
+
+	result := newJacobianInfinity()
+	for i := 0; i < gTableWindows; i++ {
+		nibble := k.Bit(4*i) | k.Bit(4*i+1)<<1 | k.Bit(4*i+2)<<2 | k.Bit(4*i+3)<<3
+		if nibble != 0 {
+			result = result.addAffine(&gTable[i][nibble-1], Secp256k1)
+		}
+	}
+
+	return result
+}
+
+func buildGeneratorTable() {
+	gTable = make([][]Point, gTableWindows)
+	base := toJacobian(&G)
+
+	for i := 0; i < gTableWindows; i++ {
+		gTable[i] = make([]Point, gTableWindowSize)
+
+		current := base
+		for j := 0; j < gTableWindowSize; j++ {
+			gTable[i][j] = current.toAffine(Secp256k1)
+			current = current.add(&base, Secp256k1)
+		}
+
+		// current is now 16 * base, the base of the next window.
+		base = current
+	}
+}
+
+func (p *Point) isGenerator() bool {
+	return p.x != nil && p.x.Cmp(Gx) == 0 && p.y.Cmp(Gy) == 0 && Secp256k1.Equals(p.Curve())
+}
+
+// Computes sum(scalars[i] * points[i]) with interleaved wNAF (Straus' method): every point gets
+// its own table of odd multiples, but all of them share a single chain of doublings. secp256k1 only.
+func multiScalarMultiply(points []Point, scalars []*big.Int) jacobianPoint {
+
+	tables := make([][]jacobianPoint, len(points))
+	digits := make([][]int, len(points))
+	maxLength := 0
+
+	for i := range points {
+		base := toJacobian(&points[i])
+		twoP := base.double(Secp256k1)
+		tables[i] = make([]jacobianPoint, 1<<(wnafWindow-2))
+		tables[i][0] = base
+		for j := 1; j < len(tables[i]); j++ {
+			tables[i][j] = tables[i][j-1].add(&twoP, Secp256k1)
+		}
+
+		digits[i] = wnaf(new(big.Int).Mod(scalars[i], N), wnafWindow)
+		if len(digits[i]) > maxLength {
+			maxLength = len(digits[i])
+		}
+	}
+
+	result := newJacobianInfinity()
+	for bit := maxLength - 1; bit >= 0; bit-- {
+		result = result.double(Secp256k1)
+
+		for i := range points {
+			if bit >= len(digits[i]) {
+				continue
+			}
+
+			digit := digits[i][bit]
+			if digit > 0 {
+				result = result.add(&tables[i][digit/2], Secp256k1)
+			} else if digit < 0 {
+				neg := tables[i][-digit/2].negate(Secp256k1)
+				result = result.add(&neg, Secp256k1)
+			}
+		}
+	}
+
+	return result
+}
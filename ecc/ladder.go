@@ -0,0 +1,130 @@
+package ecc
+
+// Constant time point multiplication for secret scalars. Points are kept in homogeneous
+// projective coordinates (X, Y, Z) -> (X/Z, Y/Z) and combined with the complete addition
+// formulas for a = 0 curves from Renes, Costello and Batina ("Complete addition formulas
+// for prime order elliptic curves", 2016). Complete formulas have no special cases for
+// doubling or the point at infinity, so there is nothing to branch on.
+type projectivePoint struct {
+	x FieldElement
+	y FieldElement
+	z FieldElement
+}
+
+// 3 * b, with b = 7 for secp256k1
+var curveB3 FieldElement
+
+func newProjectiveInfinity() projectivePoint {
+	return projectivePoint{x: NewFieldElement(BigZero), y: NewFieldElement(BigOne), z: NewFieldElement(BigZero)}
+}
+
+func toProjective(p *Point) projectivePoint {
+	if p.x == nil {
+		return newProjectiveInfinity()
+	}
+	return projectivePoint{x: NewFieldElement(p.x), y: NewFieldElement(p.y), z: NewFieldElement(BigOne)}
+}
+
+func (pp *projectivePoint) toAffine() Point {
+	if pp.z.IsZero() {
+		return Secp256k1.Infinity()
+	}
+
+	zInv := pp.z.Inverse()
+	return Point{x: pp.x.Mul(zInv).BigInt(), y: pp.y.Mul(zInv).BigInt(), curve: Secp256k1}
+}
+
+// Algorithm 7 of Renes-Costello-Batina.
+func (pp *projectivePoint) add(q *projectivePoint) projectivePoint {
+	t0 := pp.x.Mul(q.x)
+	t1 := pp.y.Mul(q.y)
+	t2 := pp.z.Mul(q.z)
+	t3 := pp.x.Add(pp.y)
+	t4 := q.x.Add(q.y)
+	t3 = t3.Mul(t4)
+	t4 = t0.Add(t1)
+	t3 = t3.Sub(t4)
+	t4 = pp.y.Add(pp.z)
+	x3 := q.y.Add(q.z)
+	t4 = t4.Mul(x3)
+	x3 = t1.Add(t2)
+	t4 = t4.Sub(x3)
+	x3 = pp.x.Add(pp.z)
+	y3 := q.x.Add(q.z)
+	x3 = x3.Mul(y3)
+	y3 = t0.Add(t2)
+	y3 = x3.Sub(y3)
+	x3 = t0.Add(t0)
+	t0 = x3.Add(t0)
+	t2 = curveB3.Mul(t2)
+	z3 := t1.Add(t2)
+	t1 = t1.Sub(t2)
+	y3 = curveB3.Mul(y3)
+	x3 = t4.Mul(y3)
+	t2 = t3.Mul(t1)
+	x3 = t2.Sub(x3)
+	y3 = y3.Mul(t0)
+	t1 = t1.Mul(z3)
+	y3 = t1.Add(y3)
+	t0 = t0.Mul(t3)
+	z3 = z3.Mul(t4)
+	z3 = z3.Add(t0)
+
+	return projectivePoint{x: x3, y: y3, z: z3}
+}
+
+// Algorithm 9 of Renes-Costello-Batina.
+func (pp *projectivePoint) double() projectivePoint {
+	t0 := pp.y.Square()
+	z3 := t0.Add(t0)
+	z3 = z3.Add(z3)
+	z3 = z3.Add(z3)
+	t1 := pp.y.Mul(pp.z)
+	t2 := pp.z.Square()
+	t2 = curveB3.Mul(t2)
+	x3 := t2.Mul(z3)
+	y3 := t0.Add(t2)
+	z3 = t1.Mul(z3)
+	t1 = t2.Add(t2)
+	t2 = t1.Add(t2)
+	t0 = t0.Sub(t2)
+	y3 = t0.Mul(y3)
+	y3 = x3.Add(y3)
+	t1 = pp.x.Mul(pp.y)
+	x3 = t0.Mul(t1)
+	x3 = x3.Add(x3)
+
+	return projectivePoint{x: x3, y: y3, z: z3}
+}
+
+// Swaps p and q when bit == 1, without branching.
+func conditionalSwap(bit uint64, p *projectivePoint, q *projectivePoint) {
+	px, py, pz := p.x, p.y, p.z
+	p.x, p.y, p.z = p.x.selectIf(bit, q.x), p.y.selectIf(bit, q.y), p.z.selectIf(bit, q.z)
+	q.x, q.y, q.z = q.x.selectIf(bit, px), q.y.selectIf(bit, py), q.z.selectIf(bit, pz)
+}
+
+// Multiplies the point by a secret scalar using a Montgomery ladder. The ladder always walks
+// all 256 bits and does one addition and one doubling per bit, whatever the value of the bit.
+// Only secp256k1 points are supported, anything else falls back to ScalarMultiply.
+func (p *Point) ScalarMultiplyConstantTime(k Scalar) Point {
+	if !Secp256k1.Equals(p.Curve()) {
+		return p.ScalarMultiply(k.BigInt())
+	}
+
+	kLimbs := scalarModulus.fromMontgomery(k.n)
+
+	r0 := newProjectiveInfinity()
+	r1 := toProjective(p)
+
+	for i := 255; i >= 0; i-- {
+		bit := (kLimbs[i/64] >> (i % 64)) & 1
+
+		conditionalSwap(bit, &r0, &r1)
+		r1 = r0.add(&r1)
+		r0 = r0.double()
+		conditionalSwap(bit, &r0, &r1)
+	}
+
+	return r0.toAffine()
+}
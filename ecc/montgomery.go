@@ -0,0 +1,197 @@
+package ecc
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+// Fixed width (4 x 64 bit limbs, little-endian) Montgomery arithmetic used by FieldElement and Scalar.
+// None of these functions branch on, or index memory with, the values they operate on. Conditional
+// behaviour is done with masks so secret data doesn't leak through timing.
+type limbs [4]uint64
+
+type montgomeryModulus struct {
+	m       limbs  // The modulus, must be odd and larger than 2^255
+	mInv    uint64 // -m^-1 mod 2^64
+	r2      limbs  // R^2 mod m, where R = 2^256
+	one     limbs  // R mod m, i.e. 1 in Montgomery form
+	mMinus2 limbs  // Exponent used for inversion (Fermat's little theorem)
+}
+
+func newMontgomeryModulus(m *big.Int) *montgomeryModulus {
+	mod := new(montgomeryModulus)
+	mod.m = bigIntToLimbs(m)
+
+	// Newton iteration for m^-1 mod 2^64, then negate.
+	inv := uint64(1)
+	for i := 0; i < 6; i++ {
+		inv *= 2 - mod.m[0]*inv
+	}
+	mod.mInv = -inv
+
+	r := new(big.Int).Lsh(BigOne, 256)
+	mod.one = bigIntToLimbs(new(big.Int).Mod(r, m))
+	r.Mul(r, r)
+	mod.r2 = bigIntToLimbs(r.Mod(r, m))
+	mod.mMinus2 = bigIntToLimbs(new(big.Int).Sub(m, BigTwo))
+
+	return mod
+}
+
+func bigIntToLimbs(x *big.Int) limbs {
+	var buffer [32]byte
+	x.FillBytes(buffer[:])
+	return bytesToLimbs(buffer[:])
+}
+
+// Interprets 32 big-endian bytes as limbs.
+func bytesToLimbs(b []byte) limbs {
+	var l limbs
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 8; j++ {
+			l[3-i] = l[3-i]<<8 | uint64(b[i*8+j])
+		}
+	}
+	return l
+}
+
+func limbsToBytes(l limbs) []byte {
+	b := make([]byte, 32)
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 8; j++ {
+			b[i*8+j] = byte(l[3-i] >> (56 - 8*j))
+		}
+	}
+	return b
+}
+
+// Returns b when bit == 1 and a when bit == 0.
+func selectLimbs(bit uint64, a limbs, b limbs) limbs {
+	mask := -bit
+	var r limbs
+	for i := range r {
+		r[i] = a[i] ^ (mask & (a[i] ^ b[i]))
+	}
+	return r
+}
+
+// Returns t - m if t >= m (taking the extra carry limb into account), otherwise t.
+func (mod *montgomeryModulus) reduceOnce(t limbs, carry uint64) limbs {
+	var u limbs
+	var borrow uint64
+	u[0], borrow = bits.Sub64(t[0], mod.m[0], 0)
+	u[1], borrow = bits.Sub64(t[1], mod.m[1], borrow)
+	u[2], borrow = bits.Sub64(t[2], mod.m[2], borrow)
+	u[3], borrow = bits.Sub64(t[3], mod.m[3], borrow)
+
+	// Keep the subtraction if there was a carry out, or if it didn't underflow.
+	_, underflow := bits.Sub64(carry, borrow, 0)
+	return selectLimbs(underflow, u, t)
+}
+
+func (mod *montgomeryModulus) add(a limbs, b limbs) limbs {
+	var t limbs
+	var carry uint64
+	t[0], carry = bits.Add64(a[0], b[0], 0)
+	t[1], carry = bits.Add64(a[1], b[1], carry)
+	t[2], carry = bits.Add64(a[2], b[2], carry)
+	t[3], carry = bits.Add64(a[3], b[3], carry)
+	return mod.reduceOnce(t, carry)
+}
+
+func (mod *montgomeryModulus) sub(a limbs, b limbs) limbs {
+	var t limbs
+	var borrow uint64
+	t[0], borrow = bits.Sub64(a[0], b[0], 0)
+	t[1], borrow = bits.Sub64(a[1], b[1], borrow)
+	t[2], borrow = bits.Sub64(a[2], b[2], borrow)
+	t[3], borrow = bits.Sub64(a[3], b[3], borrow)
+
+	// Add the modulus back if we went negative.
+	mask := -borrow
+	var carry uint64
+	t[0], carry = bits.Add64(t[0], mod.m[0]&mask, 0)
+	t[1], carry = bits.Add64(t[1], mod.m[1]&mask, carry)
+	t[2], carry = bits.Add64(t[2], mod.m[2]&mask, carry)
+	t[3], _ = bits.Add64(t[3], mod.m[3]&mask, carry)
+	return t
+}
+
+// Montgomery multiplication (CIOS): returns a * b * R^-1 mod m.
+func (mod *montgomeryModulus) mul(a limbs, b limbs) limbs {
+	var t [6]uint64
+
+	for i := 0; i < 4; i++ {
+		// t += a[i] * b
+		var c uint64
+		for j := 0; j < 4; j++ {
+			hi, lo := bits.Mul64(a[i], b[j])
+			var cc uint64
+			lo, cc = bits.Add64(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add64(lo, c, 0)
+			hi += cc
+			t[j] = lo
+			c = hi
+		}
+		var cc uint64
+		t[4], cc = bits.Add64(t[4], c, 0)
+		t[5] = cc
+
+		// t = (t + q * m) / 2^64, where q makes the lowest limb zero.
+		q := t[0] * mod.mInv
+		hi, lo := bits.Mul64(q, mod.m[0])
+		_, cc = bits.Add64(lo, t[0], 0)
+		c = hi + cc
+		for j := 1; j < 4; j++ {
+			hi, lo = bits.Mul64(q, mod.m[j])
+			lo, cc = bits.Add64(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add64(lo, c, 0)
+			hi += cc
+			t[j-1] = lo
+			c = hi
+		}
+		t[3], cc = bits.Add64(t[4], c, 0)
+		t[4] = t[5] + cc
+	}
+
+	return mod.reduceOnce(limbs{t[0], t[1], t[2], t[3]}, t[4])
+}
+
+// a^exp for a public exponent. The exponent's bits may be branched on, the base's may not.
+func (mod *montgomeryModulus) pow(a limbs, exp limbs) limbs {
+	result := mod.one
+	for i := 255; i >= 0; i-- {
+		result = mod.mul(result, result)
+		if (exp[i/64]>>(i%64))&1 == 1 {
+			result = mod.mul(result, a)
+		}
+	}
+	return result
+}
+
+func (mod *montgomeryModulus) inverse(a limbs) limbs {
+	return mod.pow(a, mod.mMinus2)
+}
+
+// Converts a canonical value (which may be >= m, but < 2^256) into Montgomery form.
+func (mod *montgomeryModulus) toMontgomery(a limbs) limbs {
+	return mod.mul(mod.reduceOnce(a, 0), mod.r2)
+}
+
+func (mod *montgomeryModulus) fromMontgomery(a limbs) limbs {
+	return mod.mul(a, limbs{1, 0, 0, 0})
+}
+
+func limbsEqual(a limbs, b limbs) bool {
+	var diff uint64
+	for i := range a {
+		diff |= a[i] ^ b[i]
+	}
+	return diff == 0
+}
+
+func limbsIsZero(a limbs) bool {
+	return limbsEqual(a, limbs{})
+}
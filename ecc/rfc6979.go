@@ -0,0 +1,74 @@
+package ecc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"math/big"
+)
+
+// Deterministic nonce generation as described in RFC 6979 (section 3.2) using HMAC-SHA256.
+// The optional extra entropy is appended to the key and message the same way libsecp256k1 /
+// Bitcoin Core do it, so the nonce stays reproducible for a given (key, hash, entropy) triple.
+func deterministicK(secret *big.Int, hash *big.Int, extraEntropy []byte) *big.Int {
+	return deterministicKForOrder(secret, hash, extraEntropy, N)
+}
+
+// deterministicK for a group of order n. For curves smaller than 256 bits the candidates are
+// truncated to the bit length of n (bits2int in the RFC), otherwise we'd hardly ever find one below n.
+func deterministicKForOrder(secret *big.Int, hash *big.Int, extraEntropy []byte, n *big.Int) *big.Int {
+
+	qlen := n.BitLen()
+	rlen := (qlen + 7) / 8
+
+	// x and h1 are the big-endian encodings of the secret and the hash reduced mod n.
+	x := make([]byte, rlen)
+	new(big.Int).Mod(secret, n).FillBytes(x)
+
+	z := new(big.Int)
+	z.Mod(hash, n)
+	h1 := make([]byte, rlen)
+	z.FillBytes(h1)
+
+	seed := append(append(x, h1...), extraEntropy...)
+
+	// Step b & c
+	v := make([]byte, 32)
+	for i := range v {
+		v[i] = 0x01
+	}
+	k := make([]byte, 32)
+
+	// Step d & e
+	k = hmacSha256(k, v, []byte{0x00}, seed)
+	v = hmacSha256(k, v)
+
+	// Step f & g
+	k = hmacSha256(k, v, []byte{0x01}, seed)
+	v = hmacSha256(k, v)
+
+	// Step h: keep generating candidates until one lands in [1, n-1].
+	for {
+		v = hmacSha256(k, v)
+
+		candidate := new(big.Int)
+		candidate.SetBytes(v)
+		if qlen < 8*len(v) {
+			candidate.Rsh(candidate, uint(8*len(v)-qlen))
+		}
+
+		if candidate.Sign() > 0 && candidate.Cmp(n) < 0 {
+			return candidate
+		}
+
+		k = hmacSha256(k, v, []byte{0x00})
+		v = hmacSha256(k, v)
+	}
+}
+
+func hmacSha256(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
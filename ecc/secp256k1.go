@@ -38,9 +38,18 @@ func init() {
 	P = tmp.Sub(tmp, tmp2)
 	P = P.Sub(P, big.NewInt(977))
 
+	N = utility.HexStringToBigInt("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141")
+
+	// The generator needs the curve and the curve needs the generator, so fill it in afterwards.
+	Secp256k1 = &Curve{Name: "secp256k1", P: P, A: A, B: B, N: N}
+
 	Gx = utility.HexStringToBigInt("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798")
 	Gy = utility.HexStringToBigInt("483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8")
 	G = NewSecp256k1Point(Gx, Gy)
+	Secp256k1.G = G
 
-	N = utility.HexStringToBigInt("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141")
+	// Parameters for the constant time FieldElement and Scalar types.
+	fieldModulus = newMontgomeryModulus(P)
+	scalarModulus = newMontgomeryModulus(N)
+	curveB3 = NewFieldElement(new(big.Int).Mul(B, BigThree))
 }
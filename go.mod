@@ -2,4 +2,7 @@ module bitcoin-go
 
 go 1.19
 
-require golang.org/x/crypto v0.1.0
+require (
+	golang.org/x/crypto v0.1.0
+	golang.org/x/text v0.4.0
+)
@@ -0,0 +1,229 @@
+package utility
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Bech32 (BIP173) and Bech32m (BIP350) encoding used by SegWit addresses.
+
+const BECH32_ALPHABET string = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+type Bech32Encoding int
+
+const (
+	Bech32 Bech32Encoding = iota
+	Bech32m
+)
+
+// The constant the checksum is xor'd with, which is the only difference between the two encodings.
+func (encoding Bech32Encoding) checksumConstant() uint32 {
+	if encoding == Bech32m {
+		return 0x2bc830a3
+	}
+	return 1
+}
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HrpExpand(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+	return expanded
+}
+
+func bech32CreateChecksum(hrp string, data []byte, encoding Bech32Encoding) []byte {
+	values := append(bech32HrpExpand(hrp), data...)
+	values = append(values, 0, 0, 0, 0, 0, 0)
+	polymod := bech32Polymod(values) ^ encoding.checksumConstant()
+
+	checksum := make([]byte, 6)
+	for i := range checksum {
+		checksum[i] = byte(polymod>>(5*(5-i))) & 31
+	}
+	return checksum
+}
+
+// Encodes the 5 bit groups in data with the given human readable part.
+func EncodeBech32(hrp string, data []byte, encoding Bech32Encoding) string {
+	hrp = strings.ToLower(hrp)
+	combined := append(append([]byte{}, data...), bech32CreateChecksum(hrp, data, encoding)...)
+
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, d := range combined {
+		sb.WriteByte(BECH32_ALPHABET[d])
+	}
+	return sb.String()
+}
+
+// Decodes a Bech32 or Bech32m string into its (lower case) human readable part and 5 bit groups,
+// telling which of the two encodings the checksum matched.
+func DecodeBech32(s string) (string, []byte, Bech32Encoding, error) {
+
+	if len(s) > 90 {
+		return "", nil, 0, fmt.Errorf("bech32 string can't be longer than 90 characters, not %v", len(s))
+	}
+
+	hasLower, hasUpper := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 33 || c > 126 {
+			return "", nil, 0, fmt.Errorf("invalid character 0x%02x in bech32 string", c)
+		}
+		hasLower = hasLower || (c >= 'a' && c <= 'z')
+		hasUpper = hasUpper || (c >= 'A' && c <= 'Z')
+	}
+	if hasLower && hasUpper {
+		return "", nil, 0, errors.New("bech32 string can't mix upper and lower case")
+	}
+	s = strings.ToLower(s)
+
+	// The separator is the last 1, the human readable part may contain 1s as well.
+	separator := strings.LastIndexByte(s, '1')
+	if separator < 1 {
+		return "", nil, 0, errors.New("bech32 string has no human readable part")
+	}
+	if separator+7 > len(s) {
+		return "", nil, 0, errors.New("bech32 checksum is too short")
+	}
+
+	hrp := s[:separator]
+	data := make([]byte, len(s)-separator-1)
+	for i := range data {
+		d := strings.IndexByte(BECH32_ALPHABET, s[separator+1+i])
+		if d < 0 {
+			return "", nil, 0, fmt.Errorf("invalid bech32 data character %q", s[separator+1+i])
+		}
+		data[i] = byte(d)
+	}
+
+	var encoding Bech32Encoding
+	switch bech32Polymod(append(bech32HrpExpand(hrp), data...)) {
+	case Bech32.checksumConstant():
+		encoding = Bech32
+	case Bech32m.checksumConstant():
+		encoding = Bech32m
+	default:
+		return "", nil, 0, errors.New("invalid bech32 checksum")
+	}
+
+	return hrp, data[:len(data)-6], encoding, nil
+}
+
+// Regroups the bits of data from groups of fromBits into groups of toBits.
+func ConvertBits(data []byte, fromBits uint, toBits uint, pad bool) ([]byte, bool) {
+	acc := uint32(0)
+	bits := uint(0)
+	maxv := uint32(1)<<toBits - 1
+	result := make([]byte, 0, len(data)*int(fromBits)/int(toBits)+1)
+
+	for _, value := range data {
+		if uint32(value)>>fromBits != 0 {
+			return nil, false
+		}
+		acc = acc<<fromBits | uint32(value)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			result = append(result, byte(acc>>bits&maxv))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			result = append(result, byte(acc<<(toBits-bits)&maxv))
+		}
+	} else if bits >= fromBits || (acc<<(toBits-bits))&maxv != 0 {
+		return nil, false
+	}
+
+	return result, true
+}
+
+// Creates a SegWit address. Version 0 programs use Bech32, later versions use Bech32m.
+func EncodeSegWitAddress(hrp string, version byte, program []byte) string {
+	data, _ := ConvertBits(program, 8, 5, true)
+	data = append([]byte{version}, data...)
+
+	encoding := Bech32
+	if version > 0 {
+		encoding = Bech32m
+	}
+
+	return EncodeBech32(hrp, data, encoding)
+}
+
+// Decodes a SegWit address with the expected human readable part ("bc" or "tb"), returning its
+// witness version and program. Version 0 must use Bech32 and have a 20 or 32 byte program, later
+// versions must use Bech32m and have a program of 2 to 40 bytes.
+func DecodeSegWitAddress(hrp string, address string) (byte, []byte, error) {
+
+	decodedHrp, data, encoding, err := DecodeBech32(address)
+	if err != nil {
+		return 0, nil, err
+	}
+	if decodedHrp != strings.ToLower(hrp) {
+		return 0, nil, fmt.Errorf("expected human readable part %q, got %q", hrp, decodedHrp)
+	}
+	if len(data) == 0 {
+		return 0, nil, errors.New("address has no witness version")
+	}
+
+	version := data[0]
+	if version > 16 {
+		return 0, nil, fmt.Errorf("invalid witness version %v", version)
+	}
+
+	program, ok := ConvertBits(data[1:], 5, 8, false)
+	if !ok {
+		return 0, nil, errors.New("invalid witness program padding")
+	}
+	if len(program) < 2 || len(program) > 40 {
+		return 0, nil, fmt.Errorf("witness program must be 2 to 40 bytes, not %v", len(program))
+	}
+	if version == 0 && len(program) != 20 && len(program) != 32 {
+		return 0, nil, fmt.Errorf("version 0 witness program must be 20 or 32 bytes, not %v", len(program))
+	}
+
+	if (version == 0 && encoding != Bech32) || (version > 0 && encoding != Bech32m) {
+		return 0, nil, fmt.Errorf("wrong checksum encoding for witness version %v", version)
+	}
+
+	return version, program, nil
+}
+
+func H160ToP2WPKHAddress(hash []byte, testnet bool) string {
+	return EncodeSegWitAddress(IIF(testnet, "tb", "bc").(string), 0, hash)
+}
+
+func H256ToP2WSHAddress(hash []byte, testnet bool) string {
+	return EncodeSegWitAddress(IIF(testnet, "tb", "bc").(string), 0, hash)
+}
+
+// The address of a Taproot output, given its 32 byte (x-only) output key.
+func OutputKeyToP2TRAddress(outputKey []byte, testnet bool) string {
+	return EncodeSegWitAddress(IIF(testnet, "tb", "bc").(string), 1, outputKey)
+}
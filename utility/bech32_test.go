@@ -0,0 +1,176 @@
+package utility
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestBech32Checksums(t *testing.T) {
+
+	// From BIP173 and BIP350.
+	valid := map[Bech32Encoding][]string{
+		Bech32: {
+			"A12UEL5L",
+			"a12uel5l",
+			"an83characterlonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1tt5tgs",
+			"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw",
+			"11qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqc8247j",
+			"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w",
+			"?1ezyfcl",
+		},
+		Bech32m: {
+			"A1LQFN3A",
+			"a1lqfn3a",
+			"an83characterlonghumanreadablepartthatcontainsthetheexcludedcharactersbioandnumber11sg7hg6",
+			"abcdef1l7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx",
+			"11llllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllludsr8",
+			"split1checkupstagehandshakeupstreamerranterredcaperredlc445v",
+			"?1v759aa",
+		},
+	}
+
+	for encoding, strs := range valid {
+		for _, s := range strs {
+			hrp, data, decodedEncoding, err := DecodeBech32(s)
+			if err != nil {
+				t.Errorf("%v: %v", s, err)
+				continue
+			}
+			if decodedEncoding != encoding {
+				t.Errorf("%v: decoded with the wrong encoding", s)
+			}
+			if encoded := EncodeBech32(hrp, data, encoding); encoded != strings.ToLower(s) {
+				t.Errorf("%v: re-encoded as %v", s, encoded)
+			}
+		}
+	}
+
+	invalid := []string{
+		"\x201nwldj5", // HRP character out of range
+		"\x7f1axkwrx", // HRP character out of range
+		"\x801eym55h", // HRP character out of range
+		"an84characterslonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1569pvx", // Too long
+		"pzry9x0s0muk",  // No separator
+		"1pzry9x0s0muk", // Empty HRP
+		"x1b4n0q5v",     // Invalid data character
+		"li1dgmt3",      // Checksum too short
+		"de1lg7wt\xff",  // Invalid character in checksum
+		"A1G7SGD8",      // Checksum calculated with upper case HRP
+		"10a06t8",       // Empty HRP
+		"1qzzfhee",      // Empty HRP
+		"\x201xj0phk",   // HRP character out of range
+		"\x7f1g6xzxy",   // HRP character out of range
+		"\x801vctc34",   // HRP character out of range
+		"an84characterslonghumanreadablepartthatcontainsthetheexcludedcharactersbioandnumber11d6pts4", // Too long
+		"qyrz8wqd2c9m",  // No separator
+		"1qyrz8wqd2c9m", // Empty HRP
+		"y1b0jsk6g",     // Invalid data character
+		"lt1igcx5c0",    // Invalid data character
+		"in1muywd",      // Checksum too short
+		"mm1crxm3i",     // Invalid character in checksum
+		"au1s5cgom",     // Invalid character in checksum
+		"M1VUXWEZ",      // Checksum calculated with upper case HRP
+		"16plkw9",       // Empty HRP
+		"1p2gdwpf",      // Empty HRP
+	}
+
+	for _, s := range invalid {
+		if _, _, _, err := DecodeBech32(s); err == nil {
+			t.Errorf("%q should be invalid", s)
+		}
+	}
+}
+
+func TestSegWitAddresses(t *testing.T) {
+
+	// From BIP350, the scriptPubKey of each address.
+	valid := []struct {
+		address      string
+		scriptPubKey string
+	}{
+		{"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", "0014751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", "00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262"},
+		{"bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kt5nd6y", "5128751e76e8199196d454941c45d1b3a323f1433bd6751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"BC1SW50QGDZ25J", "6002751e"},
+		{"bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs", "5210751e76e8199196d454941c45d1b3a323"},
+		{"tb1qqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesrxh6hy", "0020000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433"},
+		{"tb1pqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesf3hn0c", "5120000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433"},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+	}
+
+	for _, testCase := range valid {
+		hrp := "bc"
+		version, program, err := DecodeSegWitAddress(hrp, testCase.address)
+		if err != nil {
+			hrp = "tb"
+			version, program, err = DecodeSegWitAddress(hrp, testCase.address)
+		}
+		if err != nil {
+			t.Errorf("%v: %v", testCase.address, err)
+			continue
+		}
+
+		// OP_0 is 0x00, OP_1 to OP_16 are 0x51 to 0x60.
+		opcode := version
+		if version > 0 {
+			opcode += 0x50
+		}
+		scriptPubKey := hex.EncodeToString(append([]byte{opcode, byte(len(program))}, program...))
+		if scriptPubKey != testCase.scriptPubKey {
+			t.Errorf("%v: expected %v, got %v", testCase.address, testCase.scriptPubKey, scriptPubKey)
+		}
+
+		if encoded := EncodeSegWitAddress(hrp, version, program); encoded != strings.ToLower(testCase.address) {
+			t.Errorf("%v: re-encoded as %v", testCase.address, encoded)
+		}
+	}
+
+	// From BIP173 and BIP350.
+	invalid := []string{
+		"tc1qw508d6qejxtdg4y5r3zarvary0c5xw7kg3g4ty", // Invalid HRP
+		"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5", // Invalid checksum
+		"BC13W508D6QEJXTDG4Y5R3ZARVARY0C5XW7KN40WF2", // Invalid witness version
+		"bc1rw5uspcuh", // Invalid program length
+		"bc10w508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kw5rljs90", // Invalid program length
+		"BC1QR508D6QEJXTDG4Y5R3ZARVARYV98GJ9P",                                         // Invalid program length for version 0
+		"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sL5k7",               // Mixed case
+		"bc1zw508d6qejxtdg4y5r3zarvaryvqyzf3du",                                        // Zero padding of more than 4 bits
+		"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3pjxtptv",               // Non-zero padding
+		"bc1gmk9yu", // Empty data section
+		"tc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq5zuyut", // Invalid HRP
+		"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd", // Bech32 instead of Bech32m
+		"tb1z0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqglt7rf", // Bech32 instead of Bech32m
+		"BC1S0XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ54WELL", // Bech32 instead of Bech32m
+		"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh",                     // Bech32m instead of Bech32
+		"tb1q0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq24jc47", // Bech32m instead of Bech32
+		"bc1p38j9r5y49hruaue7wxjce0updqjuyyx0kh56v8s25huc6995vvpql3jow4", // Invalid character in checksum
+		"BC130XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ7ZWS8R", // Invalid witness version
+		"bc1pw5dgrnzv", // Invalid program length (1 byte)
+		"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7v8n0nx0muaewav253zgeav", // Invalid program length (41 bytes)
+		"tb1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq47Zagq",               // Mixed case
+		"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7v07qwwzcrf",             // Zero padding of more than 4 bits
+		"tb1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vpggkg4j",               // Non-zero padding
+	}
+
+	for _, address := range invalid {
+		for _, hrp := range []string{"bc", "tb"} {
+			if _, _, err := DecodeSegWitAddress(hrp, address); err == nil {
+				t.Errorf("%v should be invalid", address)
+			}
+		}
+	}
+}
+
+func TestP2WSHAndP2TRAddress(t *testing.T) {
+
+	hash, _ := hex.DecodeString("1863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262")
+	if address := H256ToP2WSHAddress(hash, true); address != "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7" {
+		t.Errorf("Unexpected P2WSH address %v", address)
+	}
+
+	outputKey, _ := hex.DecodeString("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798")
+	if address := OutputKeyToP2TRAddress(outputKey, false); address != "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0" {
+		t.Errorf("Unexpected P2TR address %v", address)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"crypto/rand"
 	"crypto/sha1"
 	"crypto/sha256"
+	"errors"
+	"fmt"
 	"math/big"
 	"strings"
 
@@ -31,6 +33,18 @@ func Hash256(bytes []byte) []byte {
 	return Sha256(Sha256(bytes))
 }
 
+// BIP340 style tagged hash: SHA256(SHA256(tag) || SHA256(tag) || data)
+func TaggedHash(tag string, data ...[]byte) []byte {
+	tagHash := Sha256([]byte(tag))
+	hash := sha256.New()
+	hash.Write(tagHash)
+	hash.Write(tagHash)
+	for _, d := range data {
+		hash.Write(d)
+	}
+	return hash.Sum(nil)
+}
+
 func HashRipemd160(bytes []byte) []byte {
 	hash := ripemd160.New()
 	hash.Write(bytes)
@@ -43,30 +57,53 @@ func Hash160(bytes []byte) []byte {
 
 const BASE58_ALPHABET string = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
 
-func DecodeBase58(addr string) ([]byte, bool) {
+// Decodes a Base58 string, turning every leading '1' back into a zero byte.
+func DecodeBase58Raw(s string) ([]byte, error) {
 
-	var num *big.Int = big.NewInt(0)
+	num := big.NewInt(0)
+	fiftyeight := big.NewInt(58)
+	zeros := 0
+	leading := true
 
-	for _, c := range addr {
-		var digit int = strings.IndexRune(BASE58_ALPHABET, c)
+	for _, c := range s {
+		digit := strings.IndexRune(BASE58_ALPHABET, c)
 		if digit == -1 {
-			return nil, false
+			return nil, fmt.Errorf("invalid Base58 character %q", c)
 		}
 
-		num = num.Mul(num, big.NewInt(58))
-		num = num.Add(num, big.NewInt(int64(digit)))
+		if leading && digit == 0 {
+			zeros++
+			continue
+		}
+		leading = false
+
+		num.Mul(num, fiftyeight)
+		num.Add(num, big.NewInt(int64(digit)))
 	}
 
-	bin := num.Bytes()
-	checksum := bin[len(bin)-4:]
+	return append(make([]byte, zeros), num.Bytes()...), nil
+}
+
+// Decodes a Base58Check string and verifies its checksum. The result is the whole payload,
+// version byte included.
+func DecodeBase58Checksum(s string) ([]byte, error) {
 
-	h256 := Hash256(bin[:len(bin)-4])
+	bin, err := DecodeBase58Raw(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bin) < 5 {
+		return nil, errors.New("Base58Check data is too short")
+	}
 
-	if !bytes.Equal(checksum, h256[:4]) {
-		return nil, false
+	payload := bin[:len(bin)-4]
+	h256 := Hash256(payload)
+	if !bytes.Equal(bin[len(bin)-4:], h256[:4]) {
+		return nil, errors.New("invalid Base58Check checksum")
 	}
 
-	return bin[1 : len(bin)-4], true
+	return payload, nil
 }
 
 func EncodeBase58(bytes []byte) string {
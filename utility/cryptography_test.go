@@ -9,10 +9,11 @@ import (
 func TestBase58(t *testing.T) {
 
 	addr := "mnrVtF8DWjMu839VW3rBfgYaAfKk8983Xf"
-	h160, ok := DecodeBase58(addr)
-	if !ok {
+	payload, err := DecodeBase58Checksum(addr)
+	if err != nil {
 		t.Error()
 	}
+	h160 := payload[1:]
 
 	expected, err := hex.DecodeString("507b27411ccf7f16f10297de6cef3f291623eddf")
 	if err != nil {
@@ -30,6 +31,32 @@ func TestBase58(t *testing.T) {
 	}
 }
 
+func TestDecodeBase58Checksum(t *testing.T) {
+
+	// A mainnet P2PKH address starts with a zero version byte, which has to survive the round trip.
+	payload, err := DecodeBase58Checksum("1111111111111111111114oLvT2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(payload, make([]byte, 21)) {
+		t.Errorf("Expected 21 zero bytes, got %x", payload)
+	}
+
+	payload, err = DecodeBase58Checksum("mnrVtF8DWjMu839VW3rBfgYaAfKk8983Xf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(payload) != "6f507b27411ccf7f16f10297de6cef3f291623eddf" {
+		t.Errorf("Unexpected payload %x", payload)
+	}
+
+	for _, invalid := range []string{"", "1", "mnrVtF8DWjMu839VW3rBfgYaAfKk8983Xg", "mnrVtF8DWjMu839VW3rBfgYaAfKk8983X0"} {
+		if _, err := DecodeBase58Checksum(invalid); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
 func TestP2PKHAddress(t *testing.T) {
 
 	h160, err := hex.DecodeString("74d691da1574e6b3c192ecfb52cc8984ee7b6c56")
@@ -69,3 +96,59 @@ func TestP2SHAddress(t *testing.T) {
 		t.Error()
 	}
 }
+
+func TestTaggedHash(t *testing.T) {
+
+	// The tagged hash must equal the manual construction, regardless of how the data is split up.
+	tag := Sha256([]byte("BIP0340/challenge"))
+	expected := Sha256(append(append(tag, tag...), []byte("abc")...))
+
+	if !bytes.Equal(TaggedHash("BIP0340/challenge", []byte("a"), []byte("bc")), expected) {
+		t.Error()
+	}
+}
+
+func TestP2WPKHAddress(t *testing.T) {
+
+	h160, err := hex.DecodeString("751e76e8199196d454941c45d1b3a323f1433bd6")
+	if err != nil {
+		t.Error()
+	}
+
+	expected := "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"
+	addr := H160ToP2WPKHAddress(h160, false)
+	if expected != addr {
+		t.Errorf("Expected %v, got %v", expected, addr)
+	}
+
+	expected = "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx"
+	addr = H160ToP2WPKHAddress(h160, true)
+	if expected != addr {
+		t.Errorf("Expected %v, got %v", expected, addr)
+	}
+}
+
+func TestWriteVarInt(t *testing.T) {
+
+	testCases := map[uint64]string{
+		0:           "00",
+		0xfc:        "fc",
+		0xfd:        "fdfd00",
+		0xffff:      "fdffff",
+		0x10000:     "fe00000100",
+		0xffffffff:  "feffffffff",
+		0x100000000: "ff0000000001000000",
+	}
+
+	for value, expected := range testCases {
+		var buffer bytes.Buffer
+		WriteVarInt(&buffer, value)
+		if hex.EncodeToString(buffer.Bytes()) != expected {
+			t.Errorf("%v: expected %v, got %x", value, expected, buffer.Bytes())
+		}
+
+		if ReadVarInt(&buffer) != value {
+			t.Errorf("%v: didn't round trip", value)
+		}
+	}
+}
@@ -101,11 +101,11 @@ func WriteVarInt(writer io.Writer, i uint64) {
 	varIntLen := 0
 	buff[0] = buff[1]
 
-	if i > 253 {
-		if i > uint64(math.Pow(2, 32)) {
+	if i >= 0xfd {
+		if i >= uint64(math.Pow(2, 32)) {
 			varIntLen = 8
 			buff[0] = 0xff
-		} else if i > uint64(math.Pow(2, 16)) {
+		} else if i >= uint64(math.Pow(2, 16)) {
 			varIntLen = 4
 			buff[0] = 0xfe
 		} else {